@@ -0,0 +1,268 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+
+	jrpc "github.com/AdamSLevy/jsonrpc2/v11"
+	"github.com/Factom-Asset-Tokens/fatd/factom"
+	"github.com/Factom-Asset-Tokens/fatd/fat"
+	"github.com/posener/complete"
+	"github.com/spf13/cobra"
+)
+
+var exportStart, exportEnd uint32
+
+// exportCmd represents the export command
+var exportCmd = func() *cobra.Command {
+	cmd := &cobra.Command{
+		DisableFlagsInUseLine: true,
+		Use: `
+export DIR [--start <height>] [--end <height>]`[1:],
+		Short: "Export an archive of Factom blocks for fatd to replay",
+		Long: `
+Export the DBlocks from --start to --end from factomd into an archive in DIR,
+along with everything that fatd needs to sync those DBlocks. Run fatd with
+-archive DIR to replay the archive without factomd.
+
+Every EBlock, and the first Entry of every new chain, is exported, so that
+fatd can find new token chains. All Entries of token chains that are created
+within the exported DBlocks are exported, along with the first EBlock and Entry
+of each token issuer's Identity Chain. Token chains created before --start are
+not exported, so fatd should replay the archive on a new database starting
+from --start using -startscanheight.
+
+If --end is not given, the current DBlock height is used. An existing archive
+in DIR is extended. Everything exported is verified against the DBlocks.
+`[1:],
+		Args: cobra.ExactArgs(1),
+		Run:  export,
+	}
+	rootCmd.AddCommand(cmd)
+	rootCmplCmd.Sub["export"] = exportCmplCmd
+	rootCmplCmd.Sub["help"].Sub["export"] = complete.Command{}
+
+	flags := cmd.Flags()
+	flags.Uint32Var(&exportStart, "start", 0, "First DBlock height to export")
+	flags.Uint32Var(&exportEnd, "end", 0,
+		"Last DBlock height to export (default current height)")
+
+	generateCmplFlags(cmd, exportCmplCmd.Flags)
+	// Don't complete these global flags as they are ignored by this
+	// command.
+	for _, flg := range []string{"-C", "--chainid",
+		"-I", "--identity", "-T", "--tokenid"} {
+		delete(exportCmplCmd.Flags, flg)
+	}
+	usage := cmd.UsageFunc()
+	cmd.SetUsageFunc(func(cmd *cobra.Command) error {
+		cmd.Flags().MarkHidden("chainid")
+		cmd.Flags().MarkHidden("tokenid")
+		cmd.Flags().MarkHidden("identity")
+		return usage(cmd)
+	})
+	return cmd
+}()
+
+var exportCmplCmd = complete.Command{
+	Flags: mergeFlags(apiCmplFlags),
+	Args:  complete.PredictDirs("*"),
+}
+
+func export(cmd *cobra.Command, args []string) {
+	archive := factom.Archive{Dir: args[0]}
+	if !cmd.Flags().Changed("end") {
+		var heights factom.Heights
+		if err := heights.Get(FactomClient); err != nil {
+			errLog.Fatal(err)
+		}
+		exportEnd = heights.DirectoryBlock
+	}
+	if exportStart > exportEnd {
+		errLog.Fatalf("--start %v is after --end %v", exportStart, exportEnd)
+	}
+
+	x := exporter{Archive: archive,
+		tokenChains: make(map[factom.Bytes32]struct{}),
+		identities:  make(map[factom.Bytes32]struct{})}
+	for h := exportStart; h <= exportEnd; h++ {
+		if err := x.exportDBlock(h); err != nil {
+			errLog.Fatalf("DBlock %v: %v", h, err)
+		}
+		vrbLog.Printf("Exported DBlock %v", h)
+	}
+	fmt.Printf("Exported DBlocks %v to %v with %v token chains to %v\n",
+		exportStart, exportEnd, len(x.tokenChains), archive.Dir)
+}
+
+// exporter exports DBlocks to an Archive, and keeps track of the token chains
+// and identities that have been found.
+type exporter struct {
+	factom.Archive
+	tokenChains map[factom.Bytes32]struct{}
+	identities  map[factom.Bytes32]struct{}
+}
+
+func (x exporter) exportDBlock(height uint32) error {
+	db := factom.DBlock{Header: factom.DBlockHeader{Height: height}}
+	if err := db.Get(FactomClient); err != nil {
+		return err
+	}
+	for _, eb := range db.EBlocks {
+		if err := x.exportEBlock(eb); err != nil {
+			return fmt.Errorf("EBlock{ChainID: %v}: %v", eb.ChainID, err)
+		}
+	}
+	return x.PutDBlock(db)
+}
+
+// exportEBlock exports eb, whose ChainID and KeyMR are from a DBlock, and any
+// of its Entries that fatd may need.
+func (x exporter) exportEBlock(eb factom.EBlock) error {
+	data, err := getRawData(eb.KeyMR)
+	if err != nil {
+		return err
+	}
+	// The Admin, EC and FCT blocks are exported as is.
+	if isSystemChain(eb.ChainID) {
+		return x.PutRaw(*eb.KeyMR, data)
+	}
+	chainID, keyMR := *eb.ChainID, *eb.KeyMR
+	eb.ChainID = &chainID
+	if err := eb.UnmarshalBinary(data); err != nil {
+		return err
+	}
+	if computed, err := eb.ComputeKeyMR(); err != nil || computed != keyMR {
+		return fmt.Errorf("invalid key merkle root")
+	}
+	if err := x.PutEBlockHead(eb, data); err != nil {
+		return err
+	}
+
+	entries := eb.Entries
+	if eb.IsFirst() {
+		first, err := x.exportEntry(eb.Entries[0])
+		if err != nil {
+			return err
+		}
+		if !fat.ValidTokenNameIDs(first.ExtIDs) {
+			return nil
+		}
+		x.tokenChains[chainID] = struct{}{}
+		if err := x.exportIdentity(
+			factom.NewBytes32(first.ExtIDs[3])); err != nil {
+			return fmt.Errorf("Identity: %v", err)
+		}
+		entries = entries[1:]
+	} else if _, ok := x.tokenChains[chainID]; !ok {
+		return nil
+	}
+	for _, e := range entries {
+		if _, err := x.exportEntry(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exportIdentity exports the EBlocks of the Identity Chain with the given id
+// back to its first EBlock, and its first Entry, once.
+func (x exporter) exportIdentity(id *factom.Bytes32) error {
+	if _, ok := x.identities[*id]; ok {
+		return nil
+	}
+	x.identities[*id] = struct{}{}
+	eb := factom.EBlock{ChainID: id}
+	if err := eb.GetChainHead(FactomClient); err != nil {
+		if _, ok := err.(jrpc.Error); ok {
+			// The Identity does not exist yet.
+			return nil
+		}
+		return err
+	}
+	for {
+		keyMR := *eb.KeyMR
+		data, err := getRawData(&keyMR)
+		if err != nil {
+			return err
+		}
+		eb = factom.EBlock{ChainID: id, KeyMR: &keyMR}
+		if err := eb.UnmarshalBinary(data); err != nil {
+			return err
+		}
+		computed, err := eb.ComputeKeyMR()
+		if err != nil || computed != keyMR {
+			return fmt.Errorf("invalid key merkle root")
+		}
+		if err := x.PutEBlockHead(eb, data); err != nil {
+			return err
+		}
+		if eb.IsFirst() {
+			_, err := x.exportEntry(eb.Entries[0])
+			return err
+		}
+		eb.KeyMR = eb.PrevKeyMR
+	}
+}
+
+// exportEntry exports e and returns it populated.
+func (x exporter) exportEntry(e factom.Entry) (factom.Entry, error) {
+	data, err := getRawData(e.Hash)
+	if err != nil {
+		return e, err
+	}
+	if factom.EntryHash(data) != *e.Hash {
+		return e, fmt.Errorf("Entry%v: invalid hash", e.Hash)
+	}
+	if err := e.UnmarshalBinary(data); err != nil {
+		return e, err
+	}
+	return e, x.PutRaw(*e.Hash, data)
+}
+
+// getRawData returns the raw data of the block or Entry with the given hash
+// from factomd.
+func getRawData(hash *factom.Bytes32) ([]byte, error) {
+	params := struct {
+		Hash *factom.Bytes32 `json:"hash"`
+	}{Hash: hash}
+	var result struct {
+		Data factom.Bytes `json:"data"`
+	}
+	if err := FactomClient.FactomdRequest("raw-data", params,
+		&result); err != nil {
+		return nil, err
+	}
+	return result.Data, nil
+}
+
+// isSystemChain returns true for the Admin, EC and FCT block chain IDs.
+func isSystemChain(id *factom.Bytes32) bool {
+	for _, b := range id[:31] {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
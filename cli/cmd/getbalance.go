@@ -34,14 +34,21 @@ import (
 	"github.com/spf13/cobra"
 )
 
-var addresses []factom.FAAddress
+var (
+	addresses     []factom.FAAddress
+	paramsHistory = srv.ParamsHistory{
+		Height:    new(uint32),
+		Timestamp: new(int64),
+	}
+)
 
 // getBalanceCmd represents the balance command
 var getBalanceCmd = func() *cobra.Command {
 	cmd := &cobra.Command{
 		DisableFlagsInUseLine: true,
 		Use: `
-balance [--chainid <chain-id>] ADDRESS...`[1:],
+balance [--chainid <chain-id>] [--height <height> | --timestamp <unix>]
+        ADDRESS...`[1:],
 		Aliases: []string{"balances"},
 		Short:   "Get balances for addresses",
 		Long: `
@@ -49,10 +56,16 @@ Get the balance of each ADDRESS on the given --chainid, if given, otherwise
 return all non-zero total balances.
 
 The list of NF Token IDs for FAT-1 tokens are displayed if --chainid is used.
+
+Use --height or --timestamp to get the balances as of the end of a past DBlock
+height or as of a past Unix timestamp.
 `[1:],
 		Args: getBalanceArgs,
 		PreRunE: func(cmd *cobra.Command, args []string) error {
 			flags := cmd.Flags()
+			if err := validateHistoryFlags(cmd); err != nil {
+				return err
+			}
 			chainIDSet := flags.Changed("chainid")
 			tokenIDSet := flags.Changed("tokenid")
 			identitySet := flags.Changed("identity")
@@ -67,6 +80,13 @@ The list of NF Token IDs for FAT-1 tokens are displayed if --chainid is used.
 	getCmd.AddCommand(cmd)
 	getCmplCmd.Sub["balance"] = getBalanceCmplCmd
 	rootCmplCmd.Sub["help"].Sub["get"].Sub["balance"] = complete.Command{}
+
+	flags := cmd.Flags()
+	flags.Uint32Var(paramsHistory.Height, "height", 0,
+		"Get balances as of the end of this DBlock height")
+	flags.Int64Var(paramsHistory.Timestamp, "timestamp", 0,
+		"Get balances as of this Unix timestamp")
+
 	generateCmplFlags(cmd, getBalanceCmplCmd.Flags)
 	return cmd
 }()
@@ -95,9 +115,26 @@ func getBalanceArgs(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+func validateHistoryFlags(cmd *cobra.Command) error {
+	flags := cmd.LocalFlags()
+	heightSet := flags.Changed("height")
+	timestampSet := flags.Changed("timestamp")
+	if heightSet && timestampSet {
+		return fmt.Errorf("--height is incompatible with --timestamp")
+	}
+	if !heightSet {
+		paramsHistory.Height = nil
+	}
+	if !timestampSet {
+		paramsHistory.Timestamp = nil
+	}
+	return nil
+}
+
 func getBalance(cmd *cobra.Command, _ []string) {
 	if paramsToken.ChainID == nil {
 		var params srv.ParamsGetBalances
+		params.ParamsHistory = paramsHistory
 		vrbLog.Println("Fetching balances for all chains...")
 		for _, adr := range addresses {
 			params.Address = &adr
@@ -128,6 +165,7 @@ func getBalance(cmd *cobra.Command, _ []string) {
 	case fat0.Type:
 		params := srv.ParamsGetBalance{}
 		params.ChainID = paramsToken.ChainID
+		params.ParamsHistory = paramsHistory
 		vrbLog.Println("Fetching balances...")
 		for _, adr := range addresses {
 			params.Address = &adr
@@ -142,6 +180,7 @@ func getBalance(cmd *cobra.Command, _ []string) {
 		var params srv.ParamsGetNFBalance
 		params.Limit = math.MaxUint64
 		params.ChainID = paramsToken.ChainID
+		params.ParamsHistory = paramsHistory
 		vrbLog.Println("Fetching NF balances...")
 		for _, adr := range addresses {
 			params.Address = &adr
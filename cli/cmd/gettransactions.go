@@ -39,6 +39,8 @@ var (
 	paramsGetTxs = srv.ParamsGetTransactions{
 		StartHash:   new(factom.Bytes32),
 		NFTokenID:   new(fat1.NFTokenID),
+		StartHeight: new(uint32),
+		EndHeight:   new(uint32),
 		ParamsToken: srv.ParamsToken{ChainID: paramsToken.ChainID},
 	}
 	to, from       bool
@@ -56,6 +58,7 @@ transactions --chainid <chain-id> TXID...
         [--page <page>] [--limit <limit>] [--order <"asc" | "desc">]
         [--address <FA> [--address <FA>]... [--to] [--from]]
         [--nftokenid <nf-token-id>]
+        [--startheight <height>] [--endheight <height>]
 `[1:],
 		Aliases: []string{"transaction", "txs", "tx"},
 		Short:   "List transactions and their data",
@@ -68,8 +71,9 @@ returned. Only global flags are accepted with TXIDs.
 
 If no TXID is provided, then a paginated list of all transactions is returned.
 The list can be scoped down to transactions --to or --from one --address or
-more, and in the case of a FAT-1 chain, by a single --nftokenid. Use --page and
---limit to scroll through transactions.
+more, and in the case of a FAT-1 chain, by a single --nftokenid. Use
+--startheight and --endheight to list only the txs confirmed within a range of
+DBlock heights. Use --page and --limit to scroll through transactions.
 `[1:],
 		Args:    getTxsArgs,
 		PreRunE: validateGetTxsFlags,
@@ -92,6 +96,10 @@ more, and in the case of a FAT-1 chain, by a single --nftokenid. Use --page and
 		"Request only txs involving this NF Token ID")
 	flags.VarPF((*FAAddressList)(&paramsGetTxs.Addresses), "address", "a",
 		"Add to the set of addresses to lookup txs for").DefValue = ""
+	flags.Uint32Var(paramsGetTxs.StartHeight, "startheight", 0,
+		"Request only txs confirmed at or after this DBlock height")
+	flags.Uint32Var(paramsGetTxs.EndHeight, "endheight", 0,
+		"Request only txs confirmed at or before this DBlock height")
 
 	generateCmplFlags(cmd, getTxsCmplCmd.Flags)
 	return cmd
@@ -130,7 +138,8 @@ func validateGetTxsFlags(cmd *cobra.Command, args []string) error {
 	flags := cmd.LocalFlags()
 	if len(transactionIDs) > 0 {
 		for _, flgName := range []string{"page", "order", "page", "limit",
-			"starttxhash", "to", "from", "nftokenid", "address"} {
+			"starttxhash", "to", "from", "nftokenid", "address",
+			"startheight", "endheight"} {
 			if flags.Changed(flgName) {
 				return fmt.Errorf("--%v is incompatible with TXID arguments",
 					flgName)
@@ -161,6 +170,13 @@ func validateGetTxsFlags(cmd *cobra.Command, args []string) error {
 		paramsGetTxs.NFTokenID = nil
 	}
 
+	if !flags.Changed("startheight") {
+		paramsGetTxs.StartHeight = nil
+	}
+	if !flags.Changed("endheight") {
+		paramsGetTxs.EndHeight = nil
+	}
+
 	return nil
 }
 
@@ -182,11 +198,9 @@ func getTxs(_ *cobra.Command, _ []string) {
 		return
 	}
 	params := srv.ParamsGetTransaction{ParamsToken: paramsGetTxs.ParamsToken}
-	result := srv.ResultGetTransaction{}
-	tx := json.RawMessage{}
-	result.Tx = &tx
 	for _, txID := range transactionIDs {
 		vrbLog.Printf("Fetching tx details... %v", txID)
+		result := srv.ResultGetTransaction{Tx: &json.RawMessage{}}
 		params.Hash = &txID
 		if err := FATClient.Request("get-transaction",
 			params, &result); err != nil {
@@ -200,6 +214,14 @@ func getTxs(_ *cobra.Command, _ []string) {
 func printTx(result srv.ResultGetTransaction) {
 	fmt.Println("TXID:", result.Hash)
 	fmt.Println("Timestamp:", result.Timestamp)
+	if result.Height > 0 {
+		fmt.Println("Height:", result.Height)
+	}
+	if result.EBlockKeyMR != nil {
+		fmt.Println("EBlock KeyMR:", result.EBlockKeyMR)
+		fmt.Println("EBlock Sequence:", *result.EBlockSequence)
+		fmt.Println("Entry Index:", *result.EntryIndex)
+	}
 	fmt.Println("TX:", (string)(*result.Tx.(*json.RawMessage)))
 	fmt.Println()
 }
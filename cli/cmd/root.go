@@ -88,6 +88,13 @@ func initClients() {
 		addHTTPScheme(url)
 	}
 
+	FATClient.SetToken(fatdToken)
+	if fatdTLS.IsSet() {
+		if err := FATClient.SetTLS(fatdTLS); err != nil {
+			errLog.Fatalf("--fatdcert: %v", err)
+		}
+	}
+
 	if Verbose {
 		vrbLog = errLog
 	}
@@ -110,6 +117,9 @@ var (
 	FATClient    = srv.NewClient()
 	FactomClient = factom.NewClient()
 
+	fatdToken string
+	fatdTLS   factom.TLSOptions
+
 	Debug           bool
 	DebugCompletion bool
 
@@ -146,6 +156,13 @@ var apiFlags = func() *flag.FlagSet {
 	flags.StringVar(&FactomClient.Walletd.Password, "walletdpass", "",
 		"Basic HTTP Auth Password for factom-walletd")
 
+	flags.StringVar(&fatdToken, "fatdtoken", "",
+		"Bearer token for fatd, instead of Basic HTTP Auth")
+	flags.StringVar(&fatdTLS.CAFile, "fatdcert", "",
+		"PEM CA certificates to verify fatd's TLS certificate")
+	flags.StringVar(&fatdTLS.ServerName, "fatdservername", "",
+		"Host name to verify fatd's TLS certificate against")
+
 	flags.DurationVar(&FATClient.Timeout, "timeout", 3*time.Second,
 		"Timeout for all API requests (i.e. 10s, 1m)")
 
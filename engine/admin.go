@@ -0,0 +1,134 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package engine
+
+import (
+	"fmt"
+
+	"github.com/Factom-Asset-Tokens/fatd/factom"
+	"github.com/Factom-Asset-Tokens/fatd/state"
+)
+
+// adminRequest is an operation on state requested through the admin API. It
+// is run by the engine goroutine the next time it is not processing a DBlock,
+// so that it never runs concurrently with state.Process.
+type adminRequest struct {
+	run func() error
+	err chan error
+}
+
+var (
+	adminRequests = make(chan adminRequest)
+	// engineDone is closed when the engine exits.
+	engineDone <-chan struct{}
+)
+
+// do runs f on the engine goroutine and returns its error.
+func do(f func() error) error {
+	if engineDone == nil {
+		return fmt.Errorf("engine not started")
+	}
+	req := adminRequest{run: f, err: make(chan error, 1)}
+	select {
+	case adminRequests <- req:
+	case <-engineDone:
+		return fmt.Errorf("engine stopped")
+	}
+	return <-req.err
+}
+
+// handleAdminRequest runs req and returns its error to the requester.
+func handleAdminRequest(req adminRequest) {
+	req.err <- req.run()
+}
+
+// TrackChain removes the chain with the given id from the blacklist, adds it
+// to the whitelist if there is one, and backfills it up to the sync height.
+func TrackChain(id factom.Bytes32) error {
+	return do(func() error {
+		state.AllowChain(id)
+		state.ApplyChainFilter()
+		if syncHeight+1 == 0 {
+			// Syncing starts at 0, so there is nothing to backfill.
+			return nil
+		}
+		return backfill(id, syncHeight)
+	})
+}
+
+// UntrackChain adds the chain with the given id to the blacklist and stops
+// tracking it. Its database is closed but not deleted.
+func UntrackChain(id factom.Bytes32) error {
+	return do(func() error {
+		state.DisallowChain(id)
+		state.ApplyChainFilter()
+		return nil
+	})
+}
+
+// ResyncChain rewinds the tracked or faulted chain with the given id to height
+// and then resyncs it up to the sync height. A faulted chain is recovered as
+// when it is retried, and is faulted again if resyncing fails.
+func ResyncChain(id factom.Bytes32, height uint32) error {
+	return do(func() error {
+		if syncHeight+1 == 0 {
+			return fmt.Errorf("no DBlocks have been synced")
+		}
+		if height > syncHeight {
+			return fmt.Errorf("height %v is after the sync height %v",
+				height, syncHeight)
+		}
+		faulted := state.Chains.Get(&id).IsFaulted()
+		if err := state.Rewind(id, height); err != nil {
+			if faulted {
+				state.FaultChain(id, err)
+			}
+			return err
+		}
+		return backfill(id, syncHeight)
+	})
+}
+
+// ValidateChain performs a full integrity check of the tracked or faulted chain
+// with the given id, and optionally repairs it. See state.Chain.Validate. A
+// faulted chain is then recovered by syncing it up to the sync height, and is
+// faulted again if that fails.
+func ValidateChain(id factom.Bytes32, repair bool) ([]string, error) {
+	var mismatches []string
+	err := do(func() error {
+		faulted := state.Chains.Get(&id).IsFaulted()
+		var err error
+		mismatches, err = state.ValidateChain(id, repair)
+		if err != nil {
+			if faulted {
+				state.FaultChain(id, err)
+			}
+			return err
+		}
+		if !faulted || syncHeight+1 == 0 {
+			return nil
+		}
+		return backfill(id, syncHeight)
+	})
+	return mismatches, err
+}
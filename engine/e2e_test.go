@@ -0,0 +1,202 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package engine_test
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Factom-Asset-Tokens/fatd/engine"
+	"github.com/Factom-Asset-Tokens/fatd/factom"
+	"github.com/Factom-Asset-Tokens/fatd/factom/factomtest"
+	"github.com/Factom-Asset-Tokens/fatd/fat"
+	"github.com/Factom-Asset-Tokens/fatd/fat/fat0"
+	"github.com/Factom-Asset-Tokens/fatd/flag"
+	"github.com/Factom-Asset-Tokens/fatd/srv"
+)
+
+// setupFatd points fatd at new factomd and walletd servers for l and wd, a
+// temporary database and a free API port, and starts the engine and the API
+// server. It returns a Client for the API and a function that stops
+// everything and restores the flags.
+func setupFatd(t *testing.T, l *factomtest.Ledger,
+	wd *factomtest.Walletd, es factom.EsAddress) (*srv.Client, func()) {
+	dbPath, err := ioutil.TempDir("", "fatd-e2e-test")
+	require.NoError(t, err)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	apiAddress := lis.Addr().String()
+	require.NoError(t, lis.Close())
+
+	factomd := httptest.NewServer(l)
+	walletd := httptest.NewServer(wd)
+	c := flag.FactomClient
+	prevFactomd, prevWalletd := c.FactomdServer, c.WalletdServer
+	prevDBPath, prevAPIAddress := flag.DBPath, flag.APIAddress
+	prevEsAdr, prevECAdr := flag.EsAdr, flag.ECAdr
+	c.FactomdServer, c.WalletdServer = factomd.URL, walletd.URL
+	flag.DBPath, flag.APIAddress = dbPath, apiAddress
+	flag.EsAdr, flag.ECAdr = es, es.ECAddress()
+	restoreIntervals := engine.SetIntervals(
+		100*time.Millisecond, 100*time.Millisecond)
+
+	stopEngine, stopSrv := make(chan struct{}), make(chan struct{})
+	engineDone := engine.Start(stopEngine)
+	srvDone := srv.Start(stopSrv)
+
+	client := srv.NewClient()
+	client.FatdServer = "http://" + apiAddress
+	return client, func() {
+		// Neither should have exited before being stopped.
+		select {
+		case <-engineDone:
+			t.Error("engine exited early")
+		case <-srvDone:
+			t.Error("srv exited early")
+		default:
+		}
+		close(stopSrv)
+		<-srvDone
+		close(stopEngine)
+		<-engineDone
+
+		restoreIntervals()
+		flag.EsAdr, flag.ECAdr = prevEsAdr, prevECAdr
+		flag.DBPath, flag.APIAddress = prevDBPath, prevAPIAddress
+		c.FactomdServer, c.WalletdServer = prevFactomd, prevWalletd
+		factomd.Close()
+		walletd.Close()
+		os.RemoveAll(dbPath)
+	}
+}
+
+// waitForSync waits until the engine has synced to height.
+func waitForSync(t *testing.T, height uint32) {
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		sync, _ := engine.GetSyncStatus()
+		if sync == height {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for sync height %v, at %v",
+				height, sync)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestEndToEnd(t *testing.T) {
+	require := require.New(t)
+	sk1, err := factom.GenerateSK1Key()
+	require.NoError(err)
+	es, err := factom.GenerateEsAddress()
+	require.NoError(err)
+	adrs := make([]factom.FsAddress, 2)
+	for i := range adrs {
+		adrs[i], err = factom.GenerateFsAddress()
+		require.NoError(err)
+	}
+
+	// Issue a FAT-0 token and mint 100 tokens to adrs[0].
+	l := factomtest.NewLedger()
+	l.SetECBalance(es.ECAddress(), 1000)
+	identity, err := l.AddIdentity(sk1.ID1Key())
+	require.NoError(err)
+	chainID, err := l.AddTokenChain("test", identity)
+	require.NoError(err)
+	issuance := fat.NewIssuance(factom.Entry{ChainID: &chainID})
+	issuance.Type = fat0.Type
+	issuance.Supply = -1
+	_, err = l.AddIssuance(issuance, sk1)
+	require.NoError(err)
+	_, err = l.NewDBlock()
+	require.NoError(err)
+
+	coinbase := fat0.NewTransaction(factom.Entry{ChainID: &chainID})
+	coinbase.Inputs = fat0.AddressAmountMap{fat.Coinbase(): 100}
+	coinbase.Outputs = fat0.AddressAmountMap{adrs[0].FAAddress(): 100}
+	_, err = l.AddFAT0Transaction(coinbase, sk1)
+	require.NoError(err)
+	_, err = l.NewDBlock()
+	require.NoError(err)
+
+	client, teardown := setupFatd(t, l, factomtest.NewWalletd(), es)
+	defer teardown()
+	waitForSync(t, 2)
+
+	token := srv.ParamsToken{ChainID: &chainID}
+	var result srv.ResultGetIssuance
+	require.NoError(client.Request("get-issuance", token, &result))
+	assert.Equal(t, "test", result.TokenID)
+	assert.Equal(t, fat0.Type, result.Issuance.Type)
+
+	balance := func(adr factom.FsAddress) uint64 {
+		fa := adr.FAAddress()
+		var balance uint64
+		require.NoError(client.Request("get-balance",
+			srv.ParamsGetBalance{ParamsToken: token, Address: &fa},
+			&balance))
+		return balance
+	}
+	assert.Equal(t, uint64(100), balance(adrs[0]))
+
+	// Send a transaction through the API, which commits and reveals it
+	// to factomd.
+	tx := fat0.NewTransaction(factom.Entry{ChainID: &chainID})
+	tx.Inputs = fat0.AddressAmountMap{adrs[0].FAAddress(): 40}
+	tx.Outputs = fat0.AddressAmountMap{adrs[1].FAAddress(): 40}
+	require.NoError(tx.MarshalEntry())
+	tx.Sign(adrs[0])
+	var sent struct {
+		Hash *factom.Bytes32 `json:"entryhash"`
+	}
+	require.NoError(client.Request("send-transaction",
+		srv.ParamsSendTransaction{ParamsToken: token,
+			ExtIDs: tx.ExtIDs, Content: tx.Content}, &sent))
+	pending := l.PendingEntries()
+	require.Len(pending, 1)
+	assert.Equal(t, *sent.Hash, *pending[0].Hash)
+	cost, err := tx.Cost()
+	require.NoError(err)
+	assert.Equal(t, 1000-uint64(cost), l.ECBalance(es.ECAddress()))
+
+	// The transaction is confirmed by the next DBlock.
+	_, err = l.NewDBlock()
+	require.NoError(err)
+	waitForSync(t, 3)
+	assert.Equal(t, uint64(60), balance(adrs[0]))
+	assert.Equal(t, uint64(40), balance(adrs[1]))
+	var confirmed srv.ResultGetTransaction
+	require.NoError(client.Request("get-transaction",
+		srv.ParamsGetTransaction{ParamsToken: token, Hash: sent.Hash},
+		&confirmed))
+	assert.Equal(t, *sent.Hash, *confirmed.Hash)
+}
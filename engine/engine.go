@@ -23,8 +23,15 @@
 // Package engine manages syncing with the Factom blockchain and updating
 // state. Start launches a number of goroutines: one to query for DBlocks
 // sequentially, and a number of workers to concurrently process EBlocks within
-// a DBlock and update state. If any runtime errors occur, engine finishes
-// processing the current set of EBlocks and then exits. See Start for more
+// a DBlock and update state.
+//
+// If a token chain fails to sync, only that chain is faulted. Faulted chains
+// are no longer processed and are retried between DBlocks with exponential
+// backoff, using state.RetryFaulted, so that one chain cannot stop the others
+// from syncing. Failing to get the current Factom height is retried every scan
+// interval, up to the factomscanretries limit. Any other error, such as failing
+// to get or verify a DBlock or to save the sync height, stops the engine after
+// it finishes processing the current set of EBlocks. See Start for more
 // details.
 package engine
 
@@ -33,6 +40,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/Factom-Asset-Tokens/fatd/events"
 	"github.com/Factom-Asset-Tokens/fatd/factom"
 	"github.com/Factom-Asset-Tokens/fatd/flag"
 	_log "github.com/Factom-Asset-Tokens/fatd/log"
@@ -44,8 +52,10 @@ var (
 	c   = flag.FactomClient
 )
 
-const (
-	scanInterval = 15 * time.Second
+// These are variables so that tests may shorten them.
+var (
+	scanInterval    = 15 * time.Second
+	pendingInterval = 5 * time.Second
 )
 
 // Start launches the main engine goroutine, which loads state and starts the
@@ -54,7 +64,9 @@ const (
 // goroutines will exit, and done will be closed. If the done channel is closed
 // before the stop channel is closed, an error occurred.
 func Start(stop <-chan struct{}) (done <-chan struct{}) {
+	c.FactomdRequestHook = observeFactomdRequest
 	_done := make(chan struct{})
+	engineDone = _done
 	go engine(stop, _done)
 	return _done
 }
@@ -67,16 +79,24 @@ func engine(stop <-chan struct{}, done chan struct{}) {
 
 	log = _log.New("engine")
 
-	if err := state.Load(); err != nil {
+	if err := updateFactomHeight(); err != nil {
 		log.Error(err)
 		return
 	}
-	// Set up sync and factom heights...
-	setSyncHeight(state.SavedHeight)
-	if err := updateFactomHeight(); err != nil {
+	networkID, err := getNetworkID()
+	if err != nil {
 		log.Error(err)
 		return
 	}
+	log.Infof("Network ID: %v", networkID)
+	state.NetworkID = networkID
+
+	if err := state.Load(); err != nil {
+		log.Error(err)
+		return
+	}
+	// Set up sync height...
+	setSyncHeight(state.SavedHeight)
 
 	// Guard against syncing against a network with an earlier blockheight.
 	if syncHeight > factomHeight {
@@ -101,19 +121,9 @@ func engine(stop <-chan struct{}, done chan struct{}) {
 		// value until the first scan loop.
 		setSyncHeight(uint32(flag.StartScanHeight - 1))
 	} else if syncHeight == 0 { // else if the syncHeight has not been set...
-		const mainnetStart = 163180
-		const testnetStart = 60000
-		// This is a hacky, unreliable way to determine what network we
-		// are on. This needs to be replaced with using the actually
-		// Network ID.
-		if factomHeight > mainnetStart {
-			setSyncHeight(mainnetStart) // Set for mainnet
-		} else if factomHeight > testnetStart {
-			setSyncHeight(testnetStart) // Set for testnet
-		} else {
-			var zero uint32         // Avoid constant overflow compile error.
-			setSyncHeight(zero - 1) // Start scan at 0.
-		}
+		// We start syncing at syncHeight+1, so subtract one. This
+		// overflows for 0, which is OK for the same reason as above.
+		setSyncHeight(activationHeight(networkID) - 1)
 	}
 
 	wg := &sync.WaitGroup{}
@@ -136,6 +146,34 @@ func engine(stop <-chan struct{}, done chan struct{}) {
 		}()
 	}
 
+	if flag.FastSync {
+		if err := fastSync(); err != nil {
+			log.Error(err)
+			return
+		}
+	}
+
+	// Backfill any whitelisted chains that were created before the sync
+	// height.
+	applyChainFilter()
+
+	// With -verifysync, each new DBlock must link back to the DBlock at the
+	// sync height.
+	var prev factom.DBlock
+	if flag.VerifySync && syncHeight+1 != 0 {
+		prev.Header.Height = syncHeight
+		if err := prev.Get(c); err != nil {
+			log.Errorf("DBlock(%v).Get(c): %v", syncHeight, err)
+			return
+		}
+		if err := prev.VerifyFullHash(); err != nil {
+			log.Error(err)
+			return
+		}
+		log.Infof("Verifying DBlocks from DBlock %v, KeyMR: %v",
+			syncHeight, prev.KeyMR)
+	}
+
 	log.Infof("Syncing from block %v to %v...", syncHeight+1, factomHeight)
 	var synced bool
 	var retries int64
@@ -147,15 +185,37 @@ func engine(stop <-chan struct{}, done chan struct{}) {
 			log.Infof("Synced.")
 		}
 
-		// Process all new DBlocks sequentially...
+		// Process all new DBlocks sequentially, while prefetching
+		// upcoming DBlocks concurrently...
+		dblocks := prefetch(syncHeight+1, factomHeight,
+			int(flag.FetchWindow), int(flag.FetchWorkers), done)
 		for h := syncHeight + 1; h <= factomHeight; h++ {
+			start := time.Now()
+
 			// Get DBlock.
-			var dblock factom.DBlock
-			dblock.Header.Height = h
-			if err := dblock.Get(c); err != nil {
-				log.Errorf("%#v.Get(c): %v", dblock, err)
+			dblock, err := dblocks.Next()
+			if err != nil {
+				log.Errorf("DBlock(%v).Get(c): %v", h, err)
+				return
+			}
+			if dblock.Header.NetworkID != networkID {
+				log.Errorf("DBlock %v is for network %v, "+
+					"expected %v", h,
+					dblock.Header.NetworkID, networkID)
 				return
 			}
+			if flag.VerifySync {
+				if err := verifyDBlock(prev, dblock); err != nil {
+					log.Error(err)
+					return
+				}
+				prev = factom.DBlock{KeyMR: dblock.KeyMR,
+					FullHash: dblock.FullHash,
+					Header:   dblock.Header}
+			}
+			if synced {
+				checkDBlockKeyMR(h)
+			}
 
 			// Queue all EBlocks for processing and wait.
 			wg.Add(len(dblock.EBlocks))
@@ -178,24 +238,33 @@ func engine(stop <-chan struct{}, done chan struct{}) {
 				log.Errorf("state.SaveHeight(%v): %v", h, err)
 				return
 			}
+			events.Publish(events.SyncHeight{Height: h})
+			dblockSeconds.ObserveDuration(start)
 
-			// Check that we haven't been told to stop.
+			// Check that we haven't been told to stop, and apply
+			// any changes to the chain filter or admin requests.
 			select {
 			case <-stop:
 				return
+			case <-chainFilterChanged:
+				applyChainFilter()
+			case req := <-adminRequests:
+				handleAdminRequest(req)
 			default:
 			}
 
+			// Retry any faulted chains that are due.
+			state.RetryFaulted(h)
+
 			if flag.LogDebug && h%100 == 0 {
 				log.Debugf("Synced to block %v...", h)
 			}
 		}
 
 		if synced {
-			// Wait until the next scan tick or we're told to stop.
-			select {
-			case <-scanTicker.C:
-			case <-stop:
+			// Track pending transactions until the next scan tick or
+			// we're told to stop.
+			if !trackPending(scanTicker.C, stop) {
 				return
 			}
 		}
@@ -214,6 +283,181 @@ func engine(stop <-chan struct{}, done chan struct{}) {
 	}
 }
 
+// verifyDBlock returns an error if dblock does not directly follow prev, or if
+// any of its populated EBlocks do not match it. If prev has no KeyMR, dblock
+// must be the first DBlock.
+func verifyDBlock(prev, dblock factom.DBlock) error {
+	if err := dblock.VerifyFullHash(); err != nil {
+		return err
+	}
+	if prev.KeyMR != nil {
+		if err := dblock.VerifyPrev(prev); err != nil {
+			return err
+		}
+	} else if dblock.Header.Height != 0 {
+		return fmt.Errorf("DBlock %v: no previous DBlock to verify "+
+			"against", dblock.Header.Height)
+	}
+	for _, eb := range dblock.EBlocks {
+		if !eb.IsPopulated() {
+			// EBlock.Get verifies the KeyMR from the DBlock
+			// when the EBlock is processed.
+			continue
+		}
+		if err := eb.VerifyDBlock(dblock); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkDBlockKeyMR reports if the factomd servers, when there is more than
+// one, disagree on the KeyMR of the DBlock at height.
+func checkDBlockKeyMR(height uint32) {
+	if len(c.FactomdServers()) < 2 {
+		return
+	}
+	if err := c.CheckDBlockKeyMR(height); err != nil {
+		log.Error(err)
+		dblockKeyMRMismatches.Inc()
+	}
+}
+
+var chainFilterChanged = make(chan struct{}, 1)
+
+// ChainFilterChanged tells the engine to apply the current state chain filter
+// the next time it is not processing a DBlock. Chains that are no longer
+// allowed are no longer tracked, and newly whitelisted chains are backfilled.
+func ChainFilterChanged() {
+	select {
+	case chainFilterChanged <- struct{}{}:
+	default: // A change is already pending.
+	}
+}
+
+// applyChainFilter stops tracking chains that are no longer allowed and
+// backfills any untracked whitelisted chains up to the sync height.
+func applyChainFilter() {
+	untracked := state.ApplyChainFilter()
+	if syncHeight+1 == 0 {
+		// Syncing starts at 0, so there is nothing to backfill.
+		return
+	}
+	for _, id := range untracked {
+		backfill(id, syncHeight)
+	}
+}
+
+// backfill calls state.Backfill and faults the chain if it fails, so that it
+// is retried later without stopping the engine.
+func backfill(id factom.Bytes32, height uint32) error {
+	if err := state.Backfill(id, height); err != nil {
+		err = fmt.Errorf("state.Backfill(): %v", err)
+		state.FaultChain(id, err)
+		return err
+	}
+	return nil
+}
+
+// fastSync syncs all whitelisted chains up to the current factomHeight by
+// walking back through their EBlocks, and then advances the syncHeight to the
+// factomHeight, so that DBlock syncing only needs to resume from there. Since
+// only whitelisted chains may be tracked, no chains are missed by skipping
+// over the DBlocks in between.
+func fastSync() error {
+	whitelist, _ := state.GetChainFilter()
+	if len(whitelist) == 0 {
+		return fmt.Errorf("-fastsync requires -whitelist")
+	}
+	state.ApplyChainFilter()
+	log.Infof("Fast syncing %v whitelisted chains to block %v...",
+		len(whitelist), factomHeight)
+	for _, id := range whitelist {
+		id := id
+		if !state.IsAllowed(&id) {
+			continue
+		}
+		backfill(id, factomHeight)
+	}
+	if err := state.SaveHeight(factomHeight); err != nil {
+		return fmt.Errorf("state.SaveHeight(%v): %v", factomHeight, err)
+	}
+	setSyncHeight(factomHeight)
+	events.Publish(events.SyncHeight{Height: factomHeight})
+	return nil
+}
+
+// trackPending updates the pending transactions of all issued chains
+// immediately and then every pendingInterval until scan is received from, in
+// which case it returns true, or stop is closed, in which case it returns
+// false. Failures to update the pending transactions are logged but are not
+// fatal.
+func trackPending(scan <-chan time.Time, stop <-chan struct{}) bool {
+	pendingTicker := time.NewTicker(pendingInterval)
+	defer pendingTicker.Stop()
+	for {
+		var pe factom.PendingEntries
+		if err := pe.Get(c); err != nil {
+			log.Errorf("factom.PendingEntries.Get(c): %v", err)
+		} else if err := state.ProcessPending(pe); err != nil {
+			log.Errorf("state.ProcessPending(): %v", err)
+		}
+		select {
+		case <-pendingTicker.C:
+			state.RetryFaulted(syncHeight)
+		case <-chainFilterChanged:
+			applyChainFilter()
+		case req := <-adminRequests:
+			handleAdminRequest(req)
+		case <-scan:
+			return true
+		case <-stop:
+			return false
+		}
+	}
+}
+
+// FAT activation heights of the known networks. No FAT chains exist prior to
+// these heights.
+const (
+	mainnetActivation = 163181
+	testnetActivation = 60001
+)
+
+// activationHeight returns the -activationheight, if set, or the FAT
+// activation height of networkID, which is 0 for custom networks.
+func activationHeight(networkID factom.NetworkID) uint32 {
+	if flag.ActivationHeight > -1 {
+		return uint32(flag.ActivationHeight)
+	}
+	switch {
+	case networkID.IsMainnet():
+		return mainnetActivation
+	case networkID.IsTestnet():
+		return testnetActivation
+	}
+	return 0
+}
+
+// getNetworkID returns the network ID of the current DBlock. An error is
+// returned if -networkid is set and factomd is on a different network.
+func getNetworkID() (factom.NetworkID, error) {
+	var dblock factom.DBlock
+	dblock.Header.Height = factomHeight
+	if err := dblock.Get(c); err != nil {
+		return factom.NetworkID{},
+			fmt.Errorf("%#v.Get(c): %v", dblock, err)
+	}
+	networkID := dblock.Header.NetworkID
+	var zero factom.NetworkID
+	if flag.FactomNetworkID != zero && flag.FactomNetworkID != networkID {
+		return factom.NetworkID{}, fmt.Errorf(
+			"factomd is on network %v, expected -networkid %v",
+			networkID, flag.FactomNetworkID)
+	}
+	return networkID, nil
+}
+
 var (
 	syncHeight, factomHeight uint32
 	heightMtx                = &sync.RWMutex{}
@@ -0,0 +1,75 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package engine
+
+import (
+	"time"
+
+	"github.com/Factom-Asset-Tokens/fatd/metrics"
+)
+
+var (
+	dblockSeconds = metrics.NewHistogram("fatd_dblock_process_seconds",
+		"Time taken to process a DBlock.",
+		[]float64{.01, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60})
+
+	factomdRequestSeconds = metrics.NewHistogram(
+		"fatd_factomd_request_seconds",
+		"Latency of requests to factomd by method.",
+		metrics.DefBuckets, "method")
+	factomdRequestErrors = metrics.NewCounter(
+		"fatd_factomd_request_errors_total",
+		"Number of failed requests to factomd by method.", "method")
+	dblockKeyMRMismatches = metrics.NewCounter(
+		"fatd_dblock_keymr_mismatches_total",
+		"Number of DBlocks on which the factomd servers disagreed.")
+)
+
+func init() {
+	metrics.NewGaugeFunc("fatd_sync_height",
+		"Height of the last DBlock that was synced.",
+		func() float64 {
+			sync, _ := GetSyncStatus()
+			return float64(sync)
+		})
+	metrics.NewGaugeFunc("fatd_factom_height",
+		"Current height of the Factom Blockchain.",
+		func() float64 {
+			_, current := GetSyncStatus()
+			return float64(current)
+		})
+	metrics.NewGaugeFunc("fatd_factomd_cache_hits",
+		"Number of DBlocks, EBlocks and Entries served from the cache.",
+		func() float64 { return float64(c.CacheStats().Hits) })
+	metrics.NewGaugeFunc("fatd_factomd_cache_misses",
+		"Number of DBlocks, EBlocks and Entries requested from factomd "+
+			"because they were not in the cache.",
+		func() float64 { return float64(c.CacheStats().Misses) })
+}
+
+func observeFactomdRequest(method string, d time.Duration, err error) {
+	factomdRequestSeconds.Observe(d.Seconds(), method)
+	if err != nil {
+		factomdRequestErrors.Inc(method)
+	}
+}
@@ -0,0 +1,144 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package engine
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/Factom-Asset-Tokens/fatd/factom"
+	"github.com/Factom-Asset-Tokens/fatd/fat"
+	"github.com/Factom-Asset-Tokens/fatd/state"
+)
+
+// prefetched is a DBlock along with any error that occurred while fetching it.
+type prefetched struct {
+	DBlock factom.DBlock
+	Err    error
+}
+
+// prefetcher downloads the DBlocks for a range of heights, along with the
+// EBlocks and Entries that state.Process will need, ahead of the DBlock that
+// is being processed.
+type prefetcher struct {
+	futures chan chan prefetched
+}
+
+// prefetch starts fetching the DBlocks from start to end, inclusive. Up to
+// window DBlocks are fetched concurrently, and at most workers requests are
+// made to factomd at once. If done is closed, no further DBlocks are fetched.
+func prefetch(start, end uint32, window, workers int,
+	done <-chan struct{}) prefetcher {
+	p := prefetcher{futures: make(chan chan prefetched, window-1)}
+	requests := make(chan struct{}, workers)
+	go func() {
+		defer close(p.futures)
+		for h := start; h <= end; h++ {
+			future := make(chan prefetched, 1)
+			select {
+			case p.futures <- future:
+			case <-done:
+				return
+			}
+			go func(h uint32) {
+				var dblock factom.DBlock
+				dblock.Header.Height = h
+				err := fetchDBlock(&dblock, requests)
+				future <- prefetched{DBlock: dblock, Err: err}
+			}(h)
+		}
+	}()
+	return p
+}
+
+// Next returns the next DBlock in height order, blocking until it has been
+// fetched.
+func (p prefetcher) Next() (factom.DBlock, error) {
+	future, ok := <-p.futures
+	if !ok {
+		return factom.DBlock{}, fmt.Errorf("prefetch stopped")
+	}
+	fetched := <-future
+	return fetched.DBlock, fetched.Err
+}
+
+// fetchDBlock populates dblock and then concurrently populates its EBlocks and
+// the Entries that may be needed to process them. Only a failure to populate
+// dblock is returned. EBlocks and Entries that fail to be populated are left
+// for state.Process to retry, which will report any error.
+func fetchDBlock(dblock *factom.DBlock, requests chan struct{}) error {
+	if err := request(requests, dblock.Get); err != nil {
+		return err
+	}
+	var wg sync.WaitGroup
+	for i := range dblock.EBlocks {
+		eb := &dblock.EBlocks[i]
+		chain := state.Chains.Get(eb.ChainID)
+		if chain.IsIgnored() || !state.IsAllowed(eb.ChainID) {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fetchEBlock(eb, chain.IsTracked(), requests)
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+// fetchEBlock populates eb and then concurrently populates its Entries if the
+// chain is tracked or if eb is the first EBlock of a new token chain.
+func fetchEBlock(eb *factom.EBlock, tracked bool, requests chan struct{}) {
+	if err := request(requests, eb.Get); err != nil {
+		return
+	}
+	entries := eb.Entries
+	if eb.IsFirst() {
+		first := &eb.Entries[0]
+		if err := request(requests, first.Get); err != nil ||
+			!fat.ValidTokenNameIDs(first.ExtIDs) {
+			return
+		}
+		entries = entries[1:]
+	} else if !tracked {
+		return
+	}
+	var wg sync.WaitGroup
+	wg.Add(len(entries))
+	for i := range entries {
+		e := &entries[i]
+		go func() {
+			defer wg.Done()
+			request(requests, e.Get)
+		}()
+	}
+	wg.Wait()
+}
+
+// request calls get with c once a slot in requests is available.
+func request(requests chan struct{}, get func(*factom.Client) error) error {
+	requests <- struct{}{}
+	defer func() { <-requests }()
+	return get(c)
+}
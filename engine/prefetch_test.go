@@ -0,0 +1,225 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Factom-Asset-Tokens/fatd/factom"
+	"github.com/Factom-Asset-Tokens/fatd/fat"
+)
+
+// mockFactomd serves the DBlocks, EBlocks and Entries of a simulated
+// blockchain in which every DBlock creates new token chains. Each request is
+// delayed by latency to simulate the round trip to factomd.
+type mockFactomd struct {
+	dblocks  []factom.DBlock
+	rawData  map[factom.Bytes32][]byte
+	latency  time.Duration
+	requests int64
+}
+
+func newMockFactomd(t testing.TB, heights, chains, entries int,
+	latency time.Duration) *mockFactomd {
+	m := &mockFactomd{rawData: make(map[factom.Bytes32][]byte),
+		latency: latency}
+	identity := factom.Bytes32{0x88, 0x88, 0x88}
+	ts := time.Unix(1500000000, 0)
+	var prevKeyMR, prevFullHash factom.Bytes32
+	for h := 1; h <= heights; h++ {
+		dblock := factom.DBlock{Header: factom.DBlockHeader{
+			NetworkID:    factom.MainnetID,
+			Height:       uint32(h),
+			PrevKeyMR:    factom.NewBytes32(prevKeyMR[:]),
+			PrevFullHash: factom.NewBytes32(prevFullHash[:]),
+			Timestamp:    ts.Add(time.Duration(h) * 10 * time.Minute),
+		}}
+		for i := 0; i < chains; i++ {
+			tokenID := fmt.Sprintf("token-%v-%v", h, i)
+			nameIDs := fat.NameIDs(tokenID, identity)
+			chainID := factom.ChainID(nameIDs)
+			eb := factom.EBlock{
+				ChainID:      &chainID,
+				PrevKeyMR:    new(factom.Bytes32),
+				PrevFullHash: new(factom.Bytes32),
+				Height:       uint32(h),
+				Timestamp:    dblock.Header.Timestamp,
+			}
+			for j := 0; j < entries; j++ {
+				e := factom.Entry{ChainID: &chainID,
+					Content: factom.Bytes(fmt.Sprint(j)),
+					ExtIDs:  []factom.Bytes{}}
+				if j == 0 {
+					e.ExtIDs = nameIDs
+				}
+				data, err := e.MarshalBinary()
+				require.NoError(t, err)
+				hash := factom.EntryHash(data)
+				e.Hash = &hash
+				e.Timestamp = eb.Timestamp
+				m.rawData[hash] = data
+				eb.Entries = append(eb.Entries, e)
+			}
+			// All entries are in the first minute, so there is
+			// only the one trailing minute marker.
+			eb.ObjectCount = uint32(len(eb.Entries) + 1)
+			bodyMR, err := eb.ComputeBodyMR()
+			require.NoError(t, err)
+			eb.BodyMR = &bodyMR
+			keyMR, err := eb.ComputeKeyMR()
+			require.NoError(t, err)
+			eb.KeyMR = &keyMR
+			data, err := eb.MarshalBinary()
+			require.NoError(t, err)
+			m.rawData[keyMR] = data
+			dblock.EBlocks = append(dblock.EBlocks,
+				factom.EBlock{ChainID: &chainID, KeyMR: &keyMR})
+		}
+		bodyMR, err := dblock.ComputeBodyMR()
+		require.NoError(t, err)
+		dblock.Header.BodyMR = &bodyMR
+		keyMR, err := dblock.ComputeKeyMR()
+		require.NoError(t, err)
+		dblock.KeyMR = &keyMR
+		fullHash, err := dblock.ComputeFullHash()
+		require.NoError(t, err)
+		dblock.FullHash = &fullHash
+		prevKeyMR, prevFullHash = keyMR, fullHash
+		m.dblocks = append(m.dblocks, dblock)
+	}
+	return m
+}
+
+func (m *mockFactomd) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	atomic.AddInt64(&m.requests, 1)
+	time.Sleep(m.latency)
+	var req struct {
+		ID     interface{}
+		Method string
+		Params struct {
+			Height uint32
+			Hash   factom.Bytes32
+		}
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var result interface{}
+	switch req.Method {
+	case "dblock-by-height":
+		result = struct {
+			DBlock *factom.DBlock `json:"dblock"`
+		}{&m.dblocks[req.Params.Height-1]}
+	case "raw-data":
+		result = struct {
+			Data factom.Bytes `json:"data"`
+		}{m.rawData[req.Params.Hash]}
+	default:
+		http.Error(w, "method not found", http.StatusBadRequest)
+		return
+	}
+	json.NewEncoder(w).Encode(struct {
+		JSONRPC string      `json:"jsonrpc"`
+		ID      interface{} `json:"id"`
+		Result  interface{} `json:"result"`
+	}{"2.0", req.ID, result})
+}
+
+// setupMockFactomd points the FactomClient at a new mockFactomd server and
+// returns a function that restores the FactomClient and closes the server.
+func setupMockFactomd(m *mockFactomd) func() {
+	srv := httptest.NewServer(m)
+	factomdServer := c.FactomdServer
+	c.FactomdServer = srv.URL
+	return func() {
+		c.FactomdServer = factomdServer
+		srv.Close()
+	}
+}
+
+func TestPrefetch(t *testing.T) {
+	const heights, chains, entries = 20, 3, 4
+	m := newMockFactomd(t, heights, chains, entries, 0)
+	defer setupMockFactomd(m)()
+
+	done := make(chan struct{})
+	defer close(done)
+	dblocks := prefetch(1, heights, 4, 4, done)
+	for h := uint32(1); h <= heights; h++ {
+		dblock, err := dblocks.Next()
+		require.NoError(t, err)
+		assert.Equal(t, h, dblock.Header.Height)
+		assert.Equal(t, *m.dblocks[h-1].KeyMR, *dblock.KeyMR)
+		require.Len(t, dblock.EBlocks, chains)
+		for _, eb := range dblock.EBlocks {
+			assert.True(t, eb.IsFirst())
+			require.Len(t, eb.Entries, entries)
+			for _, e := range eb.Entries {
+				assert.True(t, e.IsPopulated())
+			}
+		}
+	}
+	_, err := dblocks.Next()
+	assert.EqualError(t, err, "prefetch stopped")
+	assert.Equal(t, int64(heights*(1+chains*(1+entries))), m.requests)
+}
+
+func BenchmarkPrefetch(b *testing.B) {
+	const heights, chains, entries = 20, 5, 5
+	m := newMockFactomd(b, heights, chains, entries, time.Millisecond)
+	defer setupMockFactomd(m)()
+	for _, bm := range []struct {
+		Name            string
+		Window, Workers int
+	}{
+		{"sequential", 1, 1},
+		{"window=1", 1, 8},
+		{"window=4", 4, 8},
+		{"window=16", 16, 8},
+		{"window=16/workers=32", 16, 32},
+	} {
+		b.Run(bm.Name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				done := make(chan struct{})
+				dblocks := prefetch(1, heights,
+					bm.Window, bm.Workers, done)
+				for h := 1; h <= heights; h++ {
+					if _, err := dblocks.Next(); err != nil {
+						b.Fatal(err)
+					}
+				}
+				close(done)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package engine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Factom-Asset-Tokens/fatd/factom"
+)
+
+func TestVerifyDBlock(t *testing.T) {
+	const heights, chains, entries = 3, 2, 2
+	m := newMockFactomd(t, heights, chains, entries, 0)
+	defer setupMockFactomd(m)()
+
+	done := make(chan struct{})
+	defer close(done)
+	dblocks := prefetch(1, heights, 2, 2, done)
+	var fetched []factom.DBlock
+	for h := 1; h <= heights; h++ {
+		dblock, err := dblocks.Next()
+		require.NoError(t, err)
+		fetched = append(fetched, dblock)
+	}
+
+	// The mock DBlocks start at height 1, linked to an empty DBlock 0.
+	genesis := factom.DBlock{KeyMR: new(factom.Bytes32),
+		FullHash: new(factom.Bytes32)}
+	assert.NoError(t, verifyDBlock(genesis, fetched[0]))
+	assert.NoError(t, verifyDBlock(fetched[0], fetched[1]))
+	assert.NoError(t, verifyDBlock(fetched[1], fetched[2]))
+	assert.EqualError(t, verifyDBlock(fetched[0], fetched[2]),
+		"DBlock 3: does not follow DBlock 1")
+	assert.EqualError(t, verifyDBlock(factom.DBlock{}, fetched[0]),
+		"DBlock 1: no previous DBlock to verify against")
+
+	// A DBlock that is valid by itself but does not link to the previous
+	// DBlock is rejected.
+	forked := newMockFactomd(t, heights, chains+1, entries, 0)
+	assert.EqualError(t, verifyDBlock(fetched[0], forked.dblocks[1]),
+		"DBlock 2: PrevKeyMR does not match the KeyMR of DBlock 1")
+
+	// A populated EBlock that does not match the DBlock is rejected.
+	tampered := fetched[1]
+	tampered.EBlocks = append([]factom.EBlock{}, fetched[1].EBlocks...)
+	eb := &tampered.EBlocks[0]
+	require.True(t, eb.IsPopulated())
+	eb.Height++
+	assert.EqualError(t, verifyDBlock(fetched[0], tampered),
+		"EBlock{ChainID: "+eb.ChainID.String()+
+			"}: height 3 does not match DBlock 2")
+}
@@ -0,0 +1,122 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+// Package events publishes the changes to the state of tracked token chains
+// to any number of subscribers, such as WebSocket clients.
+package events
+
+import (
+	"sync"
+
+	"github.com/Factom-Asset-Tokens/fatd/factom"
+	"github.com/Factom-Asset-Tokens/fatd/fat"
+	"github.com/Factom-Asset-Tokens/fatd/fat/fat1"
+)
+
+// BufferSize is the number of events that may be queued for a Subscription
+// before it is considered too slow and is closed.
+const BufferSize = 1024
+
+// Transaction is published when a valid transaction is applied to a token
+// chain.
+type Transaction struct {
+	ChainID   *factom.Bytes32 `json:"chainid"`
+	Hash      *factom.Bytes32 `json:"entryhash"`
+	Timestamp int64           `json:"timestamp"`
+	Height    uint32          `json:"height"`
+	Tx        interface{}     `json:"data"`
+
+	// Addresses are all input and output addresses of Tx.
+	Addresses []factom.FAAddress `json:"-"`
+	// NFTokens are all NFTokenIDs transferred by Tx, if it is a FAT-1
+	// transaction.
+	NFTokens fat1.NFTokens `json:"-"`
+}
+
+// Issuance is published when a token chain is issued.
+type Issuance struct {
+	ChainID       *factom.Bytes32 `json:"chainid"`
+	TokenID       string          `json:"tokenid"`
+	IssuerChainID *factom.Bytes32 `json:"issuerid"`
+	Hash          *factom.Bytes32 `json:"entryhash"`
+	Timestamp     int64           `json:"timestamp"`
+	Height        uint32          `json:"height"`
+	Issuance      fat.Issuance    `json:"issuance"`
+}
+
+// SyncHeight is published when all EBlocks in a DBlock have been processed.
+type SyncHeight struct {
+	Height uint32 `json:"syncheight"`
+}
+
+// Subscription receives all published events on C, which is closed when the
+// Subscription is cancelled or falls more than BufferSize events behind.
+type Subscription struct {
+	C <-chan interface{}
+	c chan interface{}
+}
+
+var subs = struct {
+	m map[*Subscription]struct{}
+	sync.Mutex
+}{m: make(map[*Subscription]struct{})}
+
+// Subscribe returns a new Subscription to all events published after it is
+// created.
+func Subscribe() *Subscription {
+	c := make(chan interface{}, BufferSize)
+	sub := &Subscription{C: c, c: c}
+	subs.Lock()
+	defer subs.Unlock()
+	subs.m[sub] = struct{}{}
+	return sub
+}
+
+// Cancel stops sub from receiving events and closes sub.C, if it is not
+// already closed.
+func (sub *Subscription) Cancel() {
+	subs.Lock()
+	defer subs.Unlock()
+	sub.cancel()
+}
+
+func (sub *Subscription) cancel() {
+	if _, ok := subs.m[sub]; !ok {
+		return
+	}
+	delete(subs.m, sub)
+	close(sub.c)
+}
+
+// Publish sends evt to all Subscriptions without blocking. Any Subscription
+// whose buffer is full is cancelled.
+func Publish(evt interface{}) {
+	subs.Lock()
+	defer subs.Unlock()
+	for sub := range subs.m {
+		select {
+		case sub.c <- evt:
+		default:
+			sub.cancel()
+		}
+	}
+}
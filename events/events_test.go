@@ -0,0 +1,56 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package events
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublish(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	sub := Subscribe()
+	defer sub.Cancel()
+	Publish(SyncHeight{Height: 1})
+	require.Len(sub.C, 1)
+	assert.Equal(SyncHeight{Height: 1}, <-sub.C)
+
+	// A subscription that falls too far behind is closed.
+	slow := Subscribe()
+	for i := 0; i <= BufferSize; i++ {
+		Publish(SyncHeight{Height: uint32(i)})
+		<-sub.C
+	}
+	for range slow.C {
+	}
+	slow.Cancel() // No-op if already cancelled.
+
+	sub.Cancel()
+	_, ok := <-sub.C
+	assert.False(ok)
+	Publish(SyncHeight{Height: 2})
+}
@@ -0,0 +1,386 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package factom
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+)
+
+// ABlock represents a Factom Admin Block, which records the Directory Block
+// signatures of the federated servers and changes to the authority set.
+type ABlock struct {
+	// LookupHash is the hash referenced by the DBlock. BackReferenceHash
+	// is the hash referenced by the next ABlock.
+	LookupHash        *Bytes32
+	BackReferenceHash *Bytes32
+
+	Header ABlockHeader
+
+	Entries []ABlockEntry
+}
+
+type ABlockHeader struct {
+	PrevBackReferenceHash *Bytes32
+
+	Height uint32
+
+	ExpansionArea Bytes
+}
+
+// IsPopulated returns true if ab has already been successfully populated by a
+// call to Get.
+func (ab ABlock) IsPopulated() bool {
+	return ab.LookupHash != nil &&
+		ab.BackReferenceHash != nil &&
+		ab.Header.PrevBackReferenceHash != nil
+}
+
+// Get queries factomd for the Admin Block with ab.LookupHash, if not nil, and
+// otherwise for the Admin Block at ab.Header.Height. The LookupHash of an
+// Admin Block is the KeyMR of the first EBlock in a DBlock.
+func (ab *ABlock) Get(c *Client) error {
+	if ab.IsPopulated() {
+		return nil
+	}
+	if ab.LookupHash != nil {
+		lookupHash := *ab.LookupHash
+		data, err := c.getRawData(&lookupHash, validABlock(lookupHash))
+		if err != nil {
+			return err
+		}
+		if err := ab.UnmarshalBinary(data); err != nil {
+			return err
+		}
+		if *ab.LookupHash != lookupHash {
+			return fmt.Errorf("invalid lookup hash")
+		}
+		return nil
+	}
+
+	params := struct {
+		Height uint32 `json:"height"`
+	}{ab.Header.Height}
+	var result struct {
+		Data Bytes `json:"rawdata"`
+	}
+	if err := c.FactomdRequest("ablock-by-height", params, &result); err != nil {
+		return err
+	}
+	height := ab.Header.Height
+	if err := ab.UnmarshalBinary(result.Data); err != nil {
+		return err
+	}
+	if ab.Header.Height != height {
+		return fmt.Errorf("height does not match")
+	}
+	return nil
+}
+
+// ABlockEntry is implemented by the types of entries in an Admin Block.
+type ABlockEntry interface {
+	// Type returns the Admin Block entry type byte.
+	Type() byte
+}
+
+// Admin Block entry type bytes.
+const (
+	ABlockMinuteType byte = iota
+	ABlockDBSignatureType
+	ABlockRevealMatryoshkaHashType
+	ABlockAddMatryoshkaHashType
+	ABlockIncreaseServerCountType
+	ABlockAddFederatedServerType
+	ABlockAddAuditServerType
+	ABlockRemoveFederatedServerType
+	ABlockAddFederatedServerSigningKeyType
+	ABlockAddFederatedServerBitcoinAnchorKeyType
+	ABlockServerFaultType
+	ABlockCoinbaseDescriptorType
+	ABlockCoinbaseDescriptorCancelType
+	ABlockAddAuthorityFactoidAddressType
+	ABlockAddAuthorityEfficiencyType
+)
+
+// ABlockMinute marks the end of a minute. It is no longer used.
+type ABlockMinute struct {
+	Minute uint8
+}
+
+// ABlockDBSignature is a federated server's signature of the previous DBlock
+// header.
+type ABlockDBSignature struct {
+	IdentityChainID *Bytes32
+	PublicKey       *Bytes32
+	Signature       Bytes
+}
+
+// ABlockRevealMatryoshkaHash reveals the Matryoshka Hash of a server.
+type ABlockRevealMatryoshkaHash struct {
+	IdentityChainID *Bytes32
+	MatryoshkaHash  *Bytes32
+}
+
+// ABlockAddMatryoshkaHash adds or replaces the Matryoshka Hash of a server.
+type ABlockAddMatryoshkaHash struct {
+	IdentityChainID *Bytes32
+	MatryoshkaHash  *Bytes32
+}
+
+// ABlockIncreaseServerCount increases the number of federated servers.
+type ABlockIncreaseServerCount struct {
+	Amount uint8
+}
+
+// ABlockAddFederatedServer promotes a server to the federated set at Height.
+type ABlockAddFederatedServer struct {
+	IdentityChainID *Bytes32
+	Height          uint32
+}
+
+// ABlockAddAuditServer adds a server to the audit set at Height.
+type ABlockAddAuditServer struct {
+	IdentityChainID *Bytes32
+	Height          uint32
+}
+
+// ABlockRemoveFederatedServer removes a server from the authority set at
+// Height.
+type ABlockRemoveFederatedServer struct {
+	IdentityChainID *Bytes32
+	Height          uint32
+}
+
+// ABlockAddFederatedServerSigningKey sets the key a server uses to sign
+// DBlocks, starting at Height.
+type ABlockAddFederatedServerSigningKey struct {
+	IdentityChainID *Bytes32
+	KeyPriority     uint8
+	PublicKey       *Bytes32
+	Height          uint32
+}
+
+// ABlockAddFederatedServerBitcoinAnchorKey sets the key a server uses to
+// anchor into Bitcoin.
+type ABlockAddFederatedServerBitcoinAnchorKey struct {
+	IdentityChainID *Bytes32
+	KeyPriority     uint8
+	KeyType         uint8
+	ECDSAPublicKey  Bytes
+}
+
+// ABlockCoinbaseDescriptor describes the outputs of the coinbase transaction
+// that pays the authority servers.
+type ABlockCoinbaseDescriptor struct {
+	Outputs []FactoidAmount
+}
+
+// ABlockCoinbaseDescriptorCancel cancels an output of a previous
+// ABlockCoinbaseDescriptor.
+type ABlockCoinbaseDescriptorCancel struct {
+	DescriptorHeight uint32
+	DescriptorIndex  uint64
+}
+
+// ABlockAddAuthorityFactoidAddress sets the address that a server's coinbase
+// output pays to.
+type ABlockAddAuthorityFactoidAddress struct {
+	IdentityChainID *Bytes32
+	FAAddress       FAAddress
+}
+
+// ABlockAddAuthorityEfficiency sets the portion of a server's coinbase output
+// that goes to the grant pool, in hundredths of a percent.
+type ABlockAddAuthorityEfficiency struct {
+	IdentityChainID *Bytes32
+	Efficiency      uint16
+}
+
+// ABlockForwardCompatible is an entry of a type that is not yet known. Only
+// its raw Data is available.
+type ABlockForwardCompatible struct {
+	EntryType byte
+	Data      Bytes
+}
+
+func (ABlockMinute) Type() byte                       { return ABlockMinuteType }
+func (ABlockDBSignature) Type() byte                  { return ABlockDBSignatureType }
+func (ABlockRevealMatryoshkaHash) Type() byte         { return ABlockRevealMatryoshkaHashType }
+func (ABlockAddMatryoshkaHash) Type() byte            { return ABlockAddMatryoshkaHashType }
+func (ABlockIncreaseServerCount) Type() byte          { return ABlockIncreaseServerCountType }
+func (ABlockAddFederatedServer) Type() byte           { return ABlockAddFederatedServerType }
+func (ABlockAddAuditServer) Type() byte               { return ABlockAddAuditServerType }
+func (ABlockRemoveFederatedServer) Type() byte        { return ABlockRemoveFederatedServerType }
+func (ABlockAddFederatedServerSigningKey) Type() byte { return ABlockAddFederatedServerSigningKeyType }
+func (ABlockAddFederatedServerBitcoinAnchorKey) Type() byte {
+	return ABlockAddFederatedServerBitcoinAnchorKeyType
+}
+func (ABlockCoinbaseDescriptor) Type() byte         { return ABlockCoinbaseDescriptorType }
+func (ABlockCoinbaseDescriptorCancel) Type() byte   { return ABlockCoinbaseDescriptorCancelType }
+func (ABlockAddAuthorityFactoidAddress) Type() byte { return ABlockAddAuthorityFactoidAddressType }
+func (ABlockAddAuthorityEfficiency) Type() byte     { return ABlockAddAuthorityEfficiencyType }
+func (e ABlockForwardCompatible) Type() byte        { return e.EntryType }
+
+// UnmarshalBinary unmarshals raw admin block data and computes the LookupHash
+// and BackReferenceHash.
+//
+// Header
+// [Admin Block ChainID (Bytes32{31:0x0a})] +
+// [PrevBackReferenceHash (Bytes32)] +
+// [DB Height (4 bytes)] +
+// [Header Expansion Size (varint_f)] +
+// [Header Expansion Area (Bytes)] +
+// [Message Count (4 bytes)] +
+// [Body Size (4 bytes)]
+//
+// Body
+// [Entry Type (1 byte)] + [Entry (Bytes)] +
+// ...
+//
+// Entries of type 0x0b and above are preceded by their length as a varint_f,
+// so that entries of unknown types may be skipped.
+//
+// https://github.com/FactomProject/FactomDocs/blob/master/factomDataStructureDetails.md#administrative-block
+func (ab *ABlock) UnmarshalBinary(data []byte) error {
+	r := blockReader{data: data}
+	chainID := r.Bytes32()
+	var header ABlockHeader
+	header.PrevBackReferenceHash = r.Bytes32()
+	header.Height = r.Uint32()
+	header.ExpansionArea = r.Bytes(int(r.VarInt()))
+	count := r.Uint32()
+	bodySize := r.Uint32()
+	if r.err != nil {
+		return r.err
+	}
+	if *chainID != AdminBlockChainID {
+		return fmt.Errorf("invalid chain id")
+	}
+	if uint64(r.Len()) != uint64(bodySize) {
+		return fmt.Errorf("invalid body size")
+	}
+
+	entries := make([]ABlockEntry, 0, count)
+	for r.Len() > 0 {
+		e, err := unmarshalABlockEntry(&r)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, e)
+	}
+	if len(entries) != int(count) {
+		return fmt.Errorf("invalid message count")
+	}
+
+	ab.Header = header
+	ab.Entries = entries
+	ab.LookupHash = new(Bytes32)
+	*ab.LookupHash = sha256.Sum256(data)
+	ab.BackReferenceHash = new(Bytes32)
+	sum := sha512.Sum512(data)
+	copy(ab.BackReferenceHash[:], sum[:])
+	return nil
+}
+
+func unmarshalABlockEntry(r *blockReader) (ABlockEntry, error) {
+	entryType := r.Uint8()
+	if entryType >= ABlockCoinbaseDescriptorType {
+		return unmarshalABlockSizedEntry(entryType, r.Bytes(int(r.VarInt())), r.err)
+	}
+	var e ABlockEntry
+	switch entryType {
+	case ABlockMinuteType:
+		e = ABlockMinute{Minute: r.Uint8()}
+	case ABlockDBSignatureType:
+		e = ABlockDBSignature{IdentityChainID: r.Bytes32(),
+			PublicKey: r.Bytes32(), Signature: r.Bytes(SignatureSize)}
+	case ABlockRevealMatryoshkaHashType:
+		e = ABlockRevealMatryoshkaHash{IdentityChainID: r.Bytes32(),
+			MatryoshkaHash: r.Bytes32()}
+	case ABlockAddMatryoshkaHashType:
+		e = ABlockAddMatryoshkaHash{IdentityChainID: r.Bytes32(),
+			MatryoshkaHash: r.Bytes32()}
+	case ABlockIncreaseServerCountType:
+		e = ABlockIncreaseServerCount{Amount: r.Uint8()}
+	case ABlockAddFederatedServerType:
+		e = ABlockAddFederatedServer{IdentityChainID: r.Bytes32(),
+			Height: r.Uint32()}
+	case ABlockAddAuditServerType:
+		e = ABlockAddAuditServer{IdentityChainID: r.Bytes32(),
+			Height: r.Uint32()}
+	case ABlockRemoveFederatedServerType:
+		e = ABlockRemoveFederatedServer{IdentityChainID: r.Bytes32(),
+			Height: r.Uint32()}
+	case ABlockAddFederatedServerSigningKeyType:
+		e = ABlockAddFederatedServerSigningKey{IdentityChainID: r.Bytes32(),
+			KeyPriority: r.Uint8(), PublicKey: r.Bytes32(),
+			Height: r.Uint32()}
+	case ABlockAddFederatedServerBitcoinAnchorKeyType:
+		e = ABlockAddFederatedServerBitcoinAnchorKey{
+			IdentityChainID: r.Bytes32(),
+			KeyPriority:     r.Uint8(), KeyType: r.Uint8(),
+			ECDSAPublicKey: r.Bytes(20)}
+	default:
+		// Server Fault entries are not length prefixed and have never
+		// been recorded in an Admin Block.
+		return nil, fmt.Errorf("unsupported admin block entry type: %#x",
+			entryType)
+	}
+	return e, r.err
+}
+
+func unmarshalABlockSizedEntry(entryType byte, data []byte,
+	err error) (ABlockEntry, error) {
+	if err != nil {
+		return nil, err
+	}
+	r := blockReader{data: data}
+	var e ABlockEntry
+	switch entryType {
+	case ABlockCoinbaseDescriptorType:
+		var cd ABlockCoinbaseDescriptor
+		for r.Len() > 0 && r.err == nil {
+			cd.Outputs = append(cd.Outputs, r.factoidAmount())
+		}
+		e = cd
+	case ABlockCoinbaseDescriptorCancelType:
+		e = ABlockCoinbaseDescriptorCancel{DescriptorHeight: r.Uint32(),
+			DescriptorIndex: r.VarInt()}
+	case ABlockAddAuthorityFactoidAddressType:
+		e = ABlockAddAuthorityFactoidAddress{IdentityChainID: r.Bytes32(),
+			FAAddress: FAAddress(*r.Bytes32())}
+	case ABlockAddAuthorityEfficiencyType:
+		e = ABlockAddAuthorityEfficiency{IdentityChainID: r.Bytes32(),
+			Efficiency: r.Uint16()}
+	default:
+		return ABlockForwardCompatible{EntryType: entryType, Data: data}, nil
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	if r.Len() > 0 {
+		return nil, fmt.Errorf("invalid admin block entry size")
+	}
+	return e, nil
+}
@@ -0,0 +1,244 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package factom_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	merkle "github.com/AdamSLevy/go-merkle"
+	. "github.com/Factom-Asset-Tokens/fatd/factom"
+	"github.com/Factom-Asset-Tokens/fatd/factom/varintf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockBuilder builds raw block data for the tests below.
+type blockBuilder struct{ bytes.Buffer }
+
+func (b *blockBuilder) put(vals ...interface{}) *blockBuilder {
+	for _, v := range vals {
+		switch v := v.(type) {
+		case []byte:
+			b.Write(v)
+		case Bytes32:
+			b.Write(v[:])
+		default:
+			binary.Write(b, binary.BigEndian, v)
+		}
+	}
+	return b
+}
+
+func (b *blockBuilder) varInt(x uint64) *blockBuilder {
+	b.Write(varintf.Encode(x))
+	return b
+}
+
+func (b *blockBuilder) timestamp(ts time.Time) *blockBuilder {
+	ms := make([]byte, 8)
+	binary.BigEndian.PutUint64(ms, uint64(ts.UnixNano()/1e6))
+	b.Write(ms[2:])
+	return b
+}
+
+// testTruncated asserts that every prefix of data fails to unmarshal.
+func testTruncated(t *testing.T, data []byte,
+	unmarshal func([]byte) error) {
+	for i := range data {
+		assert.Errorf(t, unmarshal(data[:i]), "len: %v", i)
+	}
+}
+
+func TestABlockUnmarshalBinary(t *testing.T) {
+	idChainID := Bytes32{0x88, 0x88, 0x88, 1}
+	pubKey := Bytes32{2}
+	sig := bytes.Repeat([]byte{3}, 64)
+	fa := FAAddress{4}
+
+	var body blockBuilder
+	body.put(ABlockDBSignatureType, idChainID, pubKey, sig)
+	body.put(ABlockAddFederatedServerSigningKeyType,
+		idChainID, uint8(0), pubKey, uint32(1000))
+	var cd blockBuilder
+	cd.varInt(5e8).put(fa)
+	cd.varInt(1).put(Bytes32{5})
+	body.put(ABlockCoinbaseDescriptorType).varInt(uint64(cd.Len()))
+	body.put(cd.Bytes())
+	body.put(ABlockAddAuthorityEfficiencyType).varInt(34)
+	body.put(idChainID, uint16(5000))
+	body.put(byte(0x20)).varInt(3).put([]byte{6, 7, 8})
+
+	var data blockBuilder
+	data.put(AdminBlockChainID, Bytes32{9}, uint32(1000))
+	data.varInt(2).put([]byte{10, 11})
+	data.put(uint32(5), uint32(body.Len()), body.Bytes())
+
+	var ab ABlock
+	require.NoError(t, ab.UnmarshalBinary(data.Bytes()))
+	assert.Equal(t, Bytes32(sha256.Sum256(data.Bytes())), *ab.LookupHash)
+	sum := sha512.Sum512(data.Bytes())
+	assert.Equal(t, *NewBytes32(sum[:]), *ab.BackReferenceHash)
+	assert.Equal(t, Bytes32{9}, *ab.Header.PrevBackReferenceHash)
+	assert.Equal(t, uint32(1000), ab.Header.Height)
+	assert.Equal(t, Bytes{10, 11}, ab.Header.ExpansionArea)
+	assert.Equal(t, []ABlockEntry{
+		ABlockDBSignature{IdentityChainID: &idChainID,
+			PublicKey: &pubKey, Signature: sig},
+		ABlockAddFederatedServerSigningKey{IdentityChainID: &idChainID,
+			PublicKey: &pubKey, Height: 1000},
+		ABlockCoinbaseDescriptor{Outputs: []FactoidAmount{
+			{Amount: 5e8, FAAddress: fa},
+			{Amount: 1, FAAddress: FAAddress{5}}}},
+		ABlockAddAuthorityEfficiency{IdentityChainID: &idChainID,
+			Efficiency: 5000},
+		ABlockForwardCompatible{EntryType: 0x20, Data: Bytes{6, 7, 8}},
+	}, ab.Entries)
+
+	testTruncated(t, data.Bytes(), func(data []byte) error {
+		return new(ABlock).UnmarshalBinary(data)
+	})
+}
+
+func TestECBlockUnmarshalBinary(t *testing.T) {
+	ts := time.Unix(1500000000, 123e6)
+	ec := ECAddress{1}
+	sig := bytes.Repeat([]byte{2}, 64)
+
+	var body blockBuilder
+	body.put(ECBlockServerIndexType, uint8(0))
+	body.put(ECBlockEntryCommitType, uint8(0)).timestamp(ts)
+	body.put(Bytes32{3}, uint8(1), ec, sig)
+	body.put(ECBlockMinuteType, uint8(1))
+	body.put(ECBlockChainCommitType, uint8(0)).timestamp(ts)
+	body.put(Bytes32{4}, Bytes32{5}, Bytes32{6}, uint8(11), ec, sig)
+	body.put(ECBlockBalanceIncreaseType, ec, Bytes32{7}).varInt(0).varInt(200)
+
+	var data blockBuilder
+	data.put(EntryCreditBlockChainID, sha256.Sum256(body.Bytes()),
+		Bytes32{8}, Bytes32{9}, uint32(1000))
+	data.varInt(0)
+	data.put(uint64(5), uint64(body.Len()))
+	headerHash := sha256.Sum256(data.Bytes())
+	data.put(body.Bytes())
+
+	var ecb ECBlock
+	require.NoError(t, ecb.UnmarshalBinary(data.Bytes()))
+	assert.Equal(t, Bytes32(headerHash), *ecb.HeaderHash)
+	assert.Equal(t, Bytes32(sha256.Sum256(data.Bytes())), *ecb.FullHash)
+	assert.Equal(t, uint32(1000), ecb.Header.Height)
+	assert.Equal(t, uint64(5), ecb.Header.ObjectCount)
+	require.Len(t, ecb.Entries, 5)
+	assert.Equal(t, ECBlockMinute{Minute: 1}, ecb.Entries[2])
+	commit := ecb.Entries[3].(ECBlockChainCommit)
+	assert.Equal(t, ts, commit.Timestamp)
+	assert.Equal(t, Bytes32{6}, *commit.EntryHash)
+	assert.Equal(t, ec, commit.ECAddress)
+	assert.Equal(t, Bytes(sig), commit.Signature)
+	assert.Equal(t, ECBlockBalanceIncrease{ECAddress: ec,
+		TxID: &Bytes32{7}, Credits: 200}, ecb.Entries[4])
+	assert.Equal(t, map[Bytes32]uint8{{3}: 1, {6}: 11}, ecb.EntryCredits())
+
+	testTruncated(t, data.Bytes(), func(data []byte) error {
+		return new(ECBlock).UnmarshalBinary(data)
+	})
+
+	// The body must match the BodyHash.
+	data.Bytes()[data.Len()-1]++
+	assert.EqualError(t, ecb.UnmarshalBinary(data.Bytes()),
+		"invalid body hash")
+}
+
+func TestFBlockUnmarshalBinary(t *testing.T) {
+	ts := time.Unix(1500000000, 123e6)
+	fs, err := GenerateFsAddress()
+	require.NoError(t, err)
+
+	var coinbase blockBuilder
+	coinbase.varInt(2).timestamp(ts).put(uint8(0), uint8(1), uint8(0))
+	coinbase.varInt(5e8).put(Bytes32{1})
+
+	var tx blockBuilder
+	tx.varInt(2).timestamp(ts).put(uint8(1), uint8(1), uint8(1))
+	tx.varInt(3e8).put(fs.RCDHash())
+	tx.varInt(1e8).put(Bytes32{2})
+	tx.varInt(2e8).put(Bytes32{3})
+	txID := sha256.Sum256(tx.Bytes())
+	tx.put(fs.RCD(), bytes.Repeat([]byte{4}, 64))
+
+	// The coinbase is processed in minute 0 and the other transaction in
+	// minute 2.
+	leaves := [][]byte{coinbase.Bytes(), {0}, {0}, tx.Bytes()}
+	for len(leaves) < 12 {
+		leaves = append(leaves, []byte{0})
+	}
+	tree := merkle.NewTreeWithOpts(merkle.TreeOptions{DoubleOddNodes: true})
+	require.NoError(t, tree.Generate(leaves, sha256.New()))
+	bodyMR := NewBytes32(tree.Root().Hash)
+	body := bytes.Join(leaves, nil)
+
+	var data blockBuilder
+	data.put(FactoidBlockChainID, *bodyMR, Bytes32{5}, Bytes32{6},
+		uint64(1000), uint32(1000))
+	data.varInt(0)
+	data.put(uint32(2), uint32(len(body)))
+	headerHash := sha256.Sum256(data.Bytes())
+	data.put(body)
+
+	var fb FBlock
+	require.NoError(t, fb.UnmarshalBinary(data.Bytes()))
+	keyMR := sha256.Sum256(append(headerHash[:], bodyMR[:]...))
+	assert.Equal(t, Bytes32(keyMR), *fb.KeyMR)
+	assert.Equal(t, uint64(1000), fb.Header.ECExchangeRate)
+	require.Len(t, fb.Transactions, 2)
+	assert.Empty(t, fb.Transactions[0].FCTInputs)
+	assert.Empty(t, fb.Transactions[0].Signatures)
+	assert.Equal(t, uint8(0), fb.Transactions[0].Minute)
+
+	fct := fb.Transactions[1]
+	assert.Equal(t, Bytes32(txID), *fct.ID)
+	assert.Equal(t, uint64(2), fct.Version)
+	assert.Equal(t, ts, fct.Timestamp)
+	assert.Equal(t, uint8(2), fct.Minute)
+	assert.Equal(t, []FactoidAmount{{Amount: 3e8, FAAddress: fs.FAAddress()}},
+		fct.FCTInputs)
+	assert.Equal(t, []FactoidAmount{{Amount: 1e8, FAAddress: FAAddress{2}}},
+		fct.FCTOutputs)
+	assert.Equal(t, []ECAmount{{Amount: 2e8, ECAddress: ECAddress{3}}},
+		fct.ECOutputs)
+	require.Len(t, fct.Signatures, 1)
+	assert.Equal(t, Bytes(fs.RCD()), fct.Signatures[0].RCD)
+
+	testTruncated(t, data.Bytes(), func(data []byte) error {
+		return new(FBlock).UnmarshalBinary(data)
+	})
+
+	// The body must match the BodyMR.
+	data.Bytes()[data.Len()-20]++
+	assert.EqualError(t, fb.UnmarshalBinary(data.Bytes()),
+		"invalid body merkle root")
+}
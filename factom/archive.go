@@ -0,0 +1,270 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package factom
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	jrpc "github.com/AdamSLevy/jsonrpc2/v11"
+)
+
+// BlockSource serves requests for factomd's v2 API from somewhere other than
+// factomd, such as an Archive.
+type BlockSource interface {
+	FactomdRequest(method string, params, result interface{}) error
+}
+
+// Archive is a directory of binary marshaled Factom blocks and entries that
+// serves the factomd API methods needed to sync, so that blocks can be
+// replayed without factomd. The directory layout is:
+//
+//	height              Latest DBlock height, in decimal
+//	dblock/<height>     DBlocks by height
+//	raw/<hash>          EBlocks by KeyMR, and Entries by Hash
+//	chainhead/<chainid> KeyMR of the latest EBlock of each chain, in hex
+type Archive struct {
+	Dir string
+}
+
+// Errors returned by an Archive, which match the errors returned by factomd.
+var (
+	ErrorArchiveBlockNotFound = jrpc.Error{Code: -32008,
+		Message: "Block not found"}
+	ErrorArchiveObjectNotFound = jrpc.Error{Code: -32008,
+		Message: "Object not found"}
+)
+
+// FactomdRequest implements BlockSource. Only the "heights",
+// "dblock-by-height", "raw-data", "chain-head" and "pending-entries" methods
+// are supported. There are never any pending entries.
+func (a Archive) FactomdRequest(method string, params, result interface{}) error {
+	var p struct {
+		Height  uint32   `json:"height"`
+		Hash    *Bytes32 `json:"hash"`
+		ChainID *Bytes32 `json:"chainid"`
+	}
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, &p); err != nil {
+			return *jrpc.InvalidParams(err.Error())
+		}
+	}
+
+	var res interface{}
+	switch method {
+	case "heights":
+		height, err := a.Height()
+		if err != nil {
+			return err
+		}
+		res = Heights{DirectoryBlock: height, Leader: height,
+			EntryBlock: height, Entry: height}
+	case "dblock-by-height":
+		db, err := a.DBlock(p.Height)
+		if err != nil {
+			return err
+		}
+		res = struct {
+			DBlock *DBlock `json:"dblock"`
+		}{&db}
+	case "raw-data":
+		if p.Hash == nil {
+			return *jrpc.InvalidParams("hash is required")
+		}
+		data, err := a.raw(*p.Hash)
+		if err != nil {
+			return err
+		}
+		res = struct {
+			Data Bytes `json:"data"`
+		}{data}
+	case "chain-head":
+		if p.ChainID == nil {
+			return *jrpc.InvalidParams("chainid is required")
+		}
+		// Like factomd, a chain that does not exist has a zero
+		// chain head.
+		keyMR, err := a.chainHead(*p.ChainID)
+		if err != nil {
+			return err
+		}
+		res = struct {
+			KeyMR *Bytes32 `json:"chainhead"`
+		}{keyMR}
+	case "pending-entries":
+		res = []struct{}{}
+	default:
+		return *jrpc.NewError(jrpc.MethodNotFoundCode,
+			jrpc.MethodNotFoundMessage, method)
+	}
+
+	// Round trip through JSON, just like a response from factomd.
+	data, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, result)
+}
+
+// Height returns the height of the latest DBlock in a.
+func (a Archive) Height() (uint32, error) {
+	data, err := ioutil.ReadFile(filepath.Join(a.Dir, "height"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, ErrorArchiveBlockNotFound
+		}
+		return 0, err
+	}
+	height, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("%v: %v", filepath.Join(a.Dir, "height"), err)
+	}
+	return uint32(height), nil
+}
+
+// DBlock returns the DBlock at height, with its KeyMR and FullHash.
+func (a Archive) DBlock(height uint32) (DBlock, error) {
+	var db DBlock
+	data, err := a.read(ErrorArchiveBlockNotFound,
+		"dblock", strconv.FormatUint(uint64(height), 10))
+	if err != nil {
+		return db, err
+	}
+	if err := db.UnmarshalBinary(data); err != nil {
+		return db, err
+	}
+	keyMR, err := db.ComputeKeyMR()
+	if err != nil {
+		return db, err
+	}
+	db.KeyMR = &keyMR
+	fullHash, err := db.ComputeFullHash()
+	if err != nil {
+		return db, err
+	}
+	db.FullHash = &fullHash
+	return db, nil
+}
+
+// PutDBlock saves db, and updates the archive height if db is the latest
+// DBlock.
+func (a Archive) PutDBlock(db DBlock) error {
+	data, err := db.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	if err := a.write(data, "dblock",
+		strconv.FormatUint(uint64(db.Header.Height), 10)); err != nil {
+		return err
+	}
+	height, err := a.Height()
+	if err == nil && height >= db.Header.Height {
+		return nil
+	}
+	if err != nil && err != ErrorArchiveBlockNotFound {
+		return err
+	}
+	return a.write([]byte(fmt.Sprintln(db.Header.Height)), "height")
+}
+
+// PutRaw saves the raw data of the EBlock or Entry with the given KeyMR or
+// Hash, as returned by factomd's "raw-data" method.
+func (a Archive) PutRaw(hash Bytes32, data []byte) error {
+	return a.write(data, "raw", hash.String())
+}
+
+// PutEBlockHead saves the raw data of eb, and sets eb as the chain head if it
+// is later than the current chain head.
+func (a Archive) PutEBlockHead(eb EBlock, data []byte) error {
+	if err := a.PutRaw(*eb.KeyMR, data); err != nil {
+		return err
+	}
+	keyMR, err := a.chainHead(*eb.ChainID)
+	if err != nil {
+		return err
+	}
+	var zero Bytes32
+	if *keyMR != zero {
+		data, err := a.raw(*keyMR)
+		if err != nil {
+			return err
+		}
+		var head EBlock
+		if err := head.UnmarshalBinary(data); err != nil {
+			return err
+		}
+		if head.Height >= eb.Height {
+			return nil
+		}
+	}
+	return a.write([]byte(fmt.Sprintln(eb.KeyMR)),
+		"chainhead", eb.ChainID.String())
+}
+
+func (a Archive) raw(hash Bytes32) ([]byte, error) {
+	return a.read(ErrorArchiveObjectNotFound, "raw", hash.String())
+}
+
+func (a Archive) chainHead(chainID Bytes32) (*Bytes32, error) {
+	data, err := a.read(nil, "chainhead", chainID.String())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return new(Bytes32), nil
+		}
+		return nil, err
+	}
+	keyMR := new(Bytes32)
+	if err := keyMR.Set(strings.TrimSpace(string(data))); err != nil {
+		return nil, fmt.Errorf("chainhead/%v: %v", chainID, err)
+	}
+	return keyMR, nil
+}
+
+// read returns the contents of the file at path in a, or notFound if it does
+// not exist and notFound is not nil.
+func (a Archive) read(notFound error, path ...string) ([]byte, error) {
+	data, err := ioutil.ReadFile(filepath.Join(
+		append([]string{a.Dir}, path...)...))
+	if os.IsNotExist(err) && notFound != nil {
+		return nil, notFound
+	}
+	return data, err
+}
+
+// write saves data to the file at path in a, creating any directories.
+func (a Archive) write(data []byte, path ...string) error {
+	fname := filepath.Join(append([]string{a.Dir}, path...)...)
+	if err := os.MkdirAll(filepath.Dir(fname), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fname, data, 0644)
+}
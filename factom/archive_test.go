@@ -0,0 +1,101 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package factom
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	jrpc "github.com/AdamSLevy/jsonrpc2/v11"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArchive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "factom-archive-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	a := Archive{Dir: dir}
+	c := NewClient()
+	c.FactomdServer = "http://localhost:0" // Never used.
+	c.BlockSource = a
+
+	var heights Heights
+	assert.Equal(t, ErrorArchiveBlockNotFound, heights.Get(c))
+
+	first, firstEB := newVerifyDBlock(t, nil)
+	second, secondEB := newVerifyDBlock(t, first)
+	// Save the DBlocks out of order.
+	require.NoError(t, a.PutDBlock(*second))
+	require.NoError(t, a.PutDBlock(*first))
+	require.NoError(t, heights.Get(c))
+	assert.Equal(t, Heights{11, 11, 11, 11}, heights)
+
+	for _, expected := range []*DBlock{first, second} {
+		db := DBlock{Header: DBlockHeader{Height: expected.Header.Height}}
+		require.NoError(t, db.Get(c))
+		assert.Equal(t, *expected.KeyMR, *db.KeyMR)
+		assert.Equal(t, *expected.FullHash, *db.FullHash)
+		assert.Equal(t, expected.Header.Timestamp, db.Header.Timestamp)
+		require.Len(t, db.EBlocks, 4)
+		assert.Equal(t, *expected.EBlocks[3].KeyMR, *db.EBlocks[3].KeyMR)
+	}
+	db := DBlock{Header: DBlockHeader{Height: 12}}
+	assert.Equal(t, ErrorArchiveBlockNotFound, db.Get(c))
+
+	// Both EBlocks are in the same chain, so the later one is the chain
+	// head, regardless of the order they are saved in.
+	for _, eb := range []EBlock{secondEB, firstEB} {
+		data, err := eb.MarshalBinary()
+		require.NoError(t, err)
+		require.NoError(t, a.PutEBlockHead(eb, data))
+	}
+	eb := EBlock{ChainID: firstEB.ChainID}
+	require.NoError(t, eb.Get(c))
+	assert.Equal(t, *secondEB.KeyMR, *eb.KeyMR)
+	assert.Equal(t, secondEB.Height, eb.Height)
+	eb = EBlock{ChainID: firstEB.ChainID, KeyMR: firstEB.KeyMR}
+	require.NoError(t, eb.Get(c))
+	assert.Equal(t, firstEB.Height, eb.Height)
+
+	e := Entry{ChainID: firstEB.ChainID, Hash: firstEB.Entries[0].Hash}
+	assert.Equal(t, ErrorArchiveObjectNotFound, e.Get(c))
+	data, err := firstEB.Entries[0].MarshalBinary()
+	require.NoError(t, err)
+	require.NoError(t, a.PutRaw(*e.Hash, data))
+	require.NoError(t, e.Get(c))
+	assert.Equal(t, firstEB.Entries[0].Content, e.Content)
+
+	// A chain that does not exist has no chain head.
+	eb = EBlock{ChainID: &Bytes32{1}}
+	assert.Error(t, eb.GetChainHead(c))
+
+	var pending PendingEntries
+	require.NoError(t, pending.Get(c))
+	assert.Empty(t, pending)
+
+	err = c.FactomdRequest("reveal-entry", nil, nil)
+	require.IsType(t, jrpc.Error{}, err)
+	assert.Equal(t, jrpc.MethodNotFoundCode, err.(jrpc.Error).Code)
+}
@@ -0,0 +1,249 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package factom
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+)
+
+// Cache stores the raw data of DBlocks, EBlocks, Entries, and Admin, Entry
+// Credit and Factoid Blocks, which are immutable, by their KeyMR or hash.
+// Implementations must be safe for concurrent use.
+type Cache interface {
+	// Get returns the data saved for hash, if any.
+	Get(hash Bytes32) (data []byte, ok bool)
+	// Put saves the data for hash. Since any object can be requested
+	// from factomd again, Put may fail silently.
+	Put(hash Bytes32, data []byte)
+}
+
+// CacheStats counts the lookups in the Cache of a Client.
+type CacheStats struct {
+	// Hits is the number of objects that were served from the Cache.
+	Hits uint64
+	// Misses is the number of objects that had to be requested from
+	// factomd.
+	Misses uint64
+}
+
+// CacheStats returns the number of hits and misses of c.Cache.
+func (c *Client) CacheStats() CacheStats {
+	return CacheStats{Hits: atomic.LoadUint64(&c.cacheHits),
+		Misses: atomic.LoadUint64(&c.cacheMisses)}
+}
+
+// getRawData returns the raw data of the block or Entry with the given hash
+// from factomd. If c.Cache is set, the data is served from and saved to it, as
+// long as valid returns true for the data.
+func (c *Client) getRawData(hash *Bytes32, valid func([]byte) bool) ([]byte, error) {
+	if c.Cache != nil {
+		// Data is validated again in case it was corrupted in the
+		// Cache.
+		if data, ok := c.Cache.Get(*hash); ok && valid(data) {
+			atomic.AddUint64(&c.cacheHits, 1)
+			return data, nil
+		}
+		atomic.AddUint64(&c.cacheMisses, 1)
+	}
+	params := struct {
+		Hash *Bytes32 `json:"hash"`
+	}{Hash: hash}
+	var result struct {
+		Data Bytes `json:"data"`
+	}
+	if err := c.FactomdRequest("raw-data", params, &result); err != nil {
+		return nil, err
+	}
+	if c.Cache != nil && valid(result.Data) {
+		c.Cache.Put(*hash, result.Data)
+	}
+	return result.Data, nil
+}
+
+// validEntry returns a func that returns true for the raw data of the Entry
+// with the given hash.
+func validEntry(hash Bytes32) func([]byte) bool {
+	return func(data []byte) bool {
+		return EntryHash(data) == hash
+	}
+}
+
+// validEBlock returns a func that returns true for the raw data of the EBlock
+// with the given keyMR.
+func validEBlock(keyMR Bytes32) func([]byte) bool {
+	return func(data []byte) bool {
+		var eb EBlock
+		if err := eb.UnmarshalBinary(data); err != nil {
+			return false
+		}
+		computed, err := eb.ComputeKeyMR()
+		return err == nil && computed == keyMR
+	}
+}
+
+// validDBlock returns a func that returns true for the raw data of the DBlock
+// with the given keyMR.
+func validDBlock(keyMR Bytes32) func([]byte) bool {
+	return func(data []byte) bool {
+		var db DBlock
+		if err := db.UnmarshalBinary(data); err != nil {
+			return false
+		}
+		computed, err := db.ComputeKeyMR()
+		return err == nil && computed == keyMR
+	}
+}
+
+// validABlock returns a func that returns true for the raw data of the ABlock
+// with the given lookupHash.
+func validABlock(lookupHash Bytes32) func([]byte) bool {
+	return func(data []byte) bool {
+		return sha256.Sum256(data) == lookupHash
+	}
+}
+
+// validECBlock returns a func that returns true for the raw data of the
+// ECBlock with the given headerHash.
+func validECBlock(headerHash Bytes32) func([]byte) bool {
+	return func(data []byte) bool {
+		var ecb ECBlock
+		return ecb.UnmarshalBinary(data) == nil &&
+			*ecb.HeaderHash == headerHash
+	}
+}
+
+// validFBlock returns a func that returns true for the raw data of the FBlock
+// with the given keyMR.
+func validFBlock(keyMR Bytes32) func([]byte) bool {
+	return func(data []byte) bool {
+		var fb FBlock
+		return fb.UnmarshalBinary(data) == nil && *fb.KeyMR == keyMR
+	}
+}
+
+// MemoryCache is an in-memory Cache of a fixed number of objects. When it is
+// full, the least recently used object is evicted.
+type MemoryCache struct {
+	size int
+
+	mtx   sync.Mutex
+	lru   *list.List // Most recently used first.
+	items map[Bytes32]*list.Element
+}
+
+type memoryCacheItem struct {
+	hash Bytes32
+	data []byte
+}
+
+// NewMemoryCache returns a MemoryCache that holds up to size objects.
+func NewMemoryCache(size int) *MemoryCache {
+	return &MemoryCache{size: size, lru: list.New(),
+		items: make(map[Bytes32]*list.Element, size)}
+}
+
+// Get returns the data saved for hash, if any, and marks it as the most
+// recently used.
+func (mc *MemoryCache) Get(hash Bytes32) ([]byte, bool) {
+	mc.mtx.Lock()
+	defer mc.mtx.Unlock()
+	elem, ok := mc.items[hash]
+	if !ok {
+		return nil, false
+	}
+	mc.lru.MoveToFront(elem)
+	return elem.Value.(memoryCacheItem).data, true
+}
+
+// Put saves data for hash, replacing any data already saved for it, and
+// evicting the least recently used object if the MemoryCache is full.
+func (mc *MemoryCache) Put(hash Bytes32, data []byte) {
+	if mc.size <= 0 {
+		return
+	}
+	mc.mtx.Lock()
+	defer mc.mtx.Unlock()
+	if elem, ok := mc.items[hash]; ok {
+		elem.Value = memoryCacheItem{hash: hash, data: data}
+		mc.lru.MoveToFront(elem)
+		return
+	}
+	if mc.lru.Len() >= mc.size {
+		oldest := mc.lru.Back()
+		mc.lru.Remove(oldest)
+		delete(mc.items, oldest.Value.(memoryCacheItem).hash)
+	}
+	mc.items[hash] = mc.lru.PushFront(memoryCacheItem{hash: hash, data: data})
+}
+
+// Len returns the number of objects in the MemoryCache.
+func (mc *MemoryCache) Len() int {
+	mc.mtx.Lock()
+	defer mc.mtx.Unlock()
+	return mc.lru.Len()
+}
+
+// DiskCache is a Cache that saves each object in a file in Dir named by its
+// hex encoded KeyMR or Entry Hash. Objects are never evicted.
+type DiskCache struct {
+	Dir string
+}
+
+// Get returns the data saved for hash, if any.
+func (dc DiskCache) Get(hash Bytes32) ([]byte, bool) {
+	data, err := ioutil.ReadFile(filepath.Join(dc.Dir, hash.String()))
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// Put saves data for hash. The data is written to a temporary file which is
+// then renamed, so that Get never returns partially written data.
+func (dc DiskCache) Put(hash Bytes32, data []byte) {
+	if err := os.MkdirAll(dc.Dir, 0755); err != nil {
+		return
+	}
+	f, err := ioutil.TempFile(dc.Dir, ".tmp-")
+	if err != nil {
+		return
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return
+	}
+	if err := os.Rename(f.Name(),
+		filepath.Join(dc.Dir, hash.String())); err != nil {
+		os.Remove(f.Name())
+	}
+}
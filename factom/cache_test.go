@@ -0,0 +1,143 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package factom
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryCache(t *testing.T) {
+	mc := NewMemoryCache(2)
+	mc.Put(Bytes32{1}, []byte{1})
+	mc.Put(Bytes32{2}, []byte{2})
+	// Use 1 so that 2 is the least recently used.
+	data, ok := mc.Get(Bytes32{1})
+	require.True(t, ok)
+	assert.Equal(t, []byte{1}, data)
+
+	mc.Put(Bytes32{3}, []byte{3})
+	assert.Equal(t, 2, mc.Len())
+	_, ok = mc.Get(Bytes32{2})
+	assert.False(t, ok, "least recently used was not evicted")
+	_, ok = mc.Get(Bytes32{1})
+	assert.True(t, ok)
+	_, ok = mc.Get(Bytes32{3})
+	assert.True(t, ok)
+
+	mc = NewMemoryCache(0)
+	mc.Put(Bytes32{1}, []byte{1})
+	assert.Equal(t, 0, mc.Len())
+}
+
+func TestDiskCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "factom-cache-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	dc := DiskCache{Dir: dir + "/cache"}
+
+	_, ok := dc.Get(Bytes32{1})
+	assert.False(t, ok)
+	dc.Put(Bytes32{1}, []byte{1, 2, 3})
+	data, ok := dc.Get(Bytes32{1})
+	require.True(t, ok)
+	assert.Equal(t, []byte{1, 2, 3}, data)
+
+	files, err := ioutil.ReadDir(dc.Dir)
+	require.NoError(t, err)
+	assert.Len(t, files, 1, "temporary file not renamed")
+}
+
+// countingBlockSource counts the requests for each method.
+type countingBlockSource struct {
+	BlockSource
+	count map[string]int
+}
+
+func (s countingBlockSource) FactomdRequest(method string,
+	params, result interface{}) error {
+	s.count[method]++
+	return s.BlockSource.FactomdRequest(method, params, result)
+}
+
+func TestClientCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "factom-cache-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	a := Archive{Dir: dir}
+
+	db, eb := newVerifyDBlock(t, nil)
+	dbData, err := db.MarshalBinary()
+	require.NoError(t, err)
+	require.NoError(t, a.PutRaw(*db.KeyMR, dbData))
+	ebData, err := eb.MarshalBinary()
+	require.NoError(t, err)
+	require.NoError(t, a.PutRaw(*eb.KeyMR, ebData))
+	eData, err := eb.Entries[0].MarshalBinary()
+	require.NoError(t, err)
+	require.NoError(t, a.PutRaw(*eb.Entries[0].Hash, eData))
+
+	src := countingBlockSource{BlockSource: a, count: make(map[string]int)}
+	c := NewClient()
+	c.FactomdServer = "http://localhost:0" // Never used.
+	c.BlockSource = src
+	cache := NewMemoryCache(10)
+	c.Cache = cache
+
+	get := func() {
+		db := DBlock{KeyMR: db.KeyMR}
+		require.NoError(t, db.Get(c))
+		eb := EBlock{ChainID: eb.ChainID, KeyMR: eb.KeyMR}
+		require.NoError(t, eb.Get(c))
+		e := Entry{ChainID: eb.ChainID, Hash: eb.Entries[0].Hash}
+		require.NoError(t, e.Get(c))
+		assert.Equal(t, Bytes("content"), e.Content)
+	}
+
+	get()
+	assert.Equal(t, 3, src.count["raw-data"])
+	assert.Equal(t, CacheStats{Hits: 0, Misses: 3}, c.CacheStats())
+	assert.Equal(t, 3, cache.Len())
+
+	get()
+	assert.Equal(t, 3, src.count["raw-data"])
+	assert.Equal(t, CacheStats{Hits: 3, Misses: 3}, c.CacheStats())
+
+	// Corrupted data in the cache is requested again.
+	corrupt := append([]byte{}, eData...)
+	corrupt[len(corrupt)-1]++
+	cache = NewMemoryCache(10)
+	cache.Put(*eb.Entries[0].Hash, corrupt)
+	c.Cache = cache
+	e := Entry{ChainID: eb.ChainID, Hash: eb.Entries[0].Hash}
+	require.NoError(t, e.Get(c))
+	assert.Equal(t, Bytes("content"), e.Content)
+	assert.Equal(t, 4, src.count["raw-data"])
+	data, ok := cache.Get(*eb.Entries[0].Hash)
+	require.True(t, ok)
+	assert.Equal(t, eData, data, "corrupted data was not replaced")
+}
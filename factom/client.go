@@ -35,10 +35,46 @@ import (
 // BasicAuth settings to set up BasicAuth and http.Client's transport settings
 // to configure TLS.
 type Client struct {
-	Factomd       jrpc.Client
+	// cacheHits and cacheMisses are only accessed atomically, and are
+	// first so that they are 64-bit aligned.
+	cacheHits, cacheMisses uint64
+
+	Factomd jrpc.Client
+	// FactomdServer is the URL of factomd, or a comma separated list of
+	// URLs of factomd servers to fail over between.
 	FactomdServer string
 	Walletd       jrpc.Client
 	WalletdServer string
+
+	// FactomdRoundRobin spreads requests across all healthy factomd
+	// servers. Otherwise requests are made to the first healthy server
+	// listed in FactomdServer.
+	FactomdRoundRobin bool
+	// FactomdRetries is the number of times a request to factomd that
+	// fails, other than with a JSON RPC error response, is retried using
+	// the next factomd server.
+	FactomdRetries uint64
+	// FactomdRetryDelay is the delay before the first retry of a request
+	// to factomd, which doubles for each subsequent retry.
+	FactomdRetryDelay time.Duration
+
+	// factomdNext is the index of the next factomd server to use with
+	// FactomdRoundRobin. It is only accessed atomically.
+	factomdNext uint32
+
+	// BlockSource, if not nil, serves all requests to factomd instead of
+	// the FactomdServer.
+	BlockSource BlockSource
+
+	// Cache, if not nil, saves the DBlocks, EBlocks and Entries that are
+	// requested by KeyMR or Entry Hash, so that they are only requested
+	// from factomd once. See CacheStats.
+	Cache Cache
+
+	// FactomdRequestHook, if not nil, is called after each request to
+	// factomd with the method, the duration of the request and the
+	// returned error, if any.
+	FactomdRequestHook func(method string, d time.Duration, err error)
 }
 
 // Defaults for the factomd and factom-walletd endpoints.
@@ -57,13 +93,51 @@ func NewClient() *Client {
 	return c
 }
 
-// FactomdRequest makes a request to factomd's v2 API.
+// FactomdRequest makes a request to factomd's v2 API. If the request fails,
+// other than with a JSON RPC error response, the server is marked as down and
+// the request is retried with the next server, up to FactomdRetries times.
+// If BlockSource is set, the request is made to it instead.
 func (c *Client) FactomdRequest(method string, params, result interface{}) error {
-	url := c.FactomdServer + "/v2"
+	if c.BlockSource != nil {
+		return c.BlockSource.FactomdRequest(method, params, result)
+	}
+	servers := c.factomdServers()
+	delay := c.FactomdRetryDelay
+	var err error
+	for i := uint64(0); ; i++ {
+		server := servers[i%uint64(len(servers))]
+		if err = c.factomdRequest(server, method, params,
+			result); err == nil {
+			factomdHealth.up(server)
+			return nil
+		}
+		if _, ok := err.(jrpc.Error); ok {
+			// factomd responded, so the server is up.
+			factomdHealth.up(server)
+			return err
+		}
+		factomdHealth.down(server)
+		if i >= c.FactomdRetries {
+			return err
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+func (c *Client) factomdRequest(server, method string,
+	params, result interface{}) error {
+	url := server + "/v2"
 	if c.Factomd.DebugRequest {
 		fmt.Println("factomd:", url)
 	}
-	return c.Factomd.Request(url, method, params, result)
+	if c.FactomdRequestHook == nil {
+		return c.Factomd.Request(url, method, params, result)
+	}
+	start := time.Now()
+	err := c.Factomd.Request(url, method, params, result)
+	c.FactomdRequestHook(method, time.Since(start), err)
+	return err
 }
 
 // WalletdRequest makes a request to factom-walletd's v2 API.
@@ -35,13 +35,12 @@ import (
 	merkle "github.com/AdamSLevy/go-merkle"
 )
 
+// The ChainIDs of the Admin, Entry Credit and Factoid Blocks, which are the
+// first three EBlocks of every DBlock.
 var (
-	mainnetID = [...]byte{0xFA, 0x92, 0xE5, 0xA2}
-	testnetID = [...]byte{0xFA, 0x92, 0xE5, 0xA3}
-
-	adminBlockChainID       = Bytes32{31: 0x0a}
-	entryCreditBlockChainID = Bytes32{31: 0x0c}
-	factoidBlockChainID     = Bytes32{31: 0x0f}
+	AdminBlockChainID       = Bytes32{31: 0x0a}
+	EntryCreditBlockChainID = Bytes32{31: 0x0c}
+	FactoidBlockChainID     = Bytes32{31: 0x0f}
 )
 
 // DBlock represents a Factom Directory Block.
@@ -57,7 +56,7 @@ type DBlock struct {
 }
 
 type DBlockHeader struct {
-	NetworkID [4]byte `json:"networkid"`
+	NetworkID NetworkID `json:"networkid"`
 
 	BodyMR       *Bytes32 `json:"bodymr"`
 	PrevKeyMR    *Bytes32 `json:"prevkeymr"`
@@ -126,16 +125,11 @@ func (db *DBlock) Get(c *Client) (err error) {
 	}()
 
 	if db.KeyMR != nil {
-		params := struct {
-			Hash *Bytes32 `json:"hash"`
-		}{Hash: db.KeyMR}
-		var result struct {
-			Data Bytes `json:"data"`
-		}
-		if err := c.FactomdRequest("raw-data", params, &result); err != nil {
+		data, err := c.getRawData(db.KeyMR, validDBlock(*db.KeyMR))
+		if err != nil {
 			return err
 		}
-		return db.UnmarshalBinary(result.Data)
+		return db.UnmarshalBinary(data)
 	}
 
 	params := struct {
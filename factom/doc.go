@@ -37,7 +37,9 @@
 // populated by a successful call to Get.
 //
 // The DBlock, EBlock and Entry types allow for exploring the Factom
-// blockchain.
+// blockchain. The ABlock, ECBlock and FBlock types allow for inspecting the
+// Admin, Entry Credit and Factoid Blocks referenced by each DBlock, such as
+// the Entry Credits spent on each Entry and the Factoid Transactions.
 //
 // The Bytes and Bytes32 types are used by other types when JSON marshaling and
 // unmarshaling to and from hex encoded data is required. Bytes32 is used for
@@ -54,7 +56,8 @@
 // and reveal data, if the private entry credit key is available locally. See
 // Entry.Create and Entry.ComposeCreate.
 //
-// This package does not yet support Factoid transactions, nor does it support
-// the binary data structures for DBlocks or EBlocks. Additionally, working
-// with Identity Chains is not yet supported beyond querying the ID1Key.
+// This package does not yet support creating Factoid transactions.
+// Additionally, working with Identity Chains is not yet supported beyond
+// querying the ID1Key declared by the first entry. Later entries, including
+// key replacements, are ignored.
 package factom
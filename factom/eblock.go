@@ -92,17 +92,12 @@ func (eb *EBlock) Get(c *Client) error {
 	}
 
 	// Make RPC request for this Entry Block.
-	params := struct {
-		KeyMR *Bytes32 `json:"hash"`
-	}{KeyMR: eb.KeyMR}
-	var result struct {
-		Data Bytes `json:"data"`
-	}
-	if err := c.FactomdRequest("raw-data", params, &result); err != nil {
+	data, err := c.getRawData(eb.KeyMR, validEBlock(*eb.KeyMR))
+	if err != nil {
 		return err
 	}
 	height := eb.Height
-	if err := eb.UnmarshalBinary(result.Data); err != nil {
+	if err := eb.UnmarshalBinary(data); err != nil {
 		return err
 	}
 	// Verify height if it was initialized
@@ -0,0 +1,279 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package factom
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"time"
+)
+
+// ECBlock represents a Factom Entry Credit Block, which records the Entry
+// Credits spent on commits and purchased by Factoid Transactions.
+type ECBlock struct {
+	// HeaderHash is the hash referenced by the DBlock and the next
+	// ECBlock.
+	HeaderHash *Bytes32
+	FullHash   *Bytes32
+
+	Header ECBlockHeader
+
+	Entries []ECBlockEntry
+}
+
+type ECBlockHeader struct {
+	BodyHash       *Bytes32
+	PrevHeaderHash *Bytes32
+	PrevFullHash   *Bytes32
+
+	Height uint32
+
+	ExpansionArea Bytes
+
+	ObjectCount uint64
+}
+
+// IsPopulated returns true if ecb has already been successfully populated by
+// a call to Get.
+func (ecb ECBlock) IsPopulated() bool {
+	return ecb.HeaderHash != nil &&
+		ecb.FullHash != nil &&
+		ecb.Header.BodyHash != nil &&
+		ecb.Header.PrevHeaderHash != nil &&
+		ecb.Header.PrevFullHash != nil
+}
+
+// Get queries factomd for the Entry Credit Block with ecb.HeaderHash, if not
+// nil, and otherwise for the Entry Credit Block at ecb.Header.Height. The
+// HeaderHash of an Entry Credit Block is the KeyMR of the second EBlock in a
+// DBlock.
+func (ecb *ECBlock) Get(c *Client) error {
+	if ecb.IsPopulated() {
+		return nil
+	}
+	if ecb.HeaderHash != nil {
+		headerHash := *ecb.HeaderHash
+		data, err := c.getRawData(&headerHash, validECBlock(headerHash))
+		if err != nil {
+			return err
+		}
+		if err := ecb.UnmarshalBinary(data); err != nil {
+			return err
+		}
+		if *ecb.HeaderHash != headerHash {
+			return fmt.Errorf("invalid header hash")
+		}
+		return nil
+	}
+
+	params := struct {
+		Height uint32 `json:"height"`
+	}{ecb.Header.Height}
+	var result struct {
+		Data Bytes `json:"rawdata"`
+	}
+	if err := c.FactomdRequest("ecblock-by-height", params, &result); err != nil {
+		return err
+	}
+	height := ecb.Header.Height
+	if err := ecb.UnmarshalBinary(result.Data); err != nil {
+		return err
+	}
+	if ecb.Header.Height != height {
+		return fmt.Errorf("height does not match")
+	}
+	return nil
+}
+
+// ECBlockEntry is implemented by the types of entries in an Entry Credit
+// Block.
+type ECBlockEntry interface {
+	// Type returns the Entry Credit Block entry type byte.
+	Type() byte
+}
+
+// Entry Credit Block entry type bytes.
+const (
+	ECBlockServerIndexType byte = iota
+	ECBlockMinuteType
+	ECBlockChainCommitType
+	ECBlockEntryCommitType
+	ECBlockBalanceIncreaseType
+)
+
+// ECBlockServerIndex marks the start of the entries processed by the server
+// with the given index.
+type ECBlockServerIndex struct {
+	ServerIndex uint8
+}
+
+// ECBlockMinute marks the end of the given Minute, from 1 to 10.
+type ECBlockMinute struct {
+	Minute uint8
+}
+
+// ECBlockChainCommit is the commit of the first Entry in a new chain.
+type ECBlockChainCommit struct {
+	Version     uint8
+	Timestamp   time.Time
+	ChainIDHash *Bytes32
+	Weld        *Bytes32
+	EntryHash   *Bytes32
+	// Credits includes the NewChainCost.
+	Credits   uint8
+	ECAddress ECAddress
+	Signature Bytes
+}
+
+// ECBlockEntryCommit is the commit of an Entry in an existing chain.
+type ECBlockEntryCommit struct {
+	Version   uint8
+	Timestamp time.Time
+	EntryHash *Bytes32
+	Credits   uint8
+	ECAddress ECAddress
+	Signature Bytes
+}
+
+// ECBlockBalanceIncrease records the Entry Credits purchased by the output at
+// Index of the Factoid Transaction with TxID.
+type ECBlockBalanceIncrease struct {
+	ECAddress ECAddress
+	TxID      *Bytes32
+	Index     uint64
+	Credits   uint64
+}
+
+func (ECBlockServerIndex) Type() byte     { return ECBlockServerIndexType }
+func (ECBlockMinute) Type() byte          { return ECBlockMinuteType }
+func (ECBlockChainCommit) Type() byte     { return ECBlockChainCommitType }
+func (ECBlockEntryCommit) Type() byte     { return ECBlockEntryCommitType }
+func (ECBlockBalanceIncrease) Type() byte { return ECBlockBalanceIncreaseType }
+
+// UnmarshalBinary unmarshals raw entry credit block data and computes the
+// HeaderHash and FullHash.
+//
+// Header
+// [Entry Credit Block ChainID (Bytes32{31:0x0c})] +
+// [BodyHash (Bytes32)] +
+// [PrevHeaderHash (Bytes32)] +
+// [PrevFullHash (Bytes32)] +
+// [DB Height (4 bytes)] +
+// [Header Expansion Size (varint_f)] +
+// [Header Expansion Area (Bytes)] +
+// [Object Count (8 bytes)] +
+// [Body Size (8 bytes)]
+//
+// Body
+// [Entry Type (1 byte)] + [Entry (Bytes)] +
+// ...
+//
+// https://github.com/FactomProject/FactomDocs/blob/master/factomDataStructureDetails.md#entry-credit-block
+func (ecb *ECBlock) UnmarshalBinary(data []byte) error {
+	r := blockReader{data: data}
+	chainID := r.Bytes32()
+	var header ECBlockHeader
+	header.BodyHash = r.Bytes32()
+	header.PrevHeaderHash = r.Bytes32()
+	header.PrevFullHash = r.Bytes32()
+	header.Height = r.Uint32()
+	header.ExpansionArea = r.Bytes(int(r.VarInt()))
+	header.ObjectCount = r.Uint64()
+	bodySize := r.Uint64()
+	if r.err != nil {
+		return r.err
+	}
+	if *chainID != EntryCreditBlockChainID {
+		return fmt.Errorf("invalid chain id")
+	}
+	if uint64(r.Len()) != bodySize {
+		return fmt.Errorf("invalid body size")
+	}
+	headerLen := r.i
+	if sha256.Sum256(data[headerLen:]) != *header.BodyHash {
+		return fmt.Errorf("invalid body hash")
+	}
+
+	var entries []ECBlockEntry
+	for r.Len() > 0 {
+		var e ECBlockEntry
+		switch entryType := r.Uint8(); entryType {
+		case ECBlockServerIndexType:
+			e = ECBlockServerIndex{ServerIndex: r.Uint8()}
+		case ECBlockMinuteType:
+			e = ECBlockMinute{Minute: r.Uint8()}
+		case ECBlockChainCommitType:
+			e = ECBlockChainCommit{Version: r.Uint8(),
+				Timestamp:   r.Timestamp(),
+				ChainIDHash: r.Bytes32(), Weld: r.Bytes32(),
+				EntryHash: r.Bytes32(), Credits: r.Uint8(),
+				ECAddress: ECAddress(*r.Bytes32()),
+				Signature: r.Bytes(SignatureSize)}
+		case ECBlockEntryCommitType:
+			e = ECBlockEntryCommit{Version: r.Uint8(),
+				Timestamp: r.Timestamp(),
+				EntryHash: r.Bytes32(), Credits: r.Uint8(),
+				ECAddress: ECAddress(*r.Bytes32()),
+				Signature: r.Bytes(SignatureSize)}
+		case ECBlockBalanceIncreaseType:
+			e = ECBlockBalanceIncrease{
+				ECAddress: ECAddress(*r.Bytes32()),
+				TxID:      r.Bytes32(), Index: r.VarInt(),
+				Credits: r.VarInt()}
+		default:
+			return fmt.Errorf("unsupported entry credit block entry type: %#x",
+				entryType)
+		}
+		if r.err != nil {
+			return r.err
+		}
+		entries = append(entries, e)
+	}
+	if uint64(len(entries)) != header.ObjectCount {
+		return fmt.Errorf("invalid object count")
+	}
+
+	ecb.Header = header
+	ecb.Entries = entries
+	ecb.HeaderHash = new(Bytes32)
+	*ecb.HeaderHash = sha256.Sum256(data[:headerLen])
+	ecb.FullHash = new(Bytes32)
+	*ecb.FullHash = sha256.Sum256(data)
+	return nil
+}
+
+// EntryCredits returns the Entry Credits paid for each Entry committed in
+// ecb, by Entry Hash. The Entry Credits paid to create a chain include the
+// NewChainCost.
+func (ecb ECBlock) EntryCredits() map[Bytes32]uint8 {
+	credits := make(map[Bytes32]uint8)
+	for _, e := range ecb.Entries {
+		switch e := e.(type) {
+		case ECBlockChainCommit:
+			credits[*e.EntryHash] = e.Credits
+		case ECBlockEntryCommit:
+			credits[*e.EntryHash] = e.Credits
+		}
+	}
+	return credits
+}
@@ -91,19 +91,14 @@ func (e *Entry) Get(c *Client) error {
 	if e.IsPopulated() {
 		return nil
 	}
-	params := struct {
-		Hash *Bytes32 `json:"hash"`
-	}{Hash: e.Hash}
-	var result struct {
-		Data Bytes `json:"data"`
-	}
-	if err := c.FactomdRequest("raw-data", params, &result); err != nil {
+	data, err := c.getRawData(e.Hash, validEntry(*e.Hash))
+	if err != nil {
 		return err
 	}
-	if EntryHash(result.Data) != *e.Hash {
+	if EntryHash(data) != *e.Hash {
 		return fmt.Errorf("invalid hash")
 	}
-	return e.UnmarshalBinary(result.Data)
+	return e.UnmarshalBinary(data)
 }
 
 type chainFirstEntryParams struct {
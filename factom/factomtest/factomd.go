@@ -0,0 +1,410 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package factomtest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"net/http"
+
+	jrpc "github.com/AdamSLevy/jsonrpc2/v11"
+	"golang.org/x/crypto/ed25519"
+
+	"github.com/Factom-Asset-Tokens/fatd/factom"
+)
+
+// Errors returned by the factomd API, with the same codes as factomd.
+var (
+	ErrorBlockNotFound  = jrpc.Error{Code: -32008, Message: "Block not found"}
+	ErrorEntryNotFound  = jrpc.Error{Code: -32008, Message: "Entry not found"}
+	ErrorObjectNotFound = jrpc.Error{Code: -32008, Message: "Object not found"}
+	ErrorRepeatedCommit = jrpc.Error{Code: -32011, Message: "Repeated Commit"}
+)
+
+// ServeHTTP serves the factomd API methods used by fatd: "heights",
+// "dblock-by-height", "raw-data", "entry-block", "entry", "chain-head",
+// "pending-entries", "commit-chain", "commit-entry", "reveal-chain",
+// "reveal-entry", "entry-credit-balance" and "factoid-balance".
+func (l *Ledger) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	serveJRPC(w, r, map[string]method{
+		"heights":              l.heights,
+		"dblock-by-height":     l.dblockByHeight,
+		"raw-data":             l.rawData,
+		"entry-block":          l.entryBlock,
+		"entry":                l.entry,
+		"chain-head":           l.chainHead,
+		"pending-entries":      l.pendingEntries,
+		"commit-chain":         l.commit,
+		"commit-entry":         l.commit,
+		"reveal-chain":         l.reveal,
+		"reveal-entry":         l.reveal,
+		"entry-credit-balance": l.ecBalance,
+		"factoid-balance":      l.fctBalance,
+	})
+}
+
+type paramsHeight struct {
+	Height uint32 `json:"height"`
+}
+type paramsHash struct {
+	Hash *factom.Bytes32 `json:"hash"`
+}
+type paramsKeyMR struct {
+	KeyMR *factom.Bytes32 `json:"keymr"`
+}
+type paramsChainID struct {
+	ChainID *factom.Bytes32 `json:"chainid"`
+}
+type paramsMessage struct {
+	Message factom.Bytes `json:"message"`
+}
+type paramsEntry struct {
+	Entry factom.Bytes `json:"entry"`
+}
+type paramsAddress struct {
+	Address string `json:"address"`
+}
+
+type resultBalance struct {
+	Balance uint64 `json:"balance"`
+}
+
+func (l *Ledger) heights(params json.RawMessage) (interface{}, error) {
+	height := l.Height()
+	return factom.Heights{DirectoryBlock: height, Leader: height + 1,
+		EntryBlock: height, Entry: height}, nil
+}
+
+func (l *Ledger) dblockByHeight(params json.RawMessage) (interface{}, error) {
+	var p paramsHeight
+	if err := unmarshalParams(params, &p); err != nil {
+		return nil, err
+	}
+	db, ok := l.DBlock(p.Height)
+	if !ok {
+		return nil, ErrorBlockNotFound
+	}
+	return struct {
+		DBlock *factom.DBlock `json:"dblock"`
+	}{&db}, nil
+}
+
+func (l *Ledger) rawData(params json.RawMessage) (interface{}, error) {
+	var p paramsHash
+	if err := unmarshalParams(params, &p); err != nil {
+		return nil, err
+	}
+	if p.Hash == nil {
+		return nil, *jrpc.InvalidParams("hash is required")
+	}
+	l.mu.RLock()
+	data, ok := l.raw[*p.Hash]
+	l.mu.RUnlock()
+	if !ok {
+		return nil, ErrorObjectNotFound
+	}
+	return struct {
+		Data factom.Bytes `json:"data"`
+	}{data}, nil
+}
+
+func (l *Ledger) entryBlock(params json.RawMessage) (interface{}, error) {
+	var p paramsKeyMR
+	if err := unmarshalParams(params, &p); err != nil {
+		return nil, err
+	}
+	if p.KeyMR == nil {
+		return nil, *jrpc.InvalidParams("keymr is required")
+	}
+	l.mu.RLock()
+	data, ok := l.raw[*p.KeyMR]
+	l.mu.RUnlock()
+	var eb factom.EBlock
+	if !ok || eb.UnmarshalBinary(data) != nil {
+		return nil, ErrorBlockNotFound
+	}
+	db, _ := l.DBlock(eb.Height)
+	type entryListItem struct {
+		Hash      *factom.Bytes32 `json:"entryhash"`
+		Timestamp int64           `json:"timestamp"`
+	}
+	result := struct {
+		Header struct {
+			Sequence  uint32          `json:"blocksequencenumber"`
+			ChainID   *factom.Bytes32 `json:"chainid"`
+			PrevKeyMR *factom.Bytes32 `json:"prevkeymr"`
+			Timestamp int64           `json:"timestamp"`
+			Height    uint32          `json:"dbheight"`
+		} `json:"header"`
+		EntryList []entryListItem `json:"entrylist"`
+	}{EntryList: make([]entryListItem, len(eb.Entries))}
+	result.Header.Sequence = eb.Sequence
+	result.Header.ChainID = eb.ChainID
+	result.Header.PrevKeyMR = eb.PrevKeyMR
+	result.Header.Timestamp = db.Header.Timestamp.Unix()
+	result.Header.Height = eb.Height
+	for i, e := range eb.Entries {
+		result.EntryList[i] = entryListItem{Hash: e.Hash,
+			Timestamp: db.Header.Timestamp.Add(
+				e.Timestamp.Sub(eb.Timestamp)).Unix()}
+	}
+	return result, nil
+}
+
+func (l *Ledger) entry(params json.RawMessage) (interface{}, error) {
+	var p paramsHash
+	if err := unmarshalParams(params, &p); err != nil {
+		return nil, err
+	}
+	if p.Hash == nil {
+		return nil, *jrpc.InvalidParams("hash is required")
+	}
+	l.mu.RLock()
+	data, ok := l.raw[*p.Hash]
+	l.mu.RUnlock()
+	var e factom.Entry
+	if !ok || e.UnmarshalBinary(data) != nil {
+		return nil, ErrorEntryNotFound
+	}
+	return struct {
+		ChainID *factom.Bytes32 `json:"chainid"`
+		Content factom.Bytes    `json:"content"`
+		ExtIDs  []factom.Bytes  `json:"extids"`
+	}{e.ChainID, e.Content, e.ExtIDs}, nil
+}
+
+func (l *Ledger) chainHead(params json.RawMessage) (interface{}, error) {
+	var p paramsChainID
+	if err := unmarshalParams(params, &p); err != nil {
+		return nil, err
+	}
+	if p.ChainID == nil {
+		return nil, *jrpc.InvalidParams("chainid is required")
+	}
+	result := struct {
+		KeyMR              *factom.Bytes32 `json:"chainhead"`
+		ChainInProcessList bool            `json:"chaininprocesslist"`
+	}{KeyMR: new(factom.Bytes32)}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	// Like factomd, a chain that does not exist has a zero chain head.
+	if head, ok := l.heads[*p.ChainID]; ok {
+		result.KeyMR = head.KeyMR
+	}
+	result.ChainInProcessList = l.isPending(*p.ChainID)
+	return result, nil
+}
+
+func (l *Ledger) pendingEntries(params json.RawMessage) (interface{}, error) {
+	type pendingEntry struct {
+		Hash    *factom.Bytes32 `json:"entryhash"`
+		ChainID *factom.Bytes32 `json:"chainid"`
+		Status  string          `json:"status"`
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	result := make([]pendingEntry, 0, len(l.pending)+len(l.commits))
+	for _, e := range l.pending {
+		result = append(result, pendingEntry{Hash: e.Hash,
+			ChainID: e.ChainID, Status: "TransactionACK"})
+	}
+	// Entries that are committed but not revealed have no ChainID.
+	for hash := range l.commits {
+		hash := hash
+		result = append(result, pendingEntry{Hash: &hash,
+			Status: "NotConfirmed"})
+	}
+	return result, nil
+}
+
+// Lengths of the commit data of an Entry and of a new chain, and the offsets
+// of the Entry Hash within them.
+const (
+	commitLen             = 1 + 6 + 32 + 1 + 32 + 64
+	chainCommitLen        = 1 + 6 + 32 + 32 + 32 + 1 + 32 + 64
+	commitHashOffset      = 1 + 6
+	chainCommitHashOffset = 1 + 6 + 32 + 32
+)
+
+// commit verifies the signature of the commit data and deducts its cost from
+// the balance of the Entry Credit address that signed it.
+func (l *Ledger) commit(params json.RawMessage) (interface{}, error) {
+	var p paramsMessage
+	if err := unmarshalParams(params, &p); err != nil {
+		return nil, err
+	}
+	commit := p.Message
+	var i int
+	switch len(commit) {
+	case commitLen:
+		i = commitHashOffset
+	case chainCommitLen:
+		i = chainCommitHashOffset
+	default:
+		return nil, *jrpc.InvalidParams("invalid commit length")
+	}
+	var hash factom.Bytes32
+	i += copy(hash[:], commit[i:])
+	cost := uint64(commit[i])
+	i++
+	signed := commit[:i]
+	var ec factom.ECAddress
+	i += copy(ec[:], commit[i:])
+	if !ed25519.Verify(ec[:], signed, commit[i:]) {
+		return nil, *jrpc.InvalidParams("invalid commit signature")
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.commits[hash]; ok {
+		return nil, ErrorRepeatedCommit
+	}
+	if _, ok := l.raw[hash]; ok {
+		return nil, ErrorRepeatedCommit
+	}
+	if l.ecBalances[ec] < cost {
+		return nil, *jrpc.InvalidParams("insufficient Entry Credit balance")
+	}
+	l.ecBalances[ec] -= cost
+	l.commits[hash] = len(commit) == chainCommitLen
+
+	txID := factom.Bytes32(sha256.Sum256(signed))
+	return struct {
+		Message string          `json:"message"`
+		TxID    *factom.Bytes32 `json:"txid"`
+		Hash    *factom.Bytes32 `json:"entryhash"`
+	}{"Entry Commit Success", &txID, &hash}, nil
+}
+
+// reveal adds the revealed Entry to the pending Entries, if it was committed.
+func (l *Ledger) reveal(params json.RawMessage) (interface{}, error) {
+	var p paramsEntry
+	if err := unmarshalParams(params, &p); err != nil {
+		return nil, err
+	}
+	var e factom.Entry
+	if err := e.UnmarshalBinary(p.Entry); err != nil {
+		return nil, *jrpc.InvalidParams(err.Error())
+	}
+	hash := factom.EntryHash(p.Entry)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	newChain, ok := l.commits[hash]
+	if !ok {
+		return nil, *jrpc.InvalidParams("entry has not been committed")
+	}
+	if newChain && *e.ChainID != factom.ChainID(e.ExtIDs) {
+		return nil, *jrpc.InvalidParams("invalid chain id")
+	}
+	if _, err := l.addEntry(e, newChain); err != nil {
+		return nil, *jrpc.InvalidParams(err.Error())
+	}
+	delete(l.commits, hash)
+	return struct {
+		Message string          `json:"message"`
+		Hash    *factom.Bytes32 `json:"entryhash"`
+		ChainID *factom.Bytes32 `json:"chainid"`
+	}{"Entry Reveal Success", &hash, e.ChainID}, nil
+}
+
+func (l *Ledger) ecBalance(params json.RawMessage) (interface{}, error) {
+	var p paramsAddress
+	if err := unmarshalParams(params, &p); err != nil {
+		return nil, err
+	}
+	adr, err := factom.NewECAddress(p.Address)
+	if err != nil {
+		return nil, *jrpc.InvalidParams(err.Error())
+	}
+	return resultBalance{l.ECBalance(adr)}, nil
+}
+
+func (l *Ledger) fctBalance(params json.RawMessage) (interface{}, error) {
+	var p paramsAddress
+	if err := unmarshalParams(params, &p); err != nil {
+		return nil, err
+	}
+	adr, err := factom.NewFAAddress(p.Address)
+	if err != nil {
+		return nil, *jrpc.InvalidParams(err.Error())
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return resultBalance{l.fctBalances[adr]}, nil
+}
+
+// method is a JSON RPC method. A returned error that is not a jrpc.Error is
+// returned to the client as an Internal Error.
+type method func(params json.RawMessage) (interface{}, error)
+
+// serveJRPC serves a single JSON RPC 2.0 request using methods. Unlike
+// jrpc.HTTPRequestHandler, methods may return errors with the reserved codes
+// that factomd and factom-walletd use.
+func serveJRPC(w http.ResponseWriter, r *http.Request, methods map[string]method) {
+	var req struct {
+		ID     interface{}     `json:"id"`
+		Method string          `json:"method"`
+		Params json.RawMessage `json:"params"`
+	}
+	res := struct {
+		JSONRPC string      `json:"jsonrpc"`
+		ID      interface{} `json:"id"`
+		Result  interface{} `json:"result,omitempty"`
+		Error   *jrpc.Error `json:"error,omitempty"`
+	}{JSONRPC: "2.0"}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		res.Error = jrpc.NewError(jrpc.ParseErrorCode,
+			jrpc.ParseErrorMessage, err.Error())
+	} else if method, ok := methods[req.Method]; !ok {
+		res.ID = req.ID
+		res.Error = jrpc.NewError(jrpc.MethodNotFoundCode,
+			jrpc.MethodNotFoundMessage, req.Method)
+	} else {
+		res.ID = req.ID
+		result, err := method(req.Params)
+		switch err := err.(type) {
+		case nil:
+			res.Result = result
+		case jrpc.Error:
+			res.Error = &err
+		default:
+			res.Error = jrpc.NewError(jrpc.InternalErrorCode,
+				jrpc.InternalErrorMessage, err.Error())
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+// unmarshalParams unmarshals params into v, which may be omitted.
+func unmarshalParams(params json.RawMessage, v interface{}) error {
+	if len(params) == 0 || bytes.Equal(params, []byte("null")) {
+		return nil
+	}
+	if err := json.Unmarshal(params, v); err != nil {
+		return *jrpc.InvalidParams(err.Error())
+	}
+	return nil
+}
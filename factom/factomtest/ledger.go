@@ -0,0 +1,393 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+// Package factomtest provides in-memory implementations of the factomd and
+// factom-walletd APIs for testing.
+//
+// A Ledger is a simulated Factom blockchain that serves the factomd API
+// methods used by fatd. Entries are added to the Ledger either directly, using
+// the Add methods, or by committing and revealing them over the API, and are
+// pending until they are included in the next DBlock created by NewDBlock.
+//
+// A Walletd serves the factom-walletd address and compose methods for a set of
+// private addresses.
+//
+// Both are http.Handlers and are typically used with httptest.NewServer.
+package factomtest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Factom-Asset-Tokens/fatd/factom"
+	"github.com/Factom-Asset-Tokens/fatd/fat"
+	"github.com/Factom-Asset-Tokens/fatd/fat/fat0"
+	"github.com/Factom-Asset-Tokens/fatd/fat/fat1"
+)
+
+// LocalnetID is the NetworkID of the DBlocks of a Ledger. It is the NetworkID
+// used by a local factomd network, so fatd treats it as a custom network with
+// a FAT activation height of 0.
+var LocalnetID = factom.NetworkID{0xFA, 0x92, 0xE5, 0xA4}
+
+// Ledger is an in-memory Factom blockchain. It is safe for concurrent use.
+type Ledger struct {
+	mu sync.RWMutex
+
+	dblocks []factom.DBlock
+	// heads holds the header of the latest EBlock of each chain.
+	heads map[factom.Bytes32]factom.EBlock
+	// raw holds the binary data of all DBlocks, EBlocks and revealed
+	// Entries by KeyMR or Entry Hash.
+	raw map[factom.Bytes32][]byte
+
+	// pending holds the revealed Entries that are not yet in a DBlock.
+	pending []factom.Entry
+	// commits holds the Entry Hashes of the committed Entries that have
+	// not been revealed, and whether they commit a new chain.
+	commits map[factom.Bytes32]bool
+
+	ecBalances  map[factom.ECAddress]uint64
+	fctBalances map[factom.FAAddress]uint64
+}
+
+// NewLedger returns a Ledger with a single, empty DBlock at height 0.
+func NewLedger() *Ledger {
+	l := &Ledger{
+		heads:       make(map[factom.Bytes32]factom.EBlock),
+		raw:         make(map[factom.Bytes32][]byte),
+		commits:     make(map[factom.Bytes32]bool),
+		ecBalances:  make(map[factom.ECAddress]uint64),
+		fctBalances: make(map[factom.FAAddress]uint64),
+	}
+	if _, err := l.NewDBlock(); err != nil {
+		// An empty DBlock is always valid.
+		panic(err)
+	}
+	return l
+}
+
+// Height returns the height of the latest DBlock.
+func (l *Ledger) Height() uint32 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return uint32(len(l.dblocks) - 1)
+}
+
+// DBlock returns the DBlock at height, and whether it exists. The EBlocks
+// only have their ChainID and KeyMR, just like DBlock.Get.
+func (l *Ledger) DBlock(height uint32) (factom.DBlock, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if int(height) >= len(l.dblocks) {
+		return factom.DBlock{}, false
+	}
+	return l.dblocks[height], true
+}
+
+// PendingEntries returns the revealed Entries that are not yet in a DBlock, in
+// the order they were added.
+func (l *Ledger) PendingEntries() []factom.Entry {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return append([]factom.Entry(nil), l.pending...)
+}
+
+// SetECBalance sets the Entry Credit balance of adr, which pays for Entries
+// committed over the API.
+func (l *Ledger) SetECBalance(adr factom.ECAddress, balance uint64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.ecBalances[adr] = balance
+}
+
+// ECBalance returns the Entry Credit balance of adr.
+func (l *Ledger) ECBalance(adr factom.ECAddress) uint64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.ecBalances[adr]
+}
+
+// SetFCTBalance sets the Factoid balance of adr in factoshis.
+func (l *Ledger) SetFCTBalance(adr factom.FAAddress, balance uint64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.fctBalances[adr] = balance
+}
+
+// AddEntry adds e to the pending Entries and returns its Entry Hash. If
+// e.ChainID is nil, e is the first Entry of a new chain with the ChainID
+// computed from its ExtIDs, and the chain must not already exist. Otherwise
+// the chain must already exist or be pending.
+func (l *Ledger) AddEntry(e factom.Entry) (factom.Bytes32, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.addEntry(e, e.ChainID == nil)
+}
+
+// AddIdentity adds the first Entry of a new Identity Chain with the given
+// ID1Key and returns its ChainID. Real Identity ChainIDs are mined to begin
+// with 888888, which is simulated by overwriting the first three bytes of the
+// ChainID computed from the ExtIDs.
+func (l *Ledger) AddIdentity(id1 factom.ID1Key) (factom.Bytes32, error) {
+	var id2 factom.ID2Key
+	var id3 factom.ID3Key
+	var id4 factom.ID4Key
+	nameIDs := []factom.Bytes{{0x00}, factom.Bytes("Identity Chain"),
+		id1[:], id2[:], id3[:], id4[:], factom.Bytes("factomtest")}
+	chainID := factom.ChainID(nameIDs)
+	copy(chainID[:], []byte{0x88, 0x88, 0x88})
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.addEntry(factom.Entry{ChainID: &chainID,
+		ExtIDs: nameIDs, Content: factom.Bytes{}}, true); err != nil {
+		return factom.Bytes32{}, err
+	}
+	return chainID, nil
+}
+
+// AddTokenChain adds the first Entry of the token chain for tokenID and issuer
+// and returns its ChainID.
+func (l *Ledger) AddTokenChain(tokenID string,
+	issuer factom.Bytes32) (factom.Bytes32, error) {
+	e := factom.Entry{ExtIDs: fat.NameIDs(tokenID, issuer),
+		Content: factom.Bytes{}}
+	if _, err := l.AddEntry(e); err != nil {
+		return factom.Bytes32{}, err
+	}
+	return fat.ChainID(tokenID, issuer), nil
+}
+
+// AddIssuance signs issuance, which must have its ChainID set, with sk1 and
+// adds it to the pending Entries.
+func (l *Ledger) AddIssuance(issuance fat.Issuance,
+	sk1 factom.SK1Key) (factom.Bytes32, error) {
+	if err := issuance.MarshalEntry(); err != nil {
+		return factom.Bytes32{}, err
+	}
+	issuance.Sign(sk1)
+	return l.AddEntry(issuance.Entry.Entry)
+}
+
+// AddFAT0Transaction signs tx, which must have its ChainID set, with the
+// signingSet and adds it to the pending Entries.
+func (l *Ledger) AddFAT0Transaction(tx fat0.Transaction,
+	signingSet ...factom.RCDPrivateKey) (factom.Bytes32, error) {
+	if err := tx.MarshalEntry(); err != nil {
+		return factom.Bytes32{}, err
+	}
+	tx.Sign(signingSet...)
+	return l.AddEntry(tx.Entry.Entry)
+}
+
+// AddFAT1Transaction signs tx, which must have its ChainID set, with the
+// signingSet and adds it to the pending Entries.
+func (l *Ledger) AddFAT1Transaction(tx fat1.Transaction,
+	signingSet ...factom.RCDPrivateKey) (factom.Bytes32, error) {
+	if err := tx.MarshalEntry(); err != nil {
+		return factom.Bytes32{}, err
+	}
+	tx.Sign(signingSet...)
+	return l.AddEntry(tx.Entry.Entry)
+}
+
+// addEntry adds e to the pending Entries. If newChain is true, e must be the
+// first Entry of a chain that does not exist. The caller must hold the write
+// lock.
+func (l *Ledger) addEntry(e factom.Entry, newChain bool) (factom.Bytes32, error) {
+	if e.ExtIDs == nil {
+		e.ExtIDs = []factom.Bytes{}
+	}
+	if e.Content == nil {
+		e.Content = factom.Bytes{}
+	}
+	data, err := e.MarshalBinary() // Populates ChainID and Hash.
+	if err != nil {
+		return factom.Bytes32{}, err
+	}
+	if l.chainExists(*e.ChainID) == newChain {
+		if newChain {
+			return factom.Bytes32{}, fmt.Errorf(
+				"chain %v already exists", e.ChainID)
+		}
+		return factom.Bytes32{}, fmt.Errorf(
+			"chain %v does not exist", e.ChainID)
+	}
+	l.raw[*e.Hash] = data
+	l.pending = append(l.pending, factom.Entry{Hash: e.Hash,
+		ChainID: e.ChainID, ExtIDs: e.ExtIDs, Content: e.Content})
+	return *e.Hash, nil
+}
+
+// chainExists returns true if the chain has an EBlock or pending Entries. The
+// caller must hold the lock.
+func (l *Ledger) chainExists(chainID factom.Bytes32) bool {
+	if _, ok := l.heads[chainID]; ok {
+		return true
+	}
+	return l.isPending(chainID)
+}
+
+// isPending returns true if the chain has pending Entries. The caller must
+// hold the lock.
+func (l *Ledger) isPending(chainID factom.Bytes32) bool {
+	for _, e := range l.pending {
+		if *e.ChainID == chainID {
+			return true
+		}
+	}
+	return false
+}
+
+// NewDBlock creates the next DBlock from all pending Entries and returns it.
+// The DBlock has an EBlock for each chain with pending Entries, which are all
+// in the first minute, after the Admin, EC and FCT blocks. The DBlock
+// timestamp is the current time, or one minute after the previous DBlock if
+// that is later.
+func (l *Ledger) NewDBlock() (factom.DBlock, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	height := uint32(len(l.dblocks))
+	db := factom.DBlock{Header: factom.DBlockHeader{
+		NetworkID:    LocalnetID,
+		Height:       height,
+		PrevKeyMR:    new(factom.Bytes32),
+		PrevFullHash: new(factom.Bytes32),
+		Timestamp:    time.Now().Truncate(time.Minute),
+	}}
+	if height > 0 {
+		prev := l.dblocks[height-1]
+		db.Header.PrevKeyMR = prev.KeyMR
+		db.Header.PrevFullHash = prev.FullHash
+		if !db.Header.Timestamp.After(prev.Header.Timestamp) {
+			db.Header.Timestamp = prev.Header.Timestamp.Add(time.Minute)
+		}
+	}
+
+	// The Admin, EC and FCT blocks are not simulated, so they just have
+	// a unique KeyMR.
+	for _, id := range []byte{0x0a, 0x0c, 0x0f} {
+		chainID := factom.Bytes32{31: id}
+		var data [len(chainID) + 4]byte
+		copy(data[:], chainID[:])
+		binary.BigEndian.PutUint32(data[len(chainID):], height)
+		keyMR := factom.Bytes32(sha256.Sum256(data[:]))
+		db.EBlocks = append(db.EBlocks,
+			factom.EBlock{ChainID: &chainID, KeyMR: &keyMR})
+	}
+
+	// Group the pending Entries by chain, in order of ChainID.
+	var chainIDs []factom.Bytes32
+	entries := make(map[factom.Bytes32][]factom.Entry)
+	for _, e := range l.pending {
+		if _, ok := entries[*e.ChainID]; !ok {
+			chainIDs = append(chainIDs, *e.ChainID)
+		}
+		e.Timestamp = db.Header.Timestamp.Add(time.Minute)
+		e.Height = height
+		entries[*e.ChainID] = append(entries[*e.ChainID], e)
+	}
+	sort.Slice(chainIDs, func(i, j int) bool {
+		return bytes.Compare(chainIDs[i][:], chainIDs[j][:]) < 0
+	})
+
+	heads := make(map[factom.Bytes32]factom.EBlock, len(chainIDs))
+	raw := make(map[factom.Bytes32][]byte, len(chainIDs)+1)
+	for _, chainID := range chainIDs {
+		chainID := chainID
+		eb := factom.EBlock{
+			ChainID:      &chainID,
+			PrevKeyMR:    new(factom.Bytes32),
+			PrevFullHash: new(factom.Bytes32),
+			Height:       height,
+			Timestamp:    db.Header.Timestamp,
+			Entries:      entries[chainID],
+		}
+		if head, ok := l.heads[chainID]; ok {
+			fullHash, err := head.ComputeFullHash()
+			if err != nil {
+				return factom.DBlock{}, err
+			}
+			eb.PrevKeyMR = head.KeyMR
+			eb.PrevFullHash = &fullHash
+			eb.Sequence = head.Sequence + 1
+		}
+		eb.ObjectCount = eb.CountObjects()
+		bodyMR, err := eb.ComputeBodyMR()
+		if err != nil {
+			return factom.DBlock{}, err
+		}
+		eb.BodyMR = &bodyMR
+		keyMR, err := eb.ComputeKeyMR()
+		if err != nil {
+			return factom.DBlock{}, err
+		}
+		eb.KeyMR = &keyMR
+		data, err := eb.MarshalBinary()
+		if err != nil {
+			return factom.DBlock{}, err
+		}
+		raw[keyMR] = data
+		heads[chainID] = eb
+		db.EBlocks = append(db.EBlocks,
+			factom.EBlock{ChainID: &chainID, KeyMR: &keyMR})
+	}
+
+	bodyMR, err := db.ComputeBodyMR()
+	if err != nil {
+		return factom.DBlock{}, err
+	}
+	db.Header.BodyMR = &bodyMR
+	keyMR, err := db.ComputeKeyMR()
+	if err != nil {
+		return factom.DBlock{}, err
+	}
+	db.KeyMR = &keyMR
+	fullHash, err := db.ComputeFullHash()
+	if err != nil {
+		return factom.DBlock{}, err
+	}
+	db.FullHash = &fullHash
+	data, err := db.MarshalBinary()
+	if err != nil {
+		return factom.DBlock{}, err
+	}
+	raw[keyMR] = data
+
+	// Nothing is saved until the DBlock is complete.
+	for hash, data := range raw {
+		l.raw[hash] = data
+	}
+	for chainID, eb := range heads {
+		l.heads[chainID] = eb
+	}
+	l.pending = nil
+	l.dblocks = append(l.dblocks, db)
+	return db, nil
+}
@@ -0,0 +1,225 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package factomtest
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	jrpc "github.com/AdamSLevy/jsonrpc2/v11"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Factom-Asset-Tokens/fatd/factom"
+	"github.com/Factom-Asset-Tokens/fatd/fat"
+	"github.com/Factom-Asset-Tokens/fatd/fat/fat0"
+)
+
+// setupServers serves l and wd and returns a Client for them and a function
+// that closes the servers.
+func setupServers(l *Ledger, wd *Walletd) (*factom.Client, func()) {
+	factomd := httptest.NewServer(l)
+	walletd := httptest.NewServer(wd)
+	c := factom.NewClient()
+	c.FactomdServer = factomd.URL
+	c.WalletdServer = walletd.URL
+	return c, func() {
+		factomd.Close()
+		walletd.Close()
+	}
+}
+
+func TestLedger(t *testing.T) {
+	l := NewLedger()
+	c, close := setupServers(l, NewWalletd())
+	defer close()
+
+	sk1, err := factom.GenerateSK1Key()
+	require.NoError(t, err)
+	identity, err := l.AddIdentity(sk1.ID1Key())
+	require.NoError(t, err)
+	chainID, err := l.AddTokenChain("test", identity)
+	require.NoError(t, err)
+	_, err = l.AddTokenChain("test", identity)
+	assert.EqualError(t, err,
+		"chain "+chainID.String()+" already exists")
+
+	issuance := fat.NewIssuance(factom.Entry{ChainID: &chainID})
+	issuance.Type = fat0.Type
+	issuance.Supply = -1
+	_, err = l.AddIssuance(issuance, sk1)
+	require.NoError(t, err)
+
+	var pe factom.PendingEntries
+	require.NoError(t, pe.Get(c))
+	assert.Len(t, pe, 3)
+
+	// The chains do not have a chain head until they are in a DBlock.
+	eb := factom.EBlock{ChainID: &chainID}
+	err = eb.GetChainHead(c)
+	require.IsType(t, jrpc.Error{}, err)
+	assert.Equal(t, "new chain in process list", err.(jrpc.Error).Message)
+
+	first, err := l.NewDBlock()
+	require.NoError(t, err)
+	assert.Equal(t, uint32(1), first.Header.Height)
+	require.NoError(t, pe.Get(c))
+	assert.Empty(t, pe)
+
+	var heights factom.Heights
+	require.NoError(t, heights.Get(c))
+	assert.Equal(t, uint32(1), heights.Entry)
+
+	db := factom.DBlock{Header: factom.DBlockHeader{Height: 1}}
+	require.NoError(t, db.Get(c))
+	assert.Equal(t, *first.KeyMR, *db.KeyMR)
+	assert.Equal(t, LocalnetID, db.Header.NetworkID)
+	require.NoError(t, db.VerifyFullHash())
+	genesis, ok := l.DBlock(0)
+	require.True(t, ok)
+	require.NoError(t, db.VerifyPrev(genesis))
+	// Admin, EC, FCT, and the Identity and token chains.
+	require.Len(t, db.EBlocks, 5)
+
+	id := factom.NewIdentity(&identity)
+	require.NoError(t, id.Get(c))
+	assert.Equal(t, sk1.ID1Key(), id.ID1)
+
+	// EBlocks from a DBlock have its Timestamp, so that the Entries have
+	// a Timestamp to validate the Issuance signature against.
+	for _, eb = range db.EBlocks {
+		if *eb.ChainID == chainID {
+			break
+		}
+	}
+	require.NoError(t, eb.Get(c))
+	assert.True(t, eb.IsFirst())
+	require.NoError(t, eb.VerifyDBlock(db))
+	require.Len(t, eb.Entries, 2)
+	for i := range eb.Entries {
+		e := &eb.Entries[i]
+		require.NoError(t, e.Get(c))
+		require.NoError(t, eb.VerifyEntry(*e))
+	}
+	assert.Equal(t, fat.NameIDs("test", identity), eb.Entries[0].ExtIDs)
+	got := fat.NewIssuance(eb.Entries[1])
+	require.NoError(t, got.UnmarshalEntry())
+	assert.NoError(t, got.Valid(id.ID1))
+
+	db = factom.DBlock{Header: factom.DBlockHeader{Height: 2}}
+	assert.Equal(t, ErrorBlockNotFound, db.Get(c))
+}
+
+func TestLedgerCommitReveal(t *testing.T) {
+	es, err := factom.GenerateEsAddress()
+	require.NoError(t, err)
+	ec := es.ECAddress()
+	l := NewLedger()
+	l.SetECBalance(ec, 12)
+	c, close := setupServers(l, NewWalletd(es))
+	defer close()
+
+	// A new chain costs 11 Entry Credits.
+	e := factom.Entry{ExtIDs: []factom.Bytes{factom.Bytes("chain")},
+		Content: factom.Bytes("first")}
+	_, err = e.Create(c, ec)
+	require.NoError(t, err)
+	balance, err := ec.GetBalance(c)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), balance)
+
+	commit, reveal, _, err := e.Compose(es)
+	require.NoError(t, err)
+	assert.Equal(t, ErrorRepeatedCommit, c.Commit(commit))
+
+	second := factom.Entry{ChainID: e.ChainID,
+		Content: factom.Bytes("second")}
+	_, err = second.ComposeCreate(c, es)
+	require.NoError(t, err)
+
+	third := factom.Entry{ChainID: e.ChainID,
+		Content: factom.Bytes("third")}
+	_, reveal, _, err = third.Compose(es)
+	require.NoError(t, err)
+	err = c.Reveal(reveal)
+	require.IsType(t, jrpc.Error{}, err)
+	assert.Equal(t, jrpc.InvalidParamsCode, err.(jrpc.Error).Code)
+	_, err = third.ComposeCreate(c, es)
+	require.IsType(t, jrpc.Error{}, err)
+	assert.Equal(t, "insufficient Entry Credit balance",
+		err.(jrpc.Error).Data)
+
+	var pe factom.PendingEntries
+	require.NoError(t, pe.Get(c))
+	require.Len(t, pe, 2)
+	for i := range pe {
+		require.NoError(t, pe[i].Get(c))
+	}
+	assert.Equal(t, e.Content, pe[0].Content)
+	assert.Equal(t, second.Content, pe[1].Content)
+
+	_, err = l.NewDBlock()
+	require.NoError(t, err)
+	_, err = l.AddEntry(third)
+	require.NoError(t, err)
+	_, err = l.NewDBlock()
+	require.NoError(t, err)
+
+	// The chain head links back to the first EBlock.
+	head := factom.EBlock{ChainID: e.ChainID}
+	require.NoError(t, head.Get(c))
+	assert.Equal(t, uint32(1), head.Sequence)
+	assert.Equal(t, uint32(2), head.Height)
+	first := head.Prev()
+	require.NoError(t, first.Get(c))
+	assert.True(t, first.IsFirst())
+	require.Len(t, first.Entries, 2)
+	assert.Equal(t, *second.Hash, *first.Entries[1].Hash)
+}
+
+func TestWalletd(t *testing.T) {
+	fs, err := factom.GenerateFsAddress()
+	require.NoError(t, err)
+	es, err := factom.GenerateEsAddress()
+	require.NoError(t, err)
+	c, close := setupServers(NewLedger(), NewWalletd(fs))
+	defer close()
+
+	adrs, err := c.GetPrivateAddresses()
+	require.NoError(t, err)
+	assert.Equal(t, []factom.PrivateAddress{fs}, adrs)
+
+	require.NoError(t, es.Save(c))
+	ecs, err := c.GetECAddresses()
+	require.NoError(t, err)
+	assert.Equal(t, []factom.ECAddress{es.ECAddress()}, ecs)
+	got, err := es.ECAddress().GetEsAddress(c)
+	require.NoError(t, err)
+	assert.Equal(t, es, got)
+
+	require.NoError(t, fs.Remove(c))
+	fss, err := c.GetFsAddresses()
+	require.NoError(t, err)
+	assert.Empty(t, fss)
+	assert.Equal(t, ErrorAddressNotFound, fs.Remove(c))
+}
@@ -0,0 +1,221 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package factomtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+
+	jrpc "github.com/AdamSLevy/jsonrpc2/v11"
+
+	"github.com/Factom-Asset-Tokens/fatd/factom"
+)
+
+// ErrorAddressNotFound is returned by the factom-walletd API for an address
+// that is not in the Walletd.
+var ErrorAddressNotFound = jrpc.Error{Code: -32603, Message: "Internal error",
+	Data: "wallet: address not found"}
+
+// Walletd is an in-memory factom-walletd keystore. It is safe for concurrent
+// use.
+type Walletd struct {
+	mu sync.RWMutex
+	// adrs holds the private addresses by their public address.
+	adrs map[string]factom.PrivateAddress
+}
+
+// NewWalletd returns a Walletd holding adrs.
+func NewWalletd(adrs ...factom.PrivateAddress) *Walletd {
+	w := &Walletd{adrs: make(map[string]factom.PrivateAddress, len(adrs))}
+	for _, adr := range adrs {
+		w.adrs[adr.PublicAddress().String()] = adr
+	}
+	return w
+}
+
+// ServeHTTP serves the factom-walletd API methods "address", "all-addresses",
+// "import-addresses", "remove-address", "compose-entry" and "compose-chain".
+func (wd *Walletd) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	serveJRPC(w, r, map[string]method{
+		"address":          wd.address,
+		"all-addresses":    wd.allAddresses,
+		"import-addresses": wd.importAddresses,
+		"remove-address":   wd.removeAddress,
+		"compose-entry":    wd.composeEntry,
+		"compose-chain":    wd.composeChain,
+	})
+}
+
+type walletAddress struct {
+	Public string `json:"public"`
+	Secret string `json:"secret"`
+}
+type walletAddresses struct {
+	Addresses []walletAddress `json:"addresses"`
+}
+
+func newWalletAddress(adr factom.PrivateAddress) walletAddress {
+	return walletAddress{Public: adr.PublicAddress().String(),
+		Secret: adr.String()}
+}
+
+// get returns the private address for the public or private address adrStr.
+func (wd *Walletd) get(adrStr string) (factom.PrivateAddress, error) {
+	adr, err := factom.NewAddress(adrStr)
+	if err != nil {
+		return nil, *jrpc.InvalidParams(err.Error())
+	}
+	wd.mu.RLock()
+	defer wd.mu.RUnlock()
+	priv, ok := wd.adrs[adr.PublicAddress().String()]
+	if !ok {
+		return nil, ErrorAddressNotFound
+	}
+	return priv, nil
+}
+
+func (wd *Walletd) address(params json.RawMessage) (interface{}, error) {
+	var p paramsAddress
+	if err := unmarshalParams(params, &p); err != nil {
+		return nil, err
+	}
+	adr, err := wd.get(p.Address)
+	if err != nil {
+		return nil, err
+	}
+	return newWalletAddress(adr), nil
+}
+
+func (wd *Walletd) allAddresses(params json.RawMessage) (interface{}, error) {
+	wd.mu.RLock()
+	defer wd.mu.RUnlock()
+	result := walletAddresses{Addresses: make([]walletAddress, 0, len(wd.adrs))}
+	for _, adr := range wd.adrs {
+		result.Addresses = append(result.Addresses, newWalletAddress(adr))
+	}
+	sort.Slice(result.Addresses, func(i, j int) bool {
+		return result.Addresses[i].Public < result.Addresses[j].Public
+	})
+	return result, nil
+}
+
+func (wd *Walletd) importAddresses(params json.RawMessage) (interface{}, error) {
+	var p walletAddresses
+	if err := unmarshalParams(params, &p); err != nil {
+		return nil, err
+	}
+	adrs := make([]factom.PrivateAddress, len(p.Addresses))
+	result := walletAddresses{Addresses: make([]walletAddress, len(adrs))}
+	for i, adrStr := range p.Addresses {
+		adr, err := factom.NewPrivateAddress(adrStr.Secret)
+		if err != nil {
+			return nil, *jrpc.InvalidParams(err.Error())
+		}
+		adrs[i] = adr
+		result.Addresses[i] = newWalletAddress(adr)
+	}
+	wd.mu.Lock()
+	defer wd.mu.Unlock()
+	for _, adr := range adrs {
+		wd.adrs[adr.PublicAddress().String()] = adr
+	}
+	return result, nil
+}
+
+func (wd *Walletd) removeAddress(params json.RawMessage) (interface{}, error) {
+	var p paramsAddress
+	if err := unmarshalParams(params, &p); err != nil {
+		return nil, err
+	}
+	adr, err := wd.get(p.Address)
+	if err != nil {
+		return nil, err
+	}
+	wd.mu.Lock()
+	defer wd.mu.Unlock()
+	delete(wd.adrs, adr.PublicAddress().String())
+	return struct {
+		Success bool `json:"success"`
+	}{true}, nil
+}
+
+func (wd *Walletd) composeEntry(params json.RawMessage) (interface{}, error) {
+	var p struct {
+		Entry factom.Entry `json:"entry"`
+		EC    string       `json:"ecpub"`
+	}
+	if err := unmarshalParams(params, &p); err != nil {
+		return nil, err
+	}
+	if p.Entry.ChainID == nil {
+		return nil, *jrpc.InvalidParams("chainid is required")
+	}
+	return wd.compose(p.Entry, p.EC)
+}
+
+func (wd *Walletd) composeChain(params json.RawMessage) (interface{}, error) {
+	var p struct {
+		Chain struct {
+			Entry factom.Entry `json:"firstentry"`
+		} `json:"chain"`
+		EC string `json:"ecpub"`
+	}
+	if err := unmarshalParams(params, &p); err != nil {
+		return nil, err
+	}
+	// The ChainID is computed from the ExtIDs.
+	p.Chain.Entry.ChainID = nil
+	return wd.compose(p.Chain.Entry, p.EC)
+}
+
+// compose returns the commit and reveal requests for e, paid for by the
+// Entry Credit address ecStr.
+func (wd *Walletd) compose(e factom.Entry, ecStr string) (interface{}, error) {
+	ec, err := factom.NewECAddress(ecStr)
+	if err != nil {
+		return nil, *jrpc.InvalidParams(err.Error())
+	}
+	adr, err := wd.get(ec.String())
+	if err != nil {
+		return nil, err
+	}
+	commitMethod, revealMethod := "commit-entry", "reveal-entry"
+	if e.ChainID == nil {
+		commitMethod, revealMethod = "commit-chain", "reveal-chain"
+	}
+	commit, reveal, _, err := e.Compose(adr.(factom.EsAddress))
+	if err != nil {
+		return nil, *jrpc.InvalidParams(err.Error())
+	}
+	return struct {
+		Commit jrpc.Request `json:"commit"`
+		Reveal jrpc.Request `json:"reveal"`
+	}{
+		Commit: jrpc.NewRequest(commitMethod, 0,
+			paramsMessage{Message: commit}),
+		Reveal: jrpc.NewRequest(revealMethod, 0,
+			paramsEntry{Entry: reveal}),
+	}, nil
+}
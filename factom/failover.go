@@ -0,0 +1,171 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package factom
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// factomdDownMin is how long a factomd server that failed a request is
+	// skipped for, unless no other server is available.
+	factomdDownMin = 5 * time.Second
+	// factomdDownMax is the maximum time a factomd server is skipped for,
+	// which doubles after each consecutive failure.
+	factomdDownMax = 5 * time.Minute
+)
+
+// serverHealth records the consecutive failures of a server.
+type serverHealth struct {
+	failures  uint
+	downUntil time.Time
+}
+
+// factomdHealth tracks the health of all factomd servers by URL, so that it is
+// shared by all Clients using the same servers.
+var factomdHealth = healthMap{m: make(map[string]serverHealth)}
+
+type healthMap struct {
+	m map[string]serverHealth
+	sync.RWMutex
+}
+
+func (h *healthMap) up(server string) {
+	h.RLock()
+	_, ok := h.m[server]
+	h.RUnlock()
+	if !ok {
+		return
+	}
+	h.Lock()
+	defer h.Unlock()
+	delete(h.m, server)
+}
+
+func (h *healthMap) down(server string) {
+	h.Lock()
+	defer h.Unlock()
+	health := h.m[server]
+	delay := factomdDownMax
+	if health.failures < 32 &&
+		factomdDownMin<<health.failures < factomdDownMax {
+		delay = factomdDownMin << health.failures
+	}
+	health.failures++
+	health.downUntil = time.Now().Add(delay)
+	h.m[server] = health
+}
+
+func (h *healthMap) isDown(server string, now time.Time) bool {
+	h.RLock()
+	defer h.RUnlock()
+	return now.Before(h.m[server].downUntil)
+}
+
+// FactomdServers returns the URLs of the factomd servers in FactomdServer.
+func (c *Client) FactomdServers() []string {
+	servers := strings.Split(c.FactomdServer, ",")
+	for i := range servers {
+		servers[i] = strings.TrimSpace(servers[i])
+	}
+	return servers
+}
+
+// factomdServers returns the factomd servers in the order that they should be
+// tried for the next request. Servers that are up are ordered first, either in
+// the order listed in FactomdServer or, if FactomdRoundRobin is set, starting
+// with the next server in turn. Servers that are down are ordered last, so
+// they are only used if all others fail.
+func (c *Client) factomdServers() []string {
+	servers := c.FactomdServers()
+	if len(servers) == 1 {
+		return servers
+	}
+	if c.FactomdRoundRobin {
+		next := int(atomic.AddUint32(&c.factomdNext, 1)-1) % len(servers)
+		rotated := make([]string, 0, len(servers))
+		rotated = append(rotated, servers[next:]...)
+		servers = append(rotated, servers[:next]...)
+	}
+	now := time.Now()
+	sort.SliceStable(servers, func(i, j int) bool {
+		return !factomdHealth.isDown(servers[i], now) &&
+			factomdHealth.isDown(servers[j], now)
+	})
+	return servers
+}
+
+// CheckDBlockKeyMR requests the DBlock at height from every factomd server
+// and returns an error if they do not all agree on its KeyMR. Servers that fail
+// to respond are ignored.
+func (c *Client) CheckDBlockKeyMR(height uint32) error {
+	servers := c.FactomdServers()
+	keyMRs := make([]*Bytes32, len(servers))
+	var wg sync.WaitGroup
+	wg.Add(len(servers))
+	for i := range servers {
+		go func(i int) {
+			defer wg.Done()
+			sc := Client{Factomd: c.Factomd,
+				FactomdServer:      servers[i],
+				FactomdRequestHook: c.FactomdRequestHook}
+			db := DBlock{Header: DBlockHeader{Height: height}}
+			if err := db.Get(&sc); err != nil {
+				return
+			}
+			keyMRs[i] = db.KeyMR
+		}(i)
+	}
+	wg.Wait()
+
+	var keyMR *Bytes32
+	var mismatch bool
+	for _, k := range keyMRs {
+		if k == nil {
+			continue
+		}
+		if keyMR == nil {
+			keyMR = k
+			continue
+		}
+		if *k != *keyMR {
+			mismatch = true
+		}
+	}
+	if !mismatch {
+		return nil
+	}
+	var report []string
+	for i, k := range keyMRs {
+		if k != nil {
+			report = append(report, fmt.Sprintf("%v: %v", servers[i], k))
+		}
+	}
+	return fmt.Errorf("DBlock %v: factomd servers disagree on KeyMR: %v",
+		height, strings.Join(report, ", "))
+}
@@ -0,0 +1,231 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package factom
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	jrpc "github.com/AdamSLevy/jsonrpc2/v11"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockFactomd serves a single DBlock, or fails every request with an HTTP
+// error if down is set.
+type mockFactomd struct {
+	dblock   *DBlock
+	down     bool
+	requests int64
+}
+
+func (m *mockFactomd) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	atomic.AddInt64(&m.requests, 1)
+	if m.down {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	var req struct {
+		ID     interface{}
+		Method string
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	res := struct {
+		JSONRPC string      `json:"jsonrpc"`
+		ID      interface{} `json:"id"`
+		Result  interface{} `json:"result,omitempty"`
+		Error   *jrpc.Error `json:"error,omitempty"`
+	}{JSONRPC: "2.0", ID: req.ID}
+	switch req.Method {
+	case "dblock-by-height":
+		res.Result = struct {
+			DBlock *DBlock `json:"dblock"`
+		}{m.dblock}
+	default:
+		res.Error = jrpc.NewError(jrpc.MethodNotFoundCode,
+			jrpc.MethodNotFoundMessage, nil)
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+// newMockDBlock returns a valid DBlock at height 10 with the given timestamp.
+func newMockDBlock(t *testing.T, ts time.Time) *DBlock {
+	chainID := Bytes32{1}
+	db := &DBlock{
+		Header: DBlockHeader{
+			NetworkID:    MainnetID,
+			Height:       10,
+			PrevKeyMR:    new(Bytes32),
+			PrevFullHash: new(Bytes32),
+			Timestamp:    ts,
+		},
+		EBlocks: []EBlock{{ChainID: &chainID, KeyMR: &Bytes32{2}}},
+	}
+	bodyMR, err := db.ComputeBodyMR()
+	require.NoError(t, err)
+	db.Header.BodyMR = &bodyMR
+	keyMR, err := db.ComputeKeyMR()
+	require.NoError(t, err)
+	db.KeyMR = &keyMR
+	fullHash, err := db.ComputeFullHash()
+	require.NoError(t, err)
+	db.FullHash = &fullHash
+	return db
+}
+
+// setupMockFactomds starts an httptest server for each mockFactomd and returns
+// a Client using all of them, and a function that closes the servers.
+func setupMockFactomds(ms ...*mockFactomd) (*Client, func()) {
+	factomdHealth = healthMap{m: make(map[string]serverHealth)}
+	c := NewClient()
+	var urls []string
+	var srvs []*httptest.Server
+	for _, m := range ms {
+		srv := httptest.NewServer(m)
+		srvs = append(srvs, srv)
+		urls = append(urls, srv.URL)
+	}
+	c.FactomdServer = strings.Join(urls, ", ")
+	return c, func() {
+		for _, srv := range srvs {
+			srv.Close()
+		}
+	}
+}
+
+func TestFactomdFailover(t *testing.T) {
+	dblock := newMockDBlock(t, time.Unix(1500000000, 0))
+	down := &mockFactomd{dblock: dblock, down: true}
+	up := &mockFactomd{dblock: dblock}
+	c, close := setupMockFactomds(down, up)
+	defer close()
+	servers := c.FactomdServers()
+	require.Len(t, servers, 2)
+
+	// Without retries, the request to the first server fails.
+	db := DBlock{Header: DBlockHeader{Height: 10}}
+	assert.Error(t, db.Get(c))
+	assert.True(t, factomdHealth.isDown(servers[0], time.Now()))
+	assert.Equal(t, []string{servers[1], servers[0]}, c.factomdServers())
+
+	// The server that is down is now tried last.
+	require.NoError(t, db.Get(c))
+	assert.Equal(t, *dblock.KeyMR, *db.KeyMR)
+	assert.Equal(t, int64(1), down.requests)
+	assert.Equal(t, int64(1), up.requests)
+
+	// With retries, a request fails over to the next server.
+	factomdHealth = healthMap{m: make(map[string]serverHealth)}
+	c.FactomdRetries = 1
+	db = DBlock{Header: DBlockHeader{Height: 10}}
+	require.NoError(t, db.Get(c))
+	assert.Equal(t, int64(2), down.requests)
+	assert.Equal(t, int64(2), up.requests)
+	assert.True(t, factomdHealth.isDown(servers[0], time.Now()))
+	assert.False(t, factomdHealth.isDown(servers[1], time.Now()))
+
+	// Once every retry is used up, the last error is returned.
+	up.down = true
+	c.FactomdRetries = 3
+	db = DBlock{Header: DBlockHeader{Height: 10}}
+	assert.Error(t, db.Get(c))
+	assert.Equal(t, int64(4), down.requests)
+	assert.Equal(t, int64(4), up.requests)
+	assert.Equal(t, uint(3), factomdHealth.m[servers[0]].failures)
+
+	// A server that recovers is no longer considered down.
+	up.down = false
+	require.NoError(t, c.FactomdRequest("dblock-by-height", nil,
+		&struct{}{}))
+	assert.False(t, factomdHealth.isDown(servers[1], time.Now()))
+	_, ok := factomdHealth.m[servers[1]]
+	assert.False(t, ok)
+}
+
+func TestFactomdJSONRPCErrorNotRetried(t *testing.T) {
+	m1, m2 := &mockFactomd{}, &mockFactomd{}
+	c, close := setupMockFactomds(m1, m2)
+	defer close()
+	c.FactomdRetries = 3
+
+	err := c.FactomdRequest("unknown-method", nil, &struct{}{})
+	require.IsType(t, jrpc.Error{}, err)
+	assert.Equal(t, jrpc.MethodNotFoundCode, err.(jrpc.Error).Code)
+	assert.Equal(t, int64(1), m1.requests)
+	assert.Equal(t, int64(0), m2.requests)
+	assert.False(t, factomdHealth.isDown(c.FactomdServers()[0], time.Now()))
+}
+
+func TestFactomdRoundRobin(t *testing.T) {
+	dblock := newMockDBlock(t, time.Unix(1500000000, 0))
+	ms := []*mockFactomd{{dblock: dblock}, {dblock: dblock}, {dblock: dblock}}
+	c, close := setupMockFactomds(ms...)
+	defer close()
+
+	for i := 0; i < 6; i++ {
+		db := DBlock{Header: DBlockHeader{Height: 10}}
+		require.NoError(t, db.Get(c))
+	}
+	assert.Equal(t, int64(6), ms[0].requests)
+	assert.Equal(t, int64(0), ms[1].requests)
+	assert.Equal(t, int64(0), ms[2].requests)
+
+	c.FactomdRoundRobin = true
+	for i := 0; i < 6; i++ {
+		db := DBlock{Header: DBlockHeader{Height: 10}}
+		require.NoError(t, db.Get(c))
+	}
+	assert.Equal(t, int64(8), ms[0].requests)
+	assert.Equal(t, int64(2), ms[1].requests)
+	assert.Equal(t, int64(2), ms[2].requests)
+}
+
+func TestCheckDBlockKeyMR(t *testing.T) {
+	dblock := newMockDBlock(t, time.Unix(1500000000, 0))
+	fork := newMockDBlock(t, time.Unix(1500000060, 0))
+	ms := []*mockFactomd{{dblock: dblock}, {dblock: dblock}, {down: true}}
+	c, close := setupMockFactomds(ms...)
+	defer close()
+
+	assert.NoError(t, c.CheckDBlockKeyMR(10))
+	for _, m := range ms {
+		assert.Equal(t, int64(1), m.requests)
+	}
+
+	ms[1].dblock = fork
+	err := c.CheckDBlockKeyMR(10)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "factomd servers disagree on KeyMR")
+	servers := c.FactomdServers()
+	assert.Contains(t, err.Error(), servers[0]+": "+dblock.KeyMR.String())
+	assert.Contains(t, err.Error(), servers[1]+": "+fork.KeyMR.String())
+	assert.NotContains(t, err.Error(), servers[2])
+}
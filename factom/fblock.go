@@ -0,0 +1,293 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package factom
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"time"
+
+	merkle "github.com/AdamSLevy/go-merkle"
+)
+
+// FBlock represents a Factom Factoid Block, which records the Factoid
+// Transactions.
+type FBlock struct {
+	// KeyMR is referenced by the DBlock and the next FBlock.
+	KeyMR *Bytes32
+
+	Header FBlockHeader
+
+	Transactions []FactoidTransaction
+}
+
+type FBlockHeader struct {
+	BodyMR          *Bytes32
+	PrevKeyMR       *Bytes32
+	PrevLedgerKeyMR *Bytes32
+
+	// ECExchangeRate is the number of factoshis per Entry Credit.
+	ECExchangeRate uint64
+
+	Height uint32
+
+	ExpansionArea Bytes
+}
+
+// FactoidTransaction is a transfer of Factoids between FAAddresses, or a
+// purchase of Entry Credits. The first FactoidTransaction in each FBlock is
+// the coinbase transaction, which has no inputs.
+type FactoidTransaction struct {
+	// ID is the hash of the transaction without its signatures.
+	ID *Bytes32
+
+	Version   uint64
+	Timestamp time.Time
+
+	// Minute is the minute of the FBlock in which the transaction was
+	// processed, from 0 to 9.
+	Minute uint8
+
+	FCTInputs  []FactoidAmount
+	FCTOutputs []FactoidAmount
+	ECOutputs  []ECAmount
+
+	// Signatures holds the RCD and signature of each of the FCTInputs.
+	Signatures []RCDSignature
+}
+
+// FactoidAmount is an amount of factoshis sent from or to an FAAddress.
+type FactoidAmount struct {
+	Amount    uint64
+	FAAddress FAAddress
+}
+
+// ECAmount is an amount of factoshis that purchase Entry Credits for an
+// ECAddress.
+type ECAmount struct {
+	Amount    uint64
+	ECAddress ECAddress
+}
+
+// RCDSignature is the RCD and signature that authorize a FactoidTransaction
+// input.
+type RCDSignature struct {
+	RCD       Bytes
+	Signature Bytes
+}
+
+// IsPopulated returns true if fb has already been successfully populated by a
+// call to Get.
+func (fb FBlock) IsPopulated() bool {
+	return fb.KeyMR != nil &&
+		fb.Header.BodyMR != nil &&
+		fb.Header.PrevKeyMR != nil &&
+		fb.Header.PrevLedgerKeyMR != nil
+}
+
+// Get queries factomd for the Factoid Block with fb.KeyMR, if not nil, and
+// otherwise for the Factoid Block at fb.Header.Height. The KeyMR of a Factoid
+// Block is the KeyMR of the third EBlock in a DBlock.
+func (fb *FBlock) Get(c *Client) error {
+	if fb.IsPopulated() {
+		return nil
+	}
+	if fb.KeyMR != nil {
+		keyMR := *fb.KeyMR
+		data, err := c.getRawData(&keyMR, validFBlock(keyMR))
+		if err != nil {
+			return err
+		}
+		if err := fb.UnmarshalBinary(data); err != nil {
+			return err
+		}
+		if *fb.KeyMR != keyMR {
+			return fmt.Errorf("invalid key merkle root")
+		}
+		return nil
+	}
+
+	params := struct {
+		Height uint32 `json:"height"`
+	}{fb.Header.Height}
+	var result struct {
+		Data Bytes `json:"rawdata"`
+	}
+	if err := c.FactomdRequest("fblock-by-height", params, &result); err != nil {
+		return err
+	}
+	height := fb.Header.Height
+	if err := fb.UnmarshalBinary(result.Data); err != nil {
+		return err
+	}
+	if fb.Header.Height != height {
+		return fmt.Errorf("height does not match")
+	}
+	return nil
+}
+
+// fBlockMinuteMarker ends each minute in the body of an FBlock.
+const fBlockMinuteMarker = 0x00
+
+// UnmarshalBinary unmarshals raw factoid block data and computes the KeyMR.
+//
+// Header
+// [Factoid Block ChainID (Bytes32{31:0x0f})] +
+// [BodyMR (Bytes32)] +
+// [PrevKeyMR (Bytes32)] +
+// [PrevLedgerKeyMR (Bytes32)] +
+// [EC Exchange Rate (8 bytes)] +
+// [DB Height (4 bytes)] +
+// [Header Expansion Size (varint_f)] +
+// [Header Expansion Area (Bytes)] +
+// [Transaction Count (4 bytes)] +
+// [Body Size (4 bytes)]
+//
+// Body
+// [Transaction 0 (Bytes)] +
+// ... +
+// [Minute Marker (0x00)] +
+// ...
+//
+// https://github.com/FactomProject/FactomDocs/blob/master/factomDataStructureDetails.md#factoid-block
+func (fb *FBlock) UnmarshalBinary(data []byte) error {
+	r := blockReader{data: data}
+	chainID := r.Bytes32()
+	var header FBlockHeader
+	header.BodyMR = r.Bytes32()
+	header.PrevKeyMR = r.Bytes32()
+	header.PrevLedgerKeyMR = r.Bytes32()
+	header.ECExchangeRate = r.Uint64()
+	header.Height = r.Uint32()
+	header.ExpansionArea = r.Bytes(int(r.VarInt()))
+	count := r.Uint32()
+	bodySize := r.Uint32()
+	if r.err != nil {
+		return r.err
+	}
+	if *chainID != FactoidBlockChainID {
+		return fmt.Errorf("invalid chain id")
+	}
+	if uint64(r.Len()) != uint64(bodySize) {
+		return fmt.Errorf("invalid body size")
+	}
+	headerLen := r.i
+
+	// The BodyMR is the merkle root of the hashes of the Transactions and
+	// minute markers, in order.
+	var leaves [][]byte
+	txs := make([]FactoidTransaction, 0, count)
+	var minute uint8
+	for r.Len() > 0 {
+		if r.data[r.i] == fBlockMinuteMarker {
+			leaves = append(leaves, r.next(1))
+			minute++
+			continue
+		}
+		start := r.i
+		tx, err := unmarshalFactoidTransaction(&r)
+		if err != nil {
+			return err
+		}
+		tx.Minute = minute
+		txs = append(txs, tx)
+		leaves = append(leaves, r.data[start:r.i])
+	}
+	if len(txs) != int(count) {
+		return fmt.Errorf("invalid transaction count")
+	}
+	tree := merkle.NewTreeWithOpts(merkle.TreeOptions{DoubleOddNodes: true})
+	if err := tree.Generate(leaves, sha256.New()); err != nil {
+		return err
+	}
+	var bodyMR Bytes32
+	copy(bodyMR[:], tree.Root().Hash)
+	if bodyMR != *header.BodyMR {
+		return fmt.Errorf("invalid body merkle root")
+	}
+
+	fb.Header = header
+	fb.Transactions = txs
+	headerHash := sha256.Sum256(data[:headerLen])
+	fb.KeyMR = new(Bytes32)
+	*fb.KeyMR = sha256.Sum256(append(headerHash[:], bodyMR[:]...))
+	return nil
+}
+
+// unmarshalFactoidTransaction reads a Factoid Transaction.
+//
+// [Version (varint_f)] +
+// [Timestamp in milliseconds (6 bytes)] +
+// [Input Count (1 byte)] +
+// [Factoid Output Count (1 byte)] +
+// [Entry Credit Output Count (1 byte)] +
+// [Input 0 Amount (varint_f)] + [Input 0 RCD Hash (Bytes32)] +
+// ... +
+// [Factoid Output 0 Amount (varint_f)] + [Factoid Output 0 RCD Hash (Bytes32)] +
+// ... +
+// [EC Output 0 Amount (varint_f)] + [EC Output 0 Public Key (Bytes32)] +
+// ... +
+// [Input 0 RCD (Bytes)] + [Input 0 Signature (64 bytes)] +
+// ...
+func unmarshalFactoidTransaction(r *blockReader) (FactoidTransaction, error) {
+	start := r.i
+	var tx FactoidTransaction
+	tx.Version = r.VarInt()
+	tx.Timestamp = r.Timestamp()
+	inputs := r.Uint8()
+	fctOutputs := r.Uint8()
+	ecOutputs := r.Uint8()
+	tx.FCTInputs = make([]FactoidAmount, inputs)
+	for i := range tx.FCTInputs {
+		tx.FCTInputs[i] = r.factoidAmount()
+	}
+	tx.FCTOutputs = make([]FactoidAmount, fctOutputs)
+	for i := range tx.FCTOutputs {
+		tx.FCTOutputs[i] = r.factoidAmount()
+	}
+	tx.ECOutputs = make([]ECAmount, ecOutputs)
+	for i := range tx.ECOutputs {
+		tx.ECOutputs[i] = ECAmount{Amount: r.VarInt(),
+			ECAddress: ECAddress(*r.Bytes32())}
+	}
+	if r.err != nil {
+		return FactoidTransaction{}, r.err
+	}
+	tx.ID = new(Bytes32)
+	*tx.ID = sha256.Sum256(r.data[start:r.i])
+
+	tx.Signatures = make([]RCDSignature, inputs)
+	for i := range tx.Signatures {
+		if r.Len() > 0 && r.data[r.i] != RCDType {
+			return FactoidTransaction{}, fmt.Errorf(
+				"unsupported RCD type: %#x", r.data[r.i])
+		}
+		tx.Signatures[i] = RCDSignature{RCD: r.Bytes(RCDSize),
+			Signature: r.Bytes(SignatureSize)}
+	}
+	return tx, r.err
+}
+
+func (r *blockReader) factoidAmount() FactoidAmount {
+	return FactoidAmount{Amount: r.VarInt(), FAAddress: FAAddress(*r.Bytes32())}
+}
@@ -0,0 +1,120 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package factom
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// NetworkID identifies the Factom network that a DBlock belongs to.
+type NetworkID [4]byte
+
+var (
+	// MainnetID is the NetworkID of the Factom mainnet.
+	MainnetID = NetworkID{0xFA, 0x92, 0xE5, 0xA2}
+	// TestnetID is the NetworkID of the Factom community testnet.
+	TestnetID = NetworkID{0xFA, 0x92, 0xE5, 0xA3}
+)
+
+// IsMainnet returns true if n is the MainnetID.
+func (n NetworkID) IsMainnet() bool {
+	return n == MainnetID
+}
+
+// IsTestnet returns true if n is the TestnetID.
+func (n NetworkID) IsTestnet() bool {
+	return n == TestnetID
+}
+
+// IsCustom returns true if n is neither the MainnetID nor the TestnetID.
+func (n NetworkID) IsCustom() bool {
+	return !n.IsMainnet() && !n.IsTestnet()
+}
+
+// String returns "mainnet", "testnet", or the hex encoded NetworkID prefixed
+// with "0x" for custom networks.
+func (n NetworkID) String() string {
+	switch n {
+	case MainnetID:
+		return "mainnet"
+	case TestnetID:
+		return "testnet"
+	}
+	return "0x" + hex.EncodeToString(n[:])
+}
+
+// Set parses "mainnet", "testnet", or a hex encoded NetworkID, optionally
+// prefixed with "0x". Satisfies the flag.Value interface.
+func (n *NetworkID) Set(netIDStr string) error {
+	switch strings.ToLower(netIDStr) {
+	case "mainnet", "main":
+		*n = MainnetID
+		return nil
+	case "testnet", "test":
+		*n = TestnetID
+		return nil
+	}
+	hexStr := strings.TrimPrefix(strings.ToLower(netIDStr), "0x")
+	if len(hexStr) != hex.EncodedLen(len(n)) {
+		return fmt.Errorf("invalid length")
+	}
+	if _, err := hex.Decode(n[:], []byte(hexStr)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Type returns "NetworkID". Satisfies pflag.Value interface.
+func (NetworkID) Type() string {
+	return "NetworkID"
+}
+
+// Scan expects v to be an integer or NULL, which is scanned as the zero
+// NetworkID.
+func (n *NetworkID) Scan(v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		*n = NetworkID{}
+	case int64:
+		if v < 0 || v > int64(^uint32(0)) {
+			return fmt.Errorf("out of range")
+		}
+		binary.BigEndian.PutUint32(n[:], uint32(v))
+	default:
+		return fmt.Errorf("invalid type")
+	}
+	return nil
+}
+
+// Value returns n as an integer.
+func (n NetworkID) Value() (driver.Value, error) {
+	return int64(binary.BigEndian.Uint32(n[:])), nil
+}
+
+var _ sql.Scanner = &NetworkID{}
+var _ driver.Valuer = NetworkID{}
@@ -0,0 +1,100 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package factom
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var networkIDSetTests = []struct {
+	Name  string
+	Input string
+	Exp   NetworkID
+	Err   string
+}{{
+	Name:  "mainnet",
+	Input: "mainnet",
+	Exp:   MainnetID,
+}, {
+	Name:  "testnet",
+	Input: "Testnet",
+	Exp:   TestnetID,
+}, {
+	Name:  "hex",
+	Input: "0xFA92E5A4",
+	Exp:   NetworkID{0xFA, 0x92, 0xE5, 0xA4},
+}, {
+	Name:  "hex/no prefix",
+	Input: "fa92e5a2",
+	Exp:   MainnetID,
+}, {
+	Name:  "invalid length",
+	Input: "0xfa92e5",
+	Err:   "invalid length",
+}, {
+	Name:  "invalid symbol",
+	Input: "0xfa92e5ax",
+	Err:   "encoding/hex: invalid byte: U+0078 'x'",
+}}
+
+func TestNetworkIDSet(t *testing.T) {
+	for _, test := range networkIDSetTests {
+		test := test
+		t.Run(test.Name, func(t *testing.T) {
+			assert := assert.New(t)
+			var netID NetworkID
+			err := netID.Set(test.Input)
+			if len(test.Err) > 0 {
+				assert.EqualError(err, test.Err)
+				return
+			}
+			assert.NoError(err)
+			assert.Equal(test.Exp, netID)
+		})
+	}
+}
+
+func TestNetworkID(t *testing.T) {
+	assert := assert.New(t)
+	assert.True(MainnetID.IsMainnet())
+	assert.True(TestnetID.IsTestnet())
+	custom := NetworkID{0xFA, 0x92, 0xE5, 0xA4}
+	assert.True(custom.IsCustom())
+	assert.False(MainnetID.IsCustom())
+
+	assert.Equal("mainnet", MainnetID.String())
+	assert.Equal("testnet", TestnetID.String())
+	assert.Equal("0xfa92e5a4", custom.String())
+
+	v, err := custom.Value()
+	require.NoError(t, err)
+	var scanned NetworkID
+	require.NoError(t, scanned.Scan(v))
+	assert.Equal(custom, scanned)
+	require.NoError(t, scanned.Scan(nil))
+	assert.Equal(NetworkID{}, scanned)
+	assert.EqualError(scanned.Scan("mainnet"), "invalid type")
+}
@@ -0,0 +1,141 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package factom
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/Factom-Asset-Tokens/fatd/factom/varintf"
+)
+
+// blockReader reads the fields of the binary Admin, Entry Credit and Factoid
+// Blocks, which unlike DBlocks and EBlocks have variable length fields. The
+// first read past the end of data sets err, after which all reads return zero
+// values.
+type blockReader struct {
+	data []byte
+	i    int
+	err  error
+}
+
+// Len returns the number of unread bytes.
+func (r *blockReader) Len() int {
+	return len(r.data) - r.i
+}
+
+func (r *blockReader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || r.Len() < n {
+		r.err = fmt.Errorf("insufficient length")
+		return nil
+	}
+	buf := r.data[r.i : r.i+n]
+	r.i += n
+	return buf
+}
+
+func (r *blockReader) Bytes(n int) Bytes {
+	buf := r.next(n)
+	if buf == nil {
+		return nil
+	}
+	return append(Bytes{}, buf...)
+}
+
+// Bytes32 never returns nil, so that it may be dereferenced before checking
+// err.
+func (r *blockReader) Bytes32() *Bytes32 {
+	return NewBytes32(r.next(len(Bytes32{})))
+}
+
+func (r *blockReader) Uint8() uint8 {
+	buf := r.next(1)
+	if buf == nil {
+		return 0
+	}
+	return buf[0]
+}
+
+func (r *blockReader) Uint16() uint16 {
+	buf := r.next(2)
+	if buf == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint16(buf)
+}
+
+func (r *blockReader) Uint32() uint32 {
+	buf := r.next(4)
+	if buf == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(buf)
+}
+
+func (r *blockReader) Uint64() uint64 {
+	buf := r.next(8)
+	if buf == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint64(buf)
+}
+
+// Timestamp reads a 6 byte timestamp in milliseconds, as used by commits and
+// Factoid Transactions.
+func (r *blockReader) Timestamp() time.Time {
+	buf := r.next(6)
+	if buf == nil {
+		return time.Time{}
+	}
+	ms := int64(binary.BigEndian.Uint16(buf))<<32 |
+		int64(binary.BigEndian.Uint32(buf[2:]))
+	return time.Unix(0, ms*1e6)
+}
+
+// VarInt reads a varint_f, as defined by varintf.
+func (r *blockReader) VarInt() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	// varintf.Decode does not check the length of the buffer, so ensure
+	// that the varint ends within the data.
+	end := r.i
+	for end < len(r.data) && r.data[end]&0x80 > 0 {
+		end++
+	}
+	if end == len(r.data) {
+		r.err = fmt.Errorf("insufficient length")
+		return 0
+	}
+	x, n := varintf.Decode(r.data[r.i : end+1])
+	if n < 0 {
+		r.err = fmt.Errorf("invalid varint")
+		return 0
+	}
+	r.i += n
+	return x
+}
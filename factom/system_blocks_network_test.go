@@ -0,0 +1,98 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+// +build network
+
+package factom_test
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"testing"
+
+	. "github.com/Factom-Asset-Tokens/fatd/factom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false,
+	"save the mainnet blocks fetched by TestSystemBlocks in testdata")
+
+// TestSystemBlocks fetches the Admin, Entry Credit and Factoid Blocks of a
+// mainnet DBlock from the courtesy node, both by KeyMR and by height. It only
+// runs with the network build tag.
+func TestSystemBlocks(t *testing.T) {
+	height := uint32(166587)
+	c := NewClient()
+	c.FactomdServer = courtesyNode
+	db := DBlock{Header: DBlockHeader{Height: height}}
+	require.NoError(t, db.Get(c))
+
+	blocks := systemBlocks{Height: height,
+		ABlockLookupHash:  db.EBlocks[0].KeyMR,
+		ECBlockHeaderHash: db.EBlocks[1].KeyMR,
+		FBlockKeyMR:       db.EBlocks[2].KeyMR,
+	}
+	params := struct {
+		Height uint32 `json:"height"`
+	}{height}
+	for method, data := range map[string]*Bytes{
+		"ablock-by-height":  &blocks.ABlock,
+		"ecblock-by-height": &blocks.ECBlock,
+		"fblock-by-height":  &blocks.FBlock,
+	} {
+		var result struct {
+			Data Bytes `json:"rawdata"`
+		}
+		require.NoError(t, c.FactomdRequest(method, params, &result))
+		*data = result.Data
+	}
+	testSystemBlocks(t, blocks)
+
+	t.Run("Get", func(t *testing.T) {
+		ab := ABlock{LookupHash: blocks.ABlockLookupHash}
+		require.NoError(t, ab.Get(c))
+		abh := ABlock{Header: ABlockHeader{Height: height}}
+		require.NoError(t, abh.Get(c))
+		assert.Equal(t, ab, abh)
+
+		ecb := ECBlock{HeaderHash: blocks.ECBlockHeaderHash}
+		require.NoError(t, ecb.Get(c))
+		ecbh := ECBlock{Header: ECBlockHeader{Height: height}}
+		require.NoError(t, ecbh.Get(c))
+		assert.Equal(t, ecb, ecbh)
+
+		fb := FBlock{KeyMR: blocks.FBlockKeyMR}
+		require.NoError(t, fb.Get(c))
+		fbh := FBlock{Header: FBlockHeader{Height: height}}
+		require.NoError(t, fbh.Get(c))
+		assert.Equal(t, fb, fbh)
+	})
+
+	if *update {
+		data, err := json.MarshalIndent(blocks, "", "  ")
+		require.NoError(t, err)
+		require.NoError(t, ioutil.WriteFile(systemBlocksFile(height),
+			append(data, '\n'), 0644))
+	}
+}
@@ -0,0 +1,107 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package factom_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	. "github.com/Factom-Asset-Tokens/fatd/factom"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// systemBlocks is the raw data of the Admin, Entry Credit and Factoid Blocks
+// of a single mainnet DBlock, along with their KeyMRs as listed in the DBlock.
+// They are saved in testdata/mainnet-<height>.json by running the network
+// tests with -update.
+type systemBlocks struct {
+	Height uint32 `json:"height"`
+
+	// The KeyMRs of the first three EBlocks of the DBlock.
+	ABlockLookupHash  *Bytes32 `json:"ablocklookuphash"`
+	ECBlockHeaderHash *Bytes32 `json:"ecblockheaderhash"`
+	FBlockKeyMR       *Bytes32 `json:"fblockkeymr"`
+
+	ABlock  Bytes `json:"ablock"`
+	ECBlock Bytes `json:"ecblock"`
+	FBlock  Bytes `json:"fblock"`
+}
+
+func systemBlocksFile(height uint32) string {
+	return filepath.Join("testdata", fmt.Sprintf("mainnet-%v.json", height))
+}
+
+// TestSystemBlocksTestdata unmarshals the raw mainnet blocks in testdata and
+// checks them against the KeyMRs listed in their DBlock.
+func TestSystemBlocksTestdata(t *testing.T) {
+	fnames, err := filepath.Glob(filepath.Join("testdata", "mainnet-*.json"))
+	require.NoError(t, err)
+	if len(fnames) == 0 {
+		t.Skip("no mainnet blocks in testdata, " +
+			"run `go test -tags network -run TestSystemBlocks -update`")
+	}
+	for _, fname := range fnames {
+		fname := fname
+		t.Run(filepath.Base(fname), func(t *testing.T) {
+			data, err := ioutil.ReadFile(fname)
+			require.NoError(t, err)
+			var blocks systemBlocks
+			require.NoError(t, json.Unmarshal(data, &blocks))
+			testSystemBlocks(t, blocks)
+		})
+	}
+}
+
+func testSystemBlocks(t *testing.T, blocks systemBlocks) {
+	t.Run("ABlock", func(t *testing.T) {
+		var ab ABlock
+		require.NoError(t, ab.UnmarshalBinary(blocks.ABlock))
+		assert.Equal(t, *blocks.ABlockLookupHash, *ab.LookupHash)
+		assert.Equal(t, blocks.Height, ab.Header.Height)
+		assert.NotEmpty(t, ab.Entries)
+		for _, e := range ab.Entries {
+			if sig, ok := e.(ABlockDBSignature); ok {
+				assert.Len(t, sig.Signature, 64)
+			}
+		}
+	})
+	t.Run("ECBlock", func(t *testing.T) {
+		var ecb ECBlock
+		require.NoError(t, ecb.UnmarshalBinary(blocks.ECBlock))
+		assert.Equal(t, *blocks.ECBlockHeaderHash, *ecb.HeaderHash)
+		assert.Equal(t, blocks.Height, ecb.Header.Height)
+		assert.Equal(t, ecb.Header.ObjectCount, uint64(len(ecb.Entries)))
+	})
+	t.Run("FBlock", func(t *testing.T) {
+		var fb FBlock
+		require.NoError(t, fb.UnmarshalBinary(blocks.FBlock))
+		assert.Equal(t, *blocks.FBlockKeyMR, *fb.KeyMR)
+		assert.Equal(t, blocks.Height, fb.Header.Height)
+		require.NotEmpty(t, fb.Transactions)
+		assert.Empty(t, fb.Transactions[0].FCTInputs, "coinbase")
+	})
+}
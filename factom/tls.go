@@ -0,0 +1,141 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package factom
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// TLSOptions configures TLS for connections to factomd or factom-walletd. TLS
+// is used for any server URL with the https scheme.
+type TLSOptions struct {
+	// CAFile is the path to a PEM encoded bundle of CA certificates used
+	// to verify the server's certificate. If empty, the system's CA
+	// certificates are used.
+	CAFile string
+
+	// CertFile and KeyFile are the paths to a PEM encoded client
+	// certificate and its private key, which are presented to servers
+	// that require mutual TLS.
+	CertFile string
+	KeyFile  string
+
+	// ServerName, if not empty, is the host name that the server's
+	// certificate is verified against, instead of the host in the URL.
+	ServerName string
+}
+
+// IsSet returns true if any of the options are set.
+func (o TLSOptions) IsSet() bool {
+	return o != TLSOptions{}
+}
+
+// Config loads the certificates and returns the tls.Config described by o.
+func (o TLSOptions) Config() (*tls.Config, error) {
+	cfg := &tls.Config{ServerName: o.ServerName}
+	if len(o.CAFile) > 0 {
+		pem, err := ioutil.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%v: no valid PEM certificates",
+				o.CAFile)
+		}
+	}
+	if len(o.CertFile) > 0 || len(o.KeyFile) > 0 {
+		if len(o.CertFile) == 0 || len(o.KeyFile) == 0 {
+			return nil, fmt.Errorf(
+				"a client certificate requires both a cert and a key")
+		}
+		cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// SetFactomdTLS configures the TLS used for requests to factomd.
+func (c *Client) SetFactomdTLS(o TLSOptions) error {
+	return setTLS(&c.Factomd.Client, o)
+}
+
+// SetWalletdTLS configures the TLS used for requests to factom-walletd.
+func (c *Client) SetWalletdTLS(o TLSOptions) error {
+	return setTLS(&c.Walletd.Client, o)
+}
+
+func setTLS(hc *http.Client, o TLSOptions) error {
+	t, err := o.Transport()
+	if err != nil {
+		return err
+	}
+	hc.Transport = t
+	return nil
+}
+
+// Transport returns a new http.Transport with the same settings as
+// http.DefaultTransport, using the tls.Config described by o.
+func (o TLSOptions) Transport() (*http.Transport, error) {
+	cfg, err := o.Config()
+	if err != nil {
+		return nil, err
+	}
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		TLSClientConfig:       cfg,
+	}, nil
+}
+
+// HTTPS returns the comma separated list of server URLs with the http scheme
+// replaced by https, and with https added to any URLs without a scheme.
+func HTTPS(servers string) string {
+	urls := strings.Split(servers, ",")
+	for i, url := range urls {
+		url = strings.TrimSpace(url)
+		url = strings.TrimPrefix(url, "http://")
+		if !strings.HasPrefix(url, "https://") {
+			url = "https://" + url
+		}
+		urls[i] = url
+	}
+	return strings.Join(urls, ",")
+}
@@ -0,0 +1,188 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package factom
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testCert is a certificate and its key, signed by a parent testCert, or self
+// signed if it is a CA.
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+func newTestCert(t *testing.T, parent *testCert, serial int64,
+	template x509.Certificate) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template.SerialNumber = big.NewInt(serial)
+	template.Subject = pkix.Name{CommonName: t.Name()}
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	signer, signerKey := &template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, signer,
+		&key.PublicKey, signerKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCert{cert: cert, key: key, der: der}
+}
+
+func (c *testCert) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.der}, PrivateKey: c.key}
+}
+
+// writeFiles writes the PEM encoded certificate and key of c to dir and
+// returns their paths.
+func (c *testCert) writeFiles(t *testing.T, dir, name string) (string, string) {
+	certFile := filepath.Join(dir, name+".crt")
+	require.NoError(t, ioutil.WriteFile(certFile, pem.EncodeToMemory(
+		&pem.Block{Type: "CERTIFICATE", Bytes: c.der}), 0600))
+	keyDER, err := x509.MarshalECPrivateKey(c.key)
+	require.NoError(t, err)
+	keyFile := filepath.Join(dir, name+".key")
+	require.NoError(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(
+		&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	return certFile, keyFile
+}
+
+func TestTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "factom-tls-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	ca := newTestCert(t, nil, 1, x509.Certificate{
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	})
+	caFile, _ := ca.writeFiles(t, dir, "ca")
+	server := newTestCert(t, ca, 2, x509.Certificate{
+		DNSNames:    []string{"factomd.example"},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	localhost := newTestCert(t, ca, 3, x509.Certificate{
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	client := newTestCert(t, ca, 4, x509.Certificate{
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	clientCert, clientKey := client.writeFiles(t, dir, "client")
+
+	dblock := newMockDBlock(t, time.Unix(1500000000, 0))
+	newServer := func(cert *testCert, mutual bool) *httptest.Server {
+		srv := httptest.NewUnstartedServer(&mockFactomd{dblock: dblock})
+		srv.TLS = &tls.Config{
+			Certificates: []tls.Certificate{cert.tlsCertificate()},
+		}
+		if mutual {
+			srv.TLS.ClientAuth = tls.RequireAndVerifyClientCert
+			srv.TLS.ClientCAs = x509.NewCertPool()
+			srv.TLS.ClientCAs.AddCert(ca.cert)
+		}
+		srv.StartTLS()
+		return srv
+	}
+	get := func(url string, o TLSOptions) error {
+		factomdHealth = healthMap{m: make(map[string]serverHealth)}
+		c := NewClient()
+		c.FactomdServer = url
+		c.Factomd.Timeout = 5 * time.Second
+		if err := c.SetFactomdTLS(o); err != nil {
+			return err
+		}
+		db := DBlock{Header: DBlockHeader{Height: 10}}
+		return db.Get(c)
+	}
+
+	t.Run("CA", func(t *testing.T) {
+		srv := newServer(localhost, false)
+		defer srv.Close()
+		err := get(srv.URL, TLSOptions{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "certificate")
+		assert.NoError(t, get(srv.URL, TLSOptions{CAFile: caFile}))
+	})
+	t.Run("hostname verification", func(t *testing.T) {
+		srv := newServer(server, false)
+		defer srv.Close()
+		err := get(srv.URL, TLSOptions{CAFile: caFile})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "127.0.0.1")
+		err = get(srv.URL, TLSOptions{CAFile: caFile,
+			ServerName: "wrong.example"})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "wrong.example")
+		assert.NoError(t, get(srv.URL, TLSOptions{CAFile: caFile,
+			ServerName: "factomd.example"}))
+	})
+	t.Run("mutual TLS", func(t *testing.T) {
+		srv := newServer(localhost, true)
+		defer srv.Close()
+		assert.Error(t, get(srv.URL, TLSOptions{CAFile: caFile}))
+		assert.NoError(t, get(srv.URL, TLSOptions{CAFile: caFile,
+			CertFile: clientCert, KeyFile: clientKey}))
+	})
+	t.Run("invalid options", func(t *testing.T) {
+		assert.EqualError(t, get("", TLSOptions{CertFile: clientCert}),
+			"a client certificate requires both a cert and a key")
+		assert.EqualError(t, get("", TLSOptions{CAFile: clientKey}),
+			clientKey+": no valid PEM certificates")
+		assert.Error(t, get("", TLSOptions{
+			CAFile: filepath.Join(dir, "missing.crt")}))
+	})
+}
+
+func TestHTTPS(t *testing.T) {
+	assert.Equal(t, "https://localhost:8088", HTTPS("http://localhost:8088"))
+	assert.Equal(t, "https://localhost:8088", HTTPS("localhost:8088"))
+	assert.Equal(t, "https://a:8088,https://b:8088",
+		HTTPS("https://a:8088, http://b:8088"))
+}
@@ -0,0 +1,133 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package factom
+
+import "fmt"
+
+// VerifyFullHash returns an error if db.FullHash is not the hash of db.
+func (db DBlock) VerifyFullHash() error {
+	if db.FullHash == nil {
+		return fmt.Errorf("DBlock %v: no full hash", db.Header.Height)
+	}
+	fullHash, err := db.ComputeFullHash()
+	if err != nil {
+		return err
+	}
+	if *db.FullHash != fullHash {
+		return fmt.Errorf("DBlock %v: invalid full hash", db.Header.Height)
+	}
+	return nil
+}
+
+// VerifyPrev returns an error if db is not the DBlock that directly follows
+// prev, by height and by the PrevKeyMR and PrevFullHash in its header.
+func (db DBlock) VerifyPrev(prev DBlock) error {
+	if db.Header.Height != prev.Header.Height+1 {
+		return fmt.Errorf("DBlock %v: does not follow DBlock %v",
+			db.Header.Height, prev.Header.Height)
+	}
+	if prev.KeyMR == nil || db.Header.PrevKeyMR == nil ||
+		*db.Header.PrevKeyMR != *prev.KeyMR {
+		return fmt.Errorf("DBlock %v: PrevKeyMR does not match "+
+			"the KeyMR of DBlock %v", db.Header.Height,
+			prev.Header.Height)
+	}
+	if prev.FullHash == nil || db.Header.PrevFullHash == nil ||
+		*db.Header.PrevFullHash != *prev.FullHash {
+		return fmt.Errorf("DBlock %v: PrevFullHash does not match "+
+			"the full hash of DBlock %v", db.Header.Height,
+			prev.Header.Height)
+	}
+	return nil
+}
+
+// VerifyDBlock returns an error if eb is not the EBlock listed in db for
+// eb.ChainID, or if its KeyMR does not match its contents.
+func (eb EBlock) VerifyDBlock(db DBlock) error {
+	if eb.ChainID == nil || eb.KeyMR == nil {
+		return fmt.Errorf("EBlock has no ChainID or KeyMR")
+	}
+	// DBlock.EBlock requires the EBlocks to be sorted, which cannot be
+	// trusted here.
+	var dbEB *EBlock
+	for i := range db.EBlocks {
+		if ebi := &db.EBlocks[i]; ebi.ChainID != nil &&
+			*ebi.ChainID == *eb.ChainID {
+			dbEB = ebi
+			break
+		}
+	}
+	if dbEB == nil || dbEB.KeyMR == nil {
+		return fmt.Errorf("EBlock{ChainID: %v}: not in DBlock %v",
+			eb.ChainID, db.Header.Height)
+	}
+	if *dbEB.KeyMR != *eb.KeyMR {
+		return fmt.Errorf("EBlock{ChainID: %v}: KeyMR does not match "+
+			"DBlock %v", eb.ChainID, db.Header.Height)
+	}
+	if eb.Height != db.Header.Height {
+		return fmt.Errorf("EBlock{ChainID: %v}: height %v does not match "+
+			"DBlock %v", eb.ChainID, eb.Height, db.Header.Height)
+	}
+	keyMR, err := eb.ComputeKeyMR()
+	if err != nil {
+		return err
+	}
+	if *eb.KeyMR != keyMR {
+		return fmt.Errorf("EBlock{ChainID: %v}: invalid key merkle root",
+			eb.ChainID)
+	}
+	return nil
+}
+
+// VerifyEntry returns an error if e is not for eb.ChainID, or if its Hash is
+// not listed in eb or does not match its contents. The Hashes listed in eb are
+// only trustworthy if eb has been verified.
+func (eb EBlock) VerifyEntry(e Entry) error {
+	if e.Hash == nil {
+		return fmt.Errorf("Entry has no Hash")
+	}
+	if e.ChainID == nil || eb.ChainID == nil || *e.ChainID != *eb.ChainID {
+		return fmt.Errorf("Entry%v: ChainID does not match EBlock",
+			e.Hash)
+	}
+	var listed bool
+	for _, ebe := range eb.Entries {
+		if ebe.Hash != nil && *ebe.Hash == *e.Hash {
+			listed = true
+			break
+		}
+	}
+	if !listed {
+		return fmt.Errorf("Entry%v: not in EBlock{ChainID: %v}",
+			e.Hash, eb.ChainID)
+	}
+	hash, err := e.ComputeHash()
+	if err != nil {
+		return err
+	}
+	if *e.Hash != hash {
+		return fmt.Errorf("Entry%v: invalid hash", e.Hash)
+	}
+	return nil
+}
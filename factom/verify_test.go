@@ -0,0 +1,166 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package factom
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newVerifyDBlock returns a valid DBlock following prev, with one EBlock with
+// one Entry.
+func newVerifyDBlock(t *testing.T, prev *DBlock) (*DBlock, EBlock) {
+	chainID := Bytes32{0x01, 0x02}
+	db := &DBlock{Header: DBlockHeader{
+		NetworkID:    MainnetID,
+		Height:       10,
+		PrevKeyMR:    new(Bytes32),
+		PrevFullHash: new(Bytes32),
+		Timestamp:    time.Unix(1500000000, 0),
+	}}
+	if prev != nil {
+		db.Header.Height = prev.Header.Height + 1
+		db.Header.PrevKeyMR = prev.KeyMR
+		db.Header.PrevFullHash = prev.FullHash
+		db.Header.Timestamp = prev.Header.Timestamp.Add(10 * time.Minute)
+	}
+
+	e := Entry{ChainID: &chainID, ExtIDs: []Bytes{Bytes("verify")},
+		Content:   Bytes("content"),
+		Timestamp: db.Header.Timestamp.Add(time.Minute)}
+	hash, err := e.ComputeHash()
+	require.NoError(t, err)
+	e.Hash = &hash
+	eb := EBlock{ChainID: &chainID, PrevKeyMR: new(Bytes32),
+		PrevFullHash: new(Bytes32), Height: db.Header.Height,
+		Timestamp: db.Header.Timestamp, Entries: []Entry{e},
+		ObjectCount: 2}
+	bodyMR, err := eb.ComputeBodyMR()
+	require.NoError(t, err)
+	eb.BodyMR = &bodyMR
+	keyMR, err := eb.ComputeKeyMR()
+	require.NoError(t, err)
+	eb.KeyMR = &keyMR
+
+	// Every DBlock begins with the Admin, EC and FCT blocks.
+	for _, id := range []byte{0x0a, 0x0c, 0x0f} {
+		db.EBlocks = append(db.EBlocks, EBlock{
+			ChainID: &Bytes32{31: id}, KeyMR: &Bytes32{0: id}})
+	}
+	db.EBlocks = append(db.EBlocks, EBlock{ChainID: &chainID, KeyMR: &keyMR})
+	dbBodyMR, err := db.ComputeBodyMR()
+	require.NoError(t, err)
+	db.Header.BodyMR = &dbBodyMR
+	dbKeyMR, err := db.ComputeKeyMR()
+	require.NoError(t, err)
+	db.KeyMR = &dbKeyMR
+	fullHash, err := db.ComputeFullHash()
+	require.NoError(t, err)
+	db.FullHash = &fullHash
+	return db, eb
+}
+
+func TestVerifyDBlock(t *testing.T) {
+	first, _ := newVerifyDBlock(t, nil)
+	second, _ := newVerifyDBlock(t, first)
+	assert.NoError(t, first.VerifyFullHash())
+	assert.NoError(t, second.VerifyFullHash())
+	assert.NoError(t, second.VerifyPrev(*first))
+
+	assert.EqualError(t, first.VerifyPrev(*second),
+		"DBlock 10: does not follow DBlock 11")
+
+	fork := *first
+	fork.Header.Timestamp = fork.Header.Timestamp.Add(time.Minute)
+	keyMR, err := fork.ComputeKeyMR()
+	require.NoError(t, err)
+	fork.KeyMR = &keyMR
+	assert.EqualError(t, fork.VerifyFullHash(),
+		"DBlock 10: invalid full hash")
+	assert.EqualError(t, second.VerifyPrev(fork),
+		"DBlock 11: PrevKeyMR does not match the KeyMR of DBlock 10")
+
+	fork = *first
+	fork.FullHash = &Bytes32{1}
+	assert.EqualError(t, second.VerifyPrev(fork),
+		"DBlock 11: PrevFullHash does not match the full hash of DBlock 10")
+
+	fork.FullHash = nil
+	assert.EqualError(t, fork.VerifyFullHash(), "DBlock 10: no full hash")
+}
+
+func TestVerifyEBlock(t *testing.T) {
+	db, eb := newVerifyDBlock(t, nil)
+	assert.NoError(t, eb.VerifyDBlock(*db))
+
+	other, otherEB := newVerifyDBlock(t, db)
+	assert.EqualError(t, otherEB.VerifyDBlock(*db),
+		"EBlock{ChainID: "+eb.ChainID.String()+
+			"}: KeyMR does not match DBlock 10")
+
+	wrongHeight := eb
+	wrongHeight.Height = 11
+	assert.EqualError(t, wrongHeight.VerifyDBlock(*db),
+		"EBlock{ChainID: "+eb.ChainID.String()+
+			"}: height 11 does not match DBlock 10")
+
+	tampered := eb
+	tampered.Entries = append([]Entry{}, eb.Entries...)
+	tampered.Entries[0].Hash = &Bytes32{1}
+	assert.EqualError(t, tampered.VerifyDBlock(*db),
+		"EBlock{ChainID: "+eb.ChainID.String()+
+			"}: invalid key merkle root")
+
+	missing := eb
+	missing.ChainID = &Bytes32{1}
+	assert.EqualError(t, missing.VerifyDBlock(*other),
+		"EBlock{ChainID: "+missing.ChainID.String()+
+			"}: not in DBlock 11")
+}
+
+func TestVerifyEntry(t *testing.T) {
+	_, eb := newVerifyDBlock(t, nil)
+	e := eb.Entries[0]
+	assert.NoError(t, eb.VerifyEntry(e))
+
+	tampered := e
+	tampered.Content = Bytes("tampered")
+	assert.EqualError(t, eb.VerifyEntry(tampered),
+		"Entry"+e.Hash.String()+": invalid hash")
+
+	otherChain := e
+	otherChain.ChainID = &Bytes32{1}
+	assert.EqualError(t, eb.VerifyEntry(otherChain),
+		"Entry"+e.Hash.String()+": ChainID does not match EBlock")
+
+	unlisted := tampered
+	hash, err := unlisted.ComputeHash()
+	require.NoError(t, err)
+	unlisted.Hash = &hash
+	assert.EqualError(t, eb.VerifyEntry(unlisted),
+		"Entry"+hash.String()+": not in EBlock{ChainID: "+
+			eb.ChainID.String()+"}")
+}
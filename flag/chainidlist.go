@@ -0,0 +1,75 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package flag
+
+import (
+	"fmt"
+	"strings"
+
+	. "github.com/Factom-Asset-Tokens/fatd/factom"
+	"github.com/Factom-Asset-Tokens/fatd/fat"
+)
+
+type ChainIDList []Bytes32
+
+func (ids ChainIDList) String() string {
+	if len(ids) == 0 {
+		return ""
+	}
+	var s string
+	for _, id := range ids {
+		s += id.String() + ","
+	}
+	return s[:len(s)-1]
+}
+
+// Set appends a comma seperated list of ChainIDs. Each element may also be a
+// TokenID and Issuer Chain ID separated by a colon, "<tokenid>:<issuerid>",
+// which is converted to the ChainID of the token.
+func (ids *ChainIDList) Set(s string) error {
+	idStrs := strings.Split(s, ",")
+	newIDs := make(ChainIDList, len(idStrs))
+	for i, idStr := range idStrs {
+		sep := strings.LastIndex(idStr, ":")
+		if sep == -1 {
+			if len(idStr) == 0 {
+				return fmt.Errorf("invalid length")
+			}
+			if err := newIDs[i].Set(idStr); err != nil {
+				return err
+			}
+			continue
+		}
+		tokenID := idStr[:sep]
+		if len(tokenID) == 0 {
+			return fmt.Errorf("%#v: empty token ID", idStr)
+		}
+		var issuer Bytes32
+		if err := issuer.Set(idStr[sep+1:]); err != nil {
+			return fmt.Errorf("%#v: issuer chain ID: %v", idStr, err)
+		}
+		newIDs[i] = fat.ChainID(tokenID, issuer)
+	}
+	*ids = append(*ids, newIDs...)
+	return nil
+}
@@ -42,104 +42,232 @@ const envNamePrefix = "FATD_"
 var (
 	envNames = map[string]string{
 		"startscanheight":   "START_SCAN_HEIGHT",
+		"networkid":         "NETWORK_ID",
+		"activationheight":  "ACTIVATION_HEIGHT",
 		"factomscanretries": "FACTOM_SCAN_RETRIES",
+		"fetchworkers":      "FETCH_WORKERS",
+		"fetchwindow":       "FETCH_WINDOW",
 		"debug":             "DEBUG",
 
-		"dbpath": "DB_PATH",
-
-		"apiaddress": "API_ADDRESS",
-
-		"s":               "FACTOMD_SERVER",
-		"factomdtimeout":  "FACTOMD_TIMEOUT",
-		"factomduser":     "FACTOMD_USER",
-		"factomdpassword": "FACTOMD_PASSWORD",
-		//"factomdcert":     "FACTOMD_TLS_CERT",
-		//"factomdtls":      "FACTOMD_TLS_ENABLE",
-
-		"w":              "WALLETD_SERVER",
-		"wallettimeout":  "WALLETD_TIMEOUT",
-		"walletuser":     "WALLETD_USER",
-		"walletpassword": "WALLETD_PASSWORD",
-		//"walletcert":     "WALLETD_TLS_CERT",
-		//"wallettls":      "WALLETD_TLS_ENABLE",
+		"dbpath":     "DB_PATH",
+		"validatedb": "VALIDATE_DB",
+		"repairdb":   "REPAIR_DB",
+
+		"apiaddress":     "API_ADDRESS",
+		"apitlscert":     "API_TLS_CERT",
+		"apitlskey":      "API_TLS_KEY",
+		"apiuser":        "API_USER",
+		"apipassword":    "API_PASSWORD",
+		"apitoken":       "API_TOKEN",
+		"apicorsorigins": "API_CORS_ORIGINS",
+
+		"webhooks": "WEBHOOKS",
+
+		"whitelist":  "WHITELIST",
+		"blacklist":  "BLACKLIST",
+		"fastsync":   "FAST_SYNC",
+		"verifysync": "VERIFY_SYNC",
+		"archive":    "ARCHIVE",
+		"cachesize":  "CACHE_SIZE",
+		"cachedir":   "CACHE_DIR",
+		"admintoken": "ADMIN_TOKEN",
+
+		"s":                 "FACTOMD_SERVER",
+		"factomdtimeout":    "FACTOMD_TIMEOUT",
+		"factomduser":       "FACTOMD_USER",
+		"factomdpassword":   "FACTOMD_PASSWORD",
+		"factomdroundrobin": "FACTOMD_ROUND_ROBIN",
+		"factomdretries":    "FACTOMD_RETRIES",
+		"factomdretrydelay": "FACTOMD_RETRY_DELAY",
+		"factomdcert":       "FACTOMD_TLS_CERT",
+		"factomdtls":        "FACTOMD_TLS_ENABLE",
+		"factomdclientcert": "FACTOMD_TLS_CLIENT_CERT",
+		"factomdclientkey":  "FACTOMD_TLS_CLIENT_KEY",
+		"factomdservername": "FACTOMD_TLS_SERVER_NAME",
+
+		"w":                "WALLETD_SERVER",
+		"wallettimeout":    "WALLETD_TIMEOUT",
+		"walletuser":       "WALLETD_USER",
+		"walletpassword":   "WALLETD_PASSWORD",
+		"walletcert":       "WALLETD_TLS_CERT",
+		"wallettls":        "WALLETD_TLS_ENABLE",
+		"walletclientcert": "WALLETD_TLS_CLIENT_CERT",
+		"walletclientkey":  "WALLETD_TLS_CLIENT_KEY",
+		"walletservername": "WALLETD_TLS_SERVER_NAME",
 
 		"ecadr": "ECADR",
 		"esadr": "ESADR",
 	}
 	defaults = map[string]interface{}{
 		"startscanheight":   uint64(0),
+		"networkid":         "",
+		"activationheight":  uint64(0),
 		"factomscanretries": int64(0),
+		"fetchworkers":      uint64(8),
+		"fetchwindow":       uint64(16),
 		"debug":             false,
 
-		"dbpath": "./fatd.db",
-
-		"apiaddress": ":8078",
-
-		"s":               "http://localhost:8088",
-		"factomdtimeout":  time.Duration(0),
-		"factomduser":     "",
-		"factomdpassword": "",
-		//"factomdcert":     "",
-		//"factomdtls":      false,
-
-		"w":              "http://localhost:8089",
-		"wallettimeout":  time.Duration(0),
-		"walletuser":     "",
-		"walletpassword": "",
-		//"walletcert":     "",
-		//"wallettls":      false,
+		"dbpath":     "./fatd.db",
+		"validatedb": false,
+		"repairdb":   false,
+
+		"apiaddress":     ":8078",
+		"apitlscert":     "",
+		"apitlskey":      "",
+		"apiuser":        "",
+		"apipassword":    "",
+		"apitoken":       "",
+		"apicorsorigins": "*",
+
+		"webhooks": "",
+
+		"whitelist":  "",
+		"blacklist":  "",
+		"fastsync":   false,
+		"verifysync": false,
+		"archive":    "",
+		"cachesize":  uint64(0),
+		"cachedir":   "",
+		"admintoken": "",
+
+		"s":                 "http://localhost:8088",
+		"factomdtimeout":    time.Duration(0),
+		"factomduser":       "",
+		"factomdpassword":   "",
+		"factomdroundrobin": false,
+		"factomdretries":    uint64(2),
+		"factomdretrydelay": 500 * time.Millisecond,
+		"factomdcert":       "",
+		"factomdtls":        false,
+		"factomdclientcert": "",
+		"factomdclientkey":  "",
+		"factomdservername": "",
+
+		"w":                "http://localhost:8089",
+		"wallettimeout":    time.Duration(0),
+		"walletuser":       "",
+		"walletpassword":   "",
+		"walletcert":       "",
+		"wallettls":        false,
+		"walletclientcert": "",
+		"walletclientkey":  "",
+		"walletservername": "",
 
 		"ecadr": "",
 		"esadr": "",
 	}
 	descriptions = map[string]string{
 		"startscanheight":   "Block height to start scanning for deposits on startup",
+		"networkid":         "Factom network that factomd must be on: mainnet, testnet, or a hex encoded custom network ID",
+		"activationheight":  "Block height of the first FAT chain on the network, defaults to 0 for custom networks",
 		"factomscanretries": "Number of times to consecutively retry fetching the latest height before exiting, use -1 for unlimited",
+		"fetchworkers":      "Maximum number of concurrent requests to factomd while syncing",
+		"fetchwindow":       "Maximum number of DBlocks to fetch ahead of the DBlock being processed while syncing",
 		"debug":             "Log debug messages",
 
-		"dbpath": "Path to the folder containing all database files",
-
-		"apiaddress": "IPAddr:port# to bind to for serving the JSON RPC 2.0 API",
-
-		"s":               "IPAddr:port# of factomd API to use to access blockchain",
-		"factomdtimeout":  "Timeout for factomd API requests, 0 means never timeout",
-		"factomduser":     "Username for API connections to factomd",
-		"factomdpassword": "Password for API connections to factomd",
-		//"factomdcert":     "The TLS certificate that will be provided by the factomd API server",
-		//"factomdtls":      "Set to true to use TLS when accessing the factomd API",
-
-		"w":              "IPAddr:port# of factom-walletd API to use to access wallet",
-		"wallettimeout":  "Timeout for factom-walletd API requests, 0 means never timeout",
-		"walletuser":     "Username for API connections to factom-walletd",
-		"walletpassword": "Password for API connections to factom-walletd",
-		//"walletcert":     "The TLS certificate that will be provided by the factom-walletd API server",
-		//"wallettls":      "Set to true to use TLS when accessing the factom-walletd API",
+		"dbpath":     "Path to the folder containing all database files",
+		"validatedb": "Validate the integrity of all databases on startup",
+		"repairdb":   "Validate and repair the integrity of all databases on startup, after backing up any database that needs repair",
+
+		"apiaddress":     "IPAddr:port# to bind to for serving the JSON RPC 2.0 API",
+		"apitlscert":     "Path to the PEM encoded certificate to serve the API over HTTPS with, which is reloaded when the file changes",
+		"apitlskey":      "Path to the PEM encoded private key of the -apitlscert",
+		"apiuser":        "Username required to use the API with HTTP Basic auth",
+		"apipassword":    "Password required to use the API with HTTP Basic auth",
+		"apitoken":       "Bearer token that may be used instead of HTTP Basic auth to use the API",
+		"apicorsorigins": "Comma separated list of origins allowed to make cross-origin requests and WebSocket connections to the API, use * for any or \"\" for none. The default * allows any origin, as fatd always has, so that existing browser clients keep working",
+
+		"webhooks": "Path to a JSON file of webhooks to POST confirmed transactions to",
+
+		"whitelist":  "Comma separated list of chain IDs or <tokenid>:<issuerid> pairs to exclusively track",
+		"blacklist":  "Comma separated list of chain IDs or <tokenid>:<issuerid> pairs to never track",
+		"fastsync":   "Sync only the whitelisted chains by walking their EBlocks, instead of scanning every DBlock, then continue syncing from the current Factom height",
+		"archive":    "Path to an archive of Factom blocks written by fat-cli export to sync from, instead of factomd",
+		"cachesize":  "Number of DBlocks, EBlocks and Entries from factomd to cache in memory, 0 to disable",
+		"cachedir":   "Path to a directory to cache DBlocks, EBlocks and Entries from factomd on disk",
+		"verifysync": "Verify that each DBlock links to the previous DBlock, and that all EBlocks and Entries match the DBlocks, instead of trusting factomd",
+		"admintoken": "Bearer token required to use the admin JSON RPC 2.0 API at /admin, which is disabled if empty",
+
+		"s":                 "IPAddr:port# of factomd API to use to access blockchain, or a comma separated list to fail over between",
+		"factomdtimeout":    "Timeout for factomd API requests, 0 means never timeout",
+		"factomduser":       "Username for API connections to factomd",
+		"factomdpassword":   "Password for API connections to factomd",
+		"factomdroundrobin": "Spread requests across all healthy factomd servers listed by -s, instead of preferring them in order",
+		"factomdretries":    "Number of times to retry a failed factomd API request using the next factomd server",
+		"factomdretrydelay": "Delay before the first retry of a failed factomd API request, which doubles for each subsequent retry",
+		"factomdcert":       "Path to a PEM encoded bundle of CA certificates to verify the factomd API server's TLS certificate, instead of the system's",
+		"factomdtls":        "Set to true to use TLS when accessing the factomd API, even if the URL's scheme is http",
+		"factomdclientcert": "Path to a PEM encoded client certificate to present to the factomd API server for mutual TLS",
+		"factomdclientkey":  "Path to the PEM encoded private key of the factomdclientcert",
+		"factomdservername": "Host name to verify the factomd API server's TLS certificate against, instead of the host in the URL",
+
+		"w":                "IPAddr:port# of factom-walletd API to use to access wallet",
+		"wallettimeout":    "Timeout for factom-walletd API requests, 0 means never timeout",
+		"walletuser":       "Username for API connections to factom-walletd",
+		"walletpassword":   "Password for API connections to factom-walletd",
+		"walletcert":       "Path to a PEM encoded bundle of CA certificates to verify the factom-walletd API server's TLS certificate, instead of the system's",
+		"wallettls":        "Set to true to use TLS when accessing the factom-walletd API, even if the URL's scheme is http",
+		"walletclientcert": "Path to a PEM encoded client certificate to present to the factom-walletd API server for mutual TLS",
+		"walletclientkey":  "Path to the PEM encoded private key of the walletclientcert",
+		"walletservername": "Host name to verify the factom-walletd API server's TLS certificate against, instead of the host in the URL",
 
 		"ecadr": "Entry Credit Public Address to use to pay for Factom entries",
 		"esadr": "Entry Credit Secret Address to use to pay for Factom entries",
 	}
 	flags = complete.Flags{
 		"-startscanheight":   complete.PredictAnything,
+		"-networkid":         complete.PredictSet("mainnet", "testnet"),
+		"-activationheight":  complete.PredictAnything,
 		"-factomscanretries": complete.PredictAnything,
+		"-fetchworkers":      complete.PredictAnything,
+		"-fetchwindow":       complete.PredictAnything,
 		"-debug":             complete.PredictNothing,
 
-		"-dbpath": complete.PredictFiles("*"),
-
-		"-apiaddress": complete.PredictAnything,
-
-		"-s":               complete.PredictAnything,
-		"-factomdtimeout":  complete.PredictAnything,
-		"-factomduser":     complete.PredictAnything,
-		"-factomdpassword": complete.PredictAnything,
-		//"-factomdcert":     complete.PredictFiles("*"),
-		//"-factomdtls":      complete.PredictNothing,
-
-		"-w":              complete.PredictAnything,
-		"-wallettimeout":  complete.PredictAnything,
-		"-walletuser":     complete.PredictAnything,
-		"-walletpassword": complete.PredictAnything,
-		//"-walletcert":     complete.PredictFiles("*"),
-		//"-wallettls":      complete.PredictNothing,
+		"-dbpath":     complete.PredictFiles("*"),
+		"-validatedb": complete.PredictNothing,
+		"-repairdb":   complete.PredictNothing,
+
+		"-apiaddress":     complete.PredictAnything,
+		"-apitlscert":     complete.PredictFiles("*"),
+		"-apitlskey":      complete.PredictFiles("*"),
+		"-apiuser":        complete.PredictAnything,
+		"-apipassword":    complete.PredictAnything,
+		"-apitoken":       complete.PredictAnything,
+		"-apicorsorigins": complete.PredictAnything,
+
+		"-webhooks": complete.PredictFiles("*.json"),
+
+		"-whitelist":  complete.PredictAnything,
+		"-blacklist":  complete.PredictAnything,
+		"-fastsync":   complete.PredictNothing,
+		"-verifysync": complete.PredictNothing,
+		"-archive":    complete.PredictDirs("*"),
+		"-cachesize":  complete.PredictAnything,
+		"-cachedir":   complete.PredictDirs("*"),
+		"-admintoken": complete.PredictAnything,
+
+		"-s":                 complete.PredictAnything,
+		"-factomdtimeout":    complete.PredictAnything,
+		"-factomduser":       complete.PredictAnything,
+		"-factomdpassword":   complete.PredictAnything,
+		"-factomdroundrobin": complete.PredictNothing,
+		"-factomdretries":    complete.PredictAnything,
+		"-factomdretrydelay": complete.PredictAnything,
+		"-factomdcert":       complete.PredictFiles("*"),
+		"-factomdtls":        complete.PredictNothing,
+		"-factomdclientcert": complete.PredictFiles("*"),
+		"-factomdclientkey":  complete.PredictFiles("*"),
+		"-factomdservername": complete.PredictAnything,
+
+		"-w":                complete.PredictAnything,
+		"-wallettimeout":    complete.PredictAnything,
+		"-walletuser":       complete.PredictAnything,
+		"-walletpassword":   complete.PredictAnything,
+		"-walletcert":       complete.PredictFiles("*"),
+		"-wallettls":        complete.PredictNothing,
+		"-walletclientcert": complete.PredictFiles("*"),
+		"-walletclientkey":  complete.PredictFiles("*"),
+		"-walletservername": complete.PredictAnything,
 
 		"-y":                   complete.PredictNothing,
 		"-installcompletion":   complete.PredictNothing,
@@ -150,17 +278,45 @@ var (
 
 	startScanHeight   uint64      // We parse the flag as unsigned.
 	StartScanHeight   int32  = -1 // We work with the signed value.
+	FactomNetworkID   factom.NetworkID
+	activationHeight  uint64
+	ActivationHeight  int32 = -1
 	LogDebug          bool
 	FactomScanRetries int64 = -1
+	FetchWorkers      uint64
+	FetchWindow       uint64
 
 	EsAdr factom.EsAddress
 	ECAdr factom.ECAddress
 
-	DBPath string
-
-	APIAddress string
-
-	FactomClient = factom.NewClient()
+	DBPath     string
+	ValidateDB bool
+	RepairDB   bool
+
+	APIAddress     string
+	APITLSCert     string
+	APITLSKey      string
+	APIUser        string
+	APIPassword    string
+	APIToken       string
+	APICORSOrigins string
+
+	Webhooks string
+
+	Whitelist   ChainIDList
+	Blacklist   ChainIDList
+	FastSync    bool
+	VerifySync  bool
+	ArchivePath string
+	CacheSize   uint64
+	CacheDir    string
+	AdminToken  string
+
+	FactomClient     = factom.NewClient()
+	factomdTLS       factom.TLSOptions
+	factomdTLSEnable bool
+	walletdTLS       factom.TLSOptions
+	walletdTLSEnable bool
 
 	flagset    map[string]bool
 	log        *logrus.Entry
@@ -169,12 +325,35 @@ var (
 
 func init() {
 	flagVar(&startScanHeight, "startscanheight")
+	flagVar(&FactomNetworkID, "networkid")
+	flagVar(&activationHeight, "activationheight")
 	flagVar(&FactomScanRetries, "factomscanretries")
+	flagVar(&FetchWorkers, "fetchworkers")
+	flagVar(&FetchWindow, "fetchwindow")
 	flagVar(&LogDebug, "debug")
 
 	flagVar(&DBPath, "dbpath")
+	flagVar(&ValidateDB, "validatedb")
+	flagVar(&RepairDB, "repairdb")
 
 	flagVar(&APIAddress, "apiaddress")
+	flagVar(&APITLSCert, "apitlscert")
+	flagVar(&APITLSKey, "apitlskey")
+	flagVar(&APIUser, "apiuser")
+	flagVar(&APIPassword, "apipassword")
+	flagVar(&APIToken, "apitoken")
+	flagVar(&APICORSOrigins, "apicorsorigins")
+
+	flagVar(&Webhooks, "webhooks")
+
+	flagVar(&Whitelist, "whitelist")
+	flagVar(&Blacklist, "blacklist")
+	flagVar(&FastSync, "fastsync")
+	flagVar(&VerifySync, "verifysync")
+	flagVar(&ArchivePath, "archive")
+	flagVar(&CacheSize, "cachesize")
+	flagVar(&CacheDir, "cachedir")
+	flagVar(&AdminToken, "admintoken")
 
 	flagVar(&ECAdr, "ecadr")
 	flagVar(&EsAdr, "esadr")
@@ -183,15 +362,24 @@ func init() {
 	flagVar(&FactomClient.Factomd.Timeout, "factomdtimeout")
 	flagVar(&FactomClient.Factomd.User, "factomduser")
 	flagVar(&FactomClient.Factomd.Password, "factomdpassword")
-	//flagVar(&FactomClient.Factomd.TLSCertFile, "factomdcert")
-	//flagVar(&FactomClient.Factomd.TLSEnable, "factomdtls")
+	flagVar(&FactomClient.FactomdRoundRobin, "factomdroundrobin")
+	flagVar(&FactomClient.FactomdRetries, "factomdretries")
+	flagVar(&FactomClient.FactomdRetryDelay, "factomdretrydelay")
+	flagVar(&factomdTLS.CAFile, "factomdcert")
+	flagVar(&factomdTLSEnable, "factomdtls")
+	flagVar(&factomdTLS.CertFile, "factomdclientcert")
+	flagVar(&factomdTLS.KeyFile, "factomdclientkey")
+	flagVar(&factomdTLS.ServerName, "factomdservername")
 
 	flagVar(&FactomClient.WalletdServer, "w")
 	flagVar(&FactomClient.Walletd.Timeout, "wallettimeout")
 	flagVar(&FactomClient.Walletd.User, "walletuser")
 	flagVar(&FactomClient.Walletd.Password, "walletpassword")
-	//flagVar(&FactomClient.Walletd.TLSCertFile, "walletcert")
-	//flagVar(&FactomClient.Walletd.TLSEnable, "wallettls")
+	flagVar(&walletdTLS.CAFile, "walletcert")
+	flagVar(&walletdTLSEnable, "wallettls")
+	flagVar(&walletdTLS.CertFile, "walletclientcert")
+	flagVar(&walletdTLS.KeyFile, "walletclientkey")
+	flagVar(&walletdTLS.ServerName, "walletservername")
 
 	// Add flags for self installing the CLI completion tool
 	Completion = complete.New(os.Args[0], complete.Command{Flags: flags})
@@ -210,26 +398,58 @@ func Parse() {
 	// Load options from environment variables if they haven't been
 	// specified on the command line.
 	loadFromEnv(&startScanHeight, "startscanheight")
+	loadFromEnv(&FactomNetworkID, "networkid")
+	loadFromEnv(&activationHeight, "activationheight")
 	loadFromEnv(&FactomScanRetries, "factomscanretries")
+	loadFromEnv(&FetchWorkers, "fetchworkers")
+	loadFromEnv(&FetchWindow, "fetchwindow")
 	loadFromEnv(&LogDebug, "debug")
 
 	loadFromEnv(&DBPath, "dbpath")
+	loadFromEnv(&ValidateDB, "validatedb")
+	loadFromEnv(&RepairDB, "repairdb")
 
 	loadFromEnv(&APIAddress, "apiaddress")
+	loadFromEnv(&APITLSCert, "apitlscert")
+	loadFromEnv(&APITLSKey, "apitlskey")
+	loadFromEnv(&APIUser, "apiuser")
+	loadFromEnv(&APIPassword, "apipassword")
+	loadFromEnv(&APIToken, "apitoken")
+	loadFromEnv(&APICORSOrigins, "apicorsorigins")
+
+	loadFromEnv(&Webhooks, "webhooks")
+
+	loadFromEnv(&Whitelist, "whitelist")
+	loadFromEnv(&Blacklist, "blacklist")
+	loadFromEnv(&FastSync, "fastsync")
+	loadFromEnv(&VerifySync, "verifysync")
+	loadFromEnv(&ArchivePath, "archive")
+	loadFromEnv(&CacheSize, "cachesize")
+	loadFromEnv(&CacheDir, "cachedir")
+	loadFromEnv(&AdminToken, "admintoken")
 
 	loadFromEnv(&FactomClient.FactomdServer, "s")
 	loadFromEnv(&FactomClient.Factomd.Timeout, "factomdtimeout")
 	loadFromEnv(&FactomClient.Factomd.User, "factomduser")
 	loadFromEnv(&FactomClient.Factomd.Password, "factomdpassword")
-	//loadFromEnv(&FactomClient.Factomd.TLSCertFile, "factomdcert")
-	//loadFromEnv(&FactomClient.Factomd.TLSEnable, "factomdtls")
+	loadFromEnv(&FactomClient.FactomdRoundRobin, "factomdroundrobin")
+	loadFromEnv(&FactomClient.FactomdRetries, "factomdretries")
+	loadFromEnv(&FactomClient.FactomdRetryDelay, "factomdretrydelay")
+	loadFromEnv(&factomdTLS.CAFile, "factomdcert")
+	loadFromEnv(&factomdTLSEnable, "factomdtls")
+	loadFromEnv(&factomdTLS.CertFile, "factomdclientcert")
+	loadFromEnv(&factomdTLS.KeyFile, "factomdclientkey")
+	loadFromEnv(&factomdTLS.ServerName, "factomdservername")
 
 	loadFromEnv(&FactomClient.WalletdServer, "w")
 	loadFromEnv(&FactomClient.Walletd.Timeout, "walletdtimeout")
 	loadFromEnv(&FactomClient.Walletd.User, "walletuser")
 	loadFromEnv(&FactomClient.Walletd.Password, "walletpassword")
-	//loadFromEnv(&FactomClient.Walletd.TLSCertFile, "walletcert")
-	//loadFromEnv(&FactomClient.Walletd.TLSEnable, "wallettls")
+	loadFromEnv(&walletdTLS.CAFile, "walletcert")
+	loadFromEnv(&walletdTLSEnable, "wallettls")
+	loadFromEnv(&walletdTLS.CertFile, "walletclientcert")
+	loadFromEnv(&walletdTLS.KeyFile, "walletclientkey")
+	loadFromEnv(&walletdTLS.ServerName, "walletservername")
 
 	loadFromEnv(&ECAdr, "ecadr")
 	loadFromEnv(&EsAdr, "esadr")
@@ -237,6 +457,55 @@ func Parse() {
 	if flagset["startscanheight"] {
 		StartScanHeight = int32(startScanHeight)
 	}
+	if flagset["activationheight"] || len(os.Getenv(
+		envName("activationheight"))) > 0 {
+		ActivationHeight = int32(activationHeight)
+	}
+	if RepairDB {
+		ValidateDB = true
+	}
+	if (len(APITLSCert) > 0) != (len(APITLSKey) > 0) {
+		log.Fatalf("-apitlscert and -apitlskey must be used together")
+	}
+	if len(APIPassword) > 0 && len(APIUser) == 0 {
+		log.Fatalf("-apipassword requires -apiuser")
+	}
+	if FetchWorkers == 0 {
+		FetchWorkers = 1
+	}
+	if FetchWindow == 0 {
+		FetchWindow = 1
+	}
+
+	if len(ArchivePath) > 0 {
+		FactomClient.BlockSource = factom.Archive{Dir: ArchivePath}
+	}
+
+	if CacheSize > 0 && len(CacheDir) > 0 {
+		log.Fatalf("-cachesize and -cachedir may not be used together")
+	}
+	if len(CacheDir) > 0 {
+		FactomClient.Cache = factom.DiskCache{Dir: CacheDir}
+	} else if CacheSize > 0 {
+		FactomClient.Cache = factom.NewMemoryCache(int(CacheSize))
+	}
+
+	if factomdTLSEnable {
+		FactomClient.FactomdServer = factom.HTTPS(FactomClient.FactomdServer)
+	}
+	if factomdTLSEnable || factomdTLS.IsSet() {
+		if err := FactomClient.SetFactomdTLS(factomdTLS); err != nil {
+			log.Fatalf("factomd TLS: %v", err)
+		}
+	}
+	if walletdTLSEnable {
+		FactomClient.WalletdServer = factom.HTTPS(FactomClient.WalletdServer)
+	}
+	if walletdTLSEnable || walletdTLS.IsSet() {
+		if err := FactomClient.SetWalletdTLS(walletdTLS); err != nil {
+			log.Fatalf("factom-walletd TLS: %v", err)
+		}
+	}
 }
 
 func Validate() {
@@ -249,25 +518,69 @@ func Validate() {
 	if len(FactomClient.Walletd.Password) > 0 {
 		walletdPassword = "<redacted>"
 	}
+	adminToken := "\"\""
+	if len(AdminToken) > 0 {
+		adminToken = "<redacted>"
+	}
+	apiPassword := "\"\""
+	if len(APIPassword) > 0 {
+		apiPassword = "<redacted>"
+	}
+	apiToken := "\"\""
+	if len(APIToken) > 0 {
+		apiToken = "<redacted>"
+	}
 
 	log.Debugf("-dbpath            %#v", DBPath)
+	log.Debugf("-validatedb        %v ", ValidateDB)
+	log.Debugf("-repairdb          %v ", RepairDB)
 	log.Debugf("-apiaddress        %#v", APIAddress)
+	log.Debugf("-apitlscert        %#v", APITLSCert)
+	log.Debugf("-apitlskey         %#v", APITLSKey)
+	log.Debugf("-apiuser           %#v", APIUser)
+	log.Debugf("-apipassword       %v ", apiPassword)
+	log.Debugf("-apitoken          %v ", apiToken)
+	log.Debugf("-apicorsorigins    %#v", APICORSOrigins)
+	log.Debugf("-webhooks          %#v", Webhooks)
+	log.Debugf("-whitelist         %v ", Whitelist)
+	log.Debugf("-blacklist         %v ", Blacklist)
+	log.Debugf("-fastsync          %v ", FastSync)
+	log.Debugf("-verifysync        %v ", VerifySync)
+	log.Debugf("-archive           %#v", ArchivePath)
+	log.Debugf("-cachesize         %v ", CacheSize)
+	log.Debugf("-cachedir          %#v", CacheDir)
+	log.Debugf("-admintoken        %v ", adminToken)
 	log.Debugf("-startscanheight   %v ", StartScanHeight)
+	log.Debugf("-networkid         %v ", FactomNetworkID)
+	log.Debugf("-activationheight  %v ", ActivationHeight)
 	log.Debugf("-factomscanretries %v ", FactomScanRetries)
+	log.Debugf("-fetchworkers      %v ", FetchWorkers)
+	log.Debugf("-fetchwindow       %v ", FetchWindow)
 	debugPrintln()
 
 	log.Debugf("-s              %#v", FactomClient.FactomdServer)
 	log.Debugf("-factomdtimeout %v ", FactomClient.Factomd.Timeout)
 	log.Debugf("-factomduser    %#v", FactomClient.Factomd.User)
 	log.Debugf("-factomdpass    %v ", factomdPassword)
-	//log.Debugf("-factomdcert    %#v", FactomClient.Factomd.TLSCertFile)
+	log.Debugf("-factomdroundrobin %v ", FactomClient.FactomdRoundRobin)
+	log.Debugf("-factomdretries    %v ", FactomClient.FactomdRetries)
+	log.Debugf("-factomdretrydelay %v ", FactomClient.FactomdRetryDelay)
+	log.Debugf("-factomdtls     %v ", factomdTLSEnable)
+	log.Debugf("-factomdcert    %#v", factomdTLS.CAFile)
+	log.Debugf("-factomdclientcert %#v", factomdTLS.CertFile)
+	log.Debugf("-factomdclientkey  %#v", factomdTLS.KeyFile)
+	log.Debugf("-factomdservername %#v", factomdTLS.ServerName)
 	debugPrintln()
 
 	log.Debugf("-w              %#v", FactomClient.WalletdServer)
 	log.Debugf("-wallettimeout %v ", FactomClient.Walletd.Timeout)
 	log.Debugf("-walletuser    %#v", FactomClient.Walletd.User)
 	log.Debugf("-walletpass    %v ", walletdPassword)
-	//log.Debugf("-walletcert    %#v", FactomClient.Walletd.TLSCertFile)
+	log.Debugf("-wallettls     %v ", walletdTLSEnable)
+	log.Debugf("-walletcert    %#v", walletdTLS.CAFile)
+	log.Debugf("-walletclientcert %#v", walletdTLS.CertFile)
+	log.Debugf("-walletclientkey  %#v", walletdTLS.KeyFile)
+	log.Debugf("-walletservername %#v", walletdTLS.ServerName)
 	debugPrintln()
 
 	var zero factom.EsAddress
@@ -30,6 +30,7 @@ import (
 	"github.com/Factom-Asset-Tokens/fatd/flag"
 	"github.com/Factom-Asset-Tokens/fatd/log"
 	"github.com/Factom-Asset-Tokens/fatd/srv"
+	"github.com/Factom-Asset-Tokens/fatd/webhook"
 )
 
 func main() { os.Exit(_main()) }
@@ -52,6 +53,19 @@ func _main() (ret int) {
 	log.Info("Fatd Version: ", flag.Revision)
 	defer log.Info("Factom Asset Token Daemon stopped.")
 
+	// Webhooks must be started before the engine so that no transactions
+	// are missed.
+	stopWebhook := make(chan struct{})
+	webhookDone := webhook.Start(stopWebhook)
+	if webhookDone == nil {
+		return 1
+	}
+	defer func() {
+		close(stopWebhook) // Stop webhook deliveries.
+		<-webhookDone      // Wait for webhook deliveries to stop.
+		log.Info("Webhook deliveries stopped.")
+	}()
+
 	// Engine
 	stopEngine := make(chan struct{})
 	engineDone := engine.Start(stopEngine)
@@ -85,8 +99,12 @@ func _main() (ret int) {
 	case <-sigint:
 		log.Infof("SIGINT: Shutting down...")
 		return 0
+	case <-srv.ShutdownRequested():
+		log.Infof("Admin shutdown requested: Shutting down...")
+		return 0
 	case <-engineDone: // Closed if engine exits prematurely.
 	case <-srvDone: // Closed if server exits prematurely.
+	case <-webhookDone: // Closed if webhook deliveries exit prematurely.
 	}
 	return 1
 }
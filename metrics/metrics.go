@@ -0,0 +1,115 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+// Package metrics declares fatd's Prometheus metrics and serves them in the
+// Prometheus text exposition format.
+//
+// The metric types are thin wrappers around the Prometheus client library.
+// Metrics are registered with the package's Registry when they are created and
+// are typically declared as package level variables. Creating two metrics with
+// the same name, or a metric with a name or label name that is not valid in the
+// Prometheus data model, panics.
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// DefBuckets are the default Histogram buckets, in seconds, suitable for
+// measuring the latency of requests.
+var DefBuckets = prometheus.DefBuckets
+
+// Registry holds all metrics created by this package.
+var Registry = prometheus.NewRegistry()
+
+// Handler returns an http.Handler that serves all registered metrics.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// Counter is a monotonically increasing value, partitioned by labels.
+type Counter struct {
+	vec *prometheus.CounterVec
+}
+
+// NewCounter registers and returns a new Counter with the given label names.
+func NewCounter(name, help string, labels ...string) *Counter {
+	vec := prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: name, Help: help}, labels)
+	Registry.MustRegister(vec)
+	return &Counter{vec: vec}
+}
+
+// Inc increments the Counter for the given label values by 1.
+func (c *Counter) Inc(labelValues ...string) {
+	c.vec.WithLabelValues(labelValues...).Inc()
+}
+
+// Add adds v, which must not be negative, to the Counter for the given label
+// values.
+func (c *Counter) Add(v float64, labelValues ...string) {
+	c.vec.WithLabelValues(labelValues...).Add(v)
+}
+
+// GaugeFunc is a gauge whose value is computed by a function when the metrics
+// are collected.
+type GaugeFunc struct {
+	prometheus.GaugeFunc
+}
+
+// NewGaugeFunc registers and returns a new GaugeFunc. The function f must be
+// safe to call concurrently.
+func NewGaugeFunc(name, help string, f func() float64) *GaugeFunc {
+	g := prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{Name: name, Help: help}, f)
+	Registry.MustRegister(g)
+	return &GaugeFunc{g}
+}
+
+// Histogram counts observations in cumulative buckets, partitioned by labels.
+type Histogram struct {
+	vec *prometheus.HistogramVec
+}
+
+// NewHistogram registers and returns a new Histogram with the given upper
+// bucket bounds, which must be sorted in increasing order, and label names.
+func NewHistogram(name, help string, buckets []float64,
+	labels ...string) *Histogram {
+	vec := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: name, Help: help, Buckets: buckets}, labels)
+	Registry.MustRegister(vec)
+	return &Histogram{vec: vec}
+}
+
+// Observe adds v to the Histogram for the given label values.
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	h.vec.WithLabelValues(labelValues...).Observe(v)
+}
+
+// ObserveDuration observes the seconds elapsed since start.
+func (h *Histogram) ObserveDuration(start time.Time, labelValues ...string) {
+	h.Observe(time.Since(start).Seconds(), labelValues...)
+}
@@ -0,0 +1,148 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package metrics
+
+import (
+	"math"
+	"net/http/httptest"
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func scrape(t *testing.T) map[string]*dto.MetricFamily {
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	require.Equal(t, 200, rec.Code)
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(rec.Body)
+	require.NoError(t, err)
+	return families
+}
+
+// find returns the metric in f with the given label values, or nil.
+func find(f *dto.MetricFamily, labels map[string]string) *dto.Metric {
+	for _, m := range f.GetMetric() {
+		if len(m.GetLabel()) != len(labels) {
+			continue
+		}
+		match := true
+		for _, l := range m.GetLabel() {
+			if v, ok := labels[l.GetName()]; !ok || v != l.GetValue() {
+				match = false
+				break
+			}
+		}
+		if match {
+			return m
+		}
+	}
+	return nil
+}
+
+func TestMetrics(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	counter := NewCounter("test_counter_total", "A test counter.",
+		"method", "code")
+	counter.Inc("get", "200")
+	counter.Add(2, "get", "200")
+	counter.Inc("put", `"quoted"\`)
+	assert.Panics(func() { counter.Inc("get") })
+	assert.Panics(func() { counter.Add(-1, "get", "200") })
+	assert.Panics(func() { NewCounter("test_counter_total", "") })
+	assert.Panics(func() { NewCounter("invalid-name", "") })
+	assert.Panics(func() { NewCounter("test_invalid_label", "", "a-b") })
+	invalid := NewHistogram("test_invalid_le", "", DefBuckets, "le")
+	assert.Panics(func() { invalid.Observe(1, "1") })
+
+	NewGaugeFunc("test_gauge", "A test\ngauge.",
+		func() float64 { return math.Inf(1) })
+
+	hist := NewHistogram("test_seconds", "A test histogram.",
+		[]float64{.1, 1})
+	hist.Observe(.05)
+	hist.Observe(.1)
+	hist.Observe(.5)
+	hist.Observe(2)
+
+	escaped := NewCounter("test_escaped_total",
+		"Help with \\ and\nnewline.", "value")
+	values := []string{"", `"`, `\`, "\n", "\n\"\\", `\n`, `\"`,
+		"{a=\"b\"} 1\nb 2", "é☃", " trailing space "}
+	for i, v := range values {
+		escaped.Add(float64(i+1), v)
+	}
+
+	families := scrape(t)
+
+	f := families["test_counter_total"]
+	require.NotNil(f)
+	assert.Equal(dto.MetricType_COUNTER, f.GetType())
+	assert.Equal("A test counter.", f.GetHelp())
+	assert.Len(f.GetMetric(), 2)
+	m := find(f, map[string]string{"method": "get", "code": "200"})
+	require.NotNil(m)
+	assert.Equal(float64(3), m.GetCounter().GetValue())
+	m = find(f, map[string]string{"method": "put", "code": `"quoted"\`})
+	require.NotNil(m)
+	assert.Equal(float64(1), m.GetCounter().GetValue())
+
+	f = families["test_gauge"]
+	require.NotNil(f)
+	assert.Equal(dto.MetricType_GAUGE, f.GetType())
+	assert.Equal("A test\ngauge.", f.GetHelp())
+	require.Len(f.GetMetric(), 1)
+	assert.True(math.IsInf(f.GetMetric()[0].GetGauge().GetValue(), 1))
+
+	f = families["test_seconds"]
+	require.NotNil(f)
+	assert.Equal(dto.MetricType_HISTOGRAM, f.GetType())
+	require.Len(f.GetMetric(), 1)
+	h := f.GetMetric()[0].GetHistogram()
+	assert.Equal(uint64(4), h.GetSampleCount())
+	assert.InDelta(2.65, h.GetSampleSum(), 1e-9)
+	require.Len(h.GetBucket(), 3)
+	assert.Equal(.1, h.GetBucket()[0].GetUpperBound())
+	assert.Equal(uint64(2), h.GetBucket()[0].GetCumulativeCount())
+	assert.Equal(float64(1), h.GetBucket()[1].GetUpperBound())
+	assert.Equal(uint64(3), h.GetBucket()[1].GetCumulativeCount())
+	assert.True(math.IsInf(h.GetBucket()[2].GetUpperBound(), 1))
+	assert.Equal(uint64(4), h.GetBucket()[2].GetCumulativeCount())
+
+	f = families["test_escaped_total"]
+	require.NotNil(f)
+	assert.Equal("Help with \\ and\nnewline.", f.GetHelp())
+	assert.Len(f.GetMetric(), len(values))
+	for i, v := range values {
+		m := find(f, map[string]string{"value": v})
+		if assert.NotNil(m, "%q", v) {
+			assert.Equal(float64(i+1), m.GetCounter().GetValue(),
+				"%q", v)
+		}
+	}
+}
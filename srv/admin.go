@@ -0,0 +1,214 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package srv
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+
+	jrpc "github.com/AdamSLevy/jsonrpc2/v11"
+	"github.com/Factom-Asset-Tokens/fatd/engine"
+	"github.com/Factom-Asset-Tokens/fatd/factom"
+	"github.com/Factom-Asset-Tokens/fatd/flag"
+	"github.com/Factom-Asset-Tokens/fatd/state"
+)
+
+// adminMethods are served separately from jrpcMethods and require the
+// -admintoken.
+var adminMethods = jrpc.MethodMap{
+	"get-chain-filter": getChainFilter,
+	"set-chain-filter": setChainFilter,
+	"get-chains":       getChains,
+	"track-chain":      trackChain,
+	"untrack-chain":    untrackChain,
+	"resync-chain":     resyncChain,
+	"validate-chain":   validateChain,
+	"shutdown":         shutdown,
+}
+
+var (
+	shutdownRequested = make(chan struct{})
+	shutdownOnce      sync.Once
+)
+
+// ShutdownRequested returns a channel that is closed when a shutdown is
+// requested through the admin API.
+func ShutdownRequested() <-chan struct{} {
+	return shutdownRequested
+}
+
+// adminHandler serves the adminMethods to requests that have the -admintoken
+// as a Bearer token in the Authorization header. If no -admintoken is set, the
+// admin API is disabled and all requests are not found.
+func adminHandler(handler http.HandlerFunc) http.Handler {
+	if len(flag.AdminToken) == 0 {
+		return http.NotFoundHandler()
+	}
+	token := []byte(flag.AdminToken)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		const prefix = "Bearer "
+		if !strings.HasPrefix(auth, prefix) ||
+			subtle.ConstantTimeCompare(
+				[]byte(auth[len(prefix):]), token) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="fatd"`)
+			http.Error(w, http.StatusText(http.StatusUnauthorized),
+				http.StatusUnauthorized)
+			return
+		}
+		handler(w, r)
+	})
+}
+
+type ResultGetChainFilter struct {
+	Whitelist []factom.Bytes32 `json:"whitelist"`
+	Blacklist []factom.Bytes32 `json:"blacklist"`
+}
+
+func getChainFilter(data json.RawMessage) interface{} {
+	if _, err := validate(data, nil); err != nil {
+		return err
+	}
+	whitelist, blacklist := state.GetChainFilter()
+	return ResultGetChainFilter{Whitelist: whitelist, Blacklist: blacklist}
+}
+
+func setChainFilter(data json.RawMessage) interface{} {
+	params := ParamsSetChainFilter{}
+	if _, err := validate(data, &params); err != nil {
+		return err
+	}
+	state.SetChainFilter(params.Whitelist.ChainIDs(),
+		params.Blacklist.ChainIDs())
+	engine.ChainFilterChanged()
+	whitelist, blacklist := state.GetChainFilter()
+	return ResultGetChainFilter{Whitelist: whitelist, Blacklist: blacklist}
+}
+
+// adminError returns an ErrorAdminFailed with the message of err as the data.
+func adminError(err error) *jrpc.Error {
+	return jrpc.NewError(ErrorAdminFailed.Code, ErrorAdminFailed.Message,
+		err.Error())
+}
+
+type ResultGetChain struct {
+	ChainID       *factom.Bytes32 `json:"chainid"`
+	TokenID       string          `json:"tokenid"`
+	IssuerChainID *factom.Bytes32 `json:"issuerid"`
+	Status        string          `json:"status"`
+	Height        uint32          `json:"height"`
+	Error         string          `json:"error,omitempty"`
+}
+
+func getChains(data json.RawMessage) interface{} {
+	if _, err := validate(data, nil); err != nil {
+		return err
+	}
+	chains := state.Chains.GetTracked()
+	faults := state.GetFaults()
+	result := make([]ResultGetChain, len(chains))
+	for i, chain := range chains {
+		result[i] = ResultGetChain{
+			ChainID:       chain.ID,
+			TokenID:       chain.Token,
+			IssuerChainID: chain.Issuer,
+			Status:        chain.ChainStatus.String(),
+			Height:        chain.Metadata.Height,
+			Error:         faults[*chain.ID].Err,
+		}
+	}
+	return result
+}
+
+func trackChain(data json.RawMessage) interface{} {
+	params := ParamsAdminChain{}
+	if _, err := validate(data, &params); err != nil {
+		return err
+	}
+	if err := engine.TrackChain(*params.ParamsToken.ValidChainID()); err != nil {
+		return adminError(err)
+	}
+	return struct{}{}
+}
+
+func untrackChain(data json.RawMessage) interface{} {
+	params := ParamsAdminChain{}
+	if _, err := validate(data, &params); err != nil {
+		return err
+	}
+	if err := engine.UntrackChain(*params.ParamsToken.ValidChainID()); err != nil {
+		return adminError(err)
+	}
+	return struct{}{}
+}
+
+func resyncChain(data json.RawMessage) interface{} {
+	params := ParamsResyncChain{}
+	if _, err := validate(data, &params); err != nil {
+		return err
+	}
+	chainID := params.ParamsToken.ValidChainID()
+	if chain := state.Chains.Get(chainID); !chain.IsTracked() &&
+		!chain.IsFaulted() {
+		return ErrorTokenNotFound
+	}
+	if err := engine.ResyncChain(*chainID, *params.Height); err != nil {
+		return adminError(err)
+	}
+	return struct{}{}
+}
+
+type ResultValidateChain struct {
+	Mismatches []string `json:"mismatches"`
+}
+
+func validateChain(data json.RawMessage) interface{} {
+	params := ParamsValidateChain{}
+	if _, err := validate(data, &params); err != nil {
+		return err
+	}
+	chainID := params.ParamsToken.ValidChainID()
+	if chain := state.Chains.Get(chainID); !chain.IsTracked() &&
+		!chain.IsFaulted() {
+		return ErrorTokenNotFound
+	}
+	mismatches, err := engine.ValidateChain(*chainID, params.Repair)
+	if err != nil {
+		return adminError(err)
+	}
+	if mismatches == nil {
+		mismatches = []string{}
+	}
+	return ResultValidateChain{Mismatches: mismatches}
+}
+
+func shutdown(data json.RawMessage) interface{} {
+	if _, err := validate(data, nil); err != nil {
+		return err
+	}
+	shutdownOnce.Do(func() { close(shutdownRequested) })
+	return struct{}{}
+}
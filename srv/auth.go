@@ -0,0 +1,132 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package srv
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Factom-Asset-Tokens/fatd/flag"
+	"github.com/rs/cors"
+)
+
+// authHandler requires requests to handler to use either the -apiuser and
+// -apipassword with HTTP Basic auth, or the -apitoken as a Bearer token, if
+// either are set.
+func authHandler(handler http.Handler) http.Handler {
+	if len(flag.APIUser) == 0 && len(flag.APIToken) == 0 {
+		return handler
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(r) {
+			if len(flag.APIUser) > 0 {
+				w.Header().Add("WWW-Authenticate",
+					`Basic realm="fatd"`)
+			}
+			if len(flag.APIToken) > 0 {
+				w.Header().Add("WWW-Authenticate",
+					`Bearer realm="fatd"`)
+			}
+			http.Error(w, http.StatusText(http.StatusUnauthorized),
+				http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+func authorized(r *http.Request) bool {
+	if len(flag.APIToken) > 0 {
+		auth := r.Header.Get("Authorization")
+		const prefix = "Bearer "
+		if strings.HasPrefix(auth, prefix) &&
+			equal(auth[len(prefix):], flag.APIToken) {
+			return true
+		}
+	}
+	if len(flag.APIUser) > 0 {
+		user, password, ok := r.BasicAuth()
+		// Always compare both to avoid leaking which was wrong.
+		userOK := equal(user, flag.APIUser)
+		passwordOK := equal(password, flag.APIPassword)
+		return ok && userOK && passwordOK
+	}
+	return false
+}
+
+func equal(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// corsHandler allows cross-origin requests to handler from the
+// -apicorsorigins. If no origins are set, no CORS headers are sent, so
+// browsers block all cross-origin requests.
+func corsHandler(handler http.Handler) http.Handler {
+	if len(corsOrigins()) == 0 {
+		return handler
+	}
+	return cors.New(cors.Options{
+		AllowOriginFunc: allowOrigin,
+		AllowedHeaders: []string{"Origin", "Accept", "Content-Type",
+			"X-Requested-With", "Authorization"},
+	}).Handler(handler)
+}
+
+// corsOrigins returns the -apicorsorigins.
+func corsOrigins() []string {
+	var origins []string
+	for _, origin := range strings.Split(flag.APICORSOrigins, ",") {
+		origin = strings.TrimSpace(origin)
+		if len(origin) > 0 {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
+
+// allowOrigin returns true if origin is one of the -apicorsorigins, or if they
+// include "*".
+func allowOrigin(origin string) bool {
+	for _, allowed := range corsOrigins() {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// wsCheckOrigin allows WebSocket connections without an Origin header, from
+// the same host, or from any of the -apicorsorigins.
+func wsCheckOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if len(origin) == 0 {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil &&
+		strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	return allowOrigin(origin)
+}
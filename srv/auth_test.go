@@ -0,0 +1,126 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package srv
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Factom-Asset-Tokens/fatd/flag"
+	"github.com/stretchr/testify/assert"
+)
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+})
+
+func TestAuthHandler(t *testing.T) {
+	defer func(user, password, token string) {
+		flag.APIUser, flag.APIPassword, flag.APIToken = user, password, token
+	}(flag.APIUser, flag.APIPassword, flag.APIToken)
+
+	serve := func(r *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		authHandler(okHandler).ServeHTTP(w, r)
+		return w
+	}
+	newRequest := func(user, password, token string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/v1", nil)
+		if len(user) > 0 {
+			r.SetBasicAuth(user, password)
+		}
+		if len(token) > 0 {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		return r
+	}
+
+	flag.APIUser, flag.APIPassword, flag.APIToken = "", "", ""
+	assert.Equal(t, http.StatusOK, serve(newRequest("", "", "")).Code)
+
+	flag.APIUser, flag.APIPassword = "user", "password"
+	w := serve(newRequest("", "", ""))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, []string{`Basic realm="fatd"`},
+		w.Header()["Www-Authenticate"])
+	assert.Equal(t, http.StatusUnauthorized,
+		serve(newRequest("user", "wrong", "")).Code)
+	assert.Equal(t, http.StatusUnauthorized,
+		serve(newRequest("wrong", "password", "")).Code)
+	assert.Equal(t, http.StatusOK,
+		serve(newRequest("user", "password", "")).Code)
+
+	flag.APIToken = "token"
+	w = serve(newRequest("", "", "wrong"))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, []string{`Basic realm="fatd"`, `Bearer realm="fatd"`},
+		w.Header()["Www-Authenticate"])
+	assert.Equal(t, http.StatusOK, serve(newRequest("", "", "token")).Code)
+	assert.Equal(t, http.StatusOK,
+		serve(newRequest("user", "password", "")).Code)
+
+	flag.APIUser, flag.APIPassword = "", ""
+	assert.Equal(t, http.StatusUnauthorized,
+		serve(newRequest("user", "", "")).Code)
+	assert.Equal(t, http.StatusOK, serve(newRequest("", "", "token")).Code)
+}
+
+func TestCORS(t *testing.T) {
+	defer func(origins string) {
+		flag.APICORSOrigins = origins
+	}(flag.APICORSOrigins)
+
+	allowedOrigin := func(origin string) string {
+		r := httptest.NewRequest(http.MethodPost, "/v1", nil)
+		r.Header.Set("Origin", origin)
+		w := httptest.NewRecorder()
+		corsHandler(okHandler).ServeHTTP(w, r)
+		return w.Header().Get("Access-Control-Allow-Origin")
+	}
+	wsAllowed := func(host, origin string) bool {
+		r := httptest.NewRequest(http.MethodGet, "/ws", nil)
+		r.Host = host
+		if len(origin) > 0 {
+			r.Header.Set("Origin", origin)
+		}
+		return wsCheckOrigin(r)
+	}
+
+	flag.APICORSOrigins = "*"
+	assert.Equal(t, "https://a.example", allowedOrigin("https://a.example"))
+	assert.True(t, wsAllowed("localhost:8078", "https://a.example"))
+
+	flag.APICORSOrigins = "https://a.example, https://b.example"
+	assert.Equal(t, "https://a.example", allowedOrigin("https://a.example"))
+	assert.Equal(t, "https://b.example", allowedOrigin("https://b.example"))
+	assert.Equal(t, "", allowedOrigin("https://c.example"))
+	assert.True(t, wsAllowed("localhost:8078", "https://b.example"))
+	assert.False(t, wsAllowed("localhost:8078", "https://c.example"))
+
+	flag.APICORSOrigins = ""
+	assert.Equal(t, "", allowedOrigin("https://a.example"))
+	assert.False(t, wsAllowed("localhost:8078", "https://a.example"))
+	assert.True(t, wsAllowed("localhost:8078", "http://localhost:8078"))
+	assert.True(t, wsAllowed("localhost:8078", ""))
+}
@@ -24,9 +24,11 @@ package srv
 
 import (
 	"fmt"
+	"net/http"
 	"time"
 
 	jrpc "github.com/AdamSLevy/jsonrpc2/v11"
+	"github.com/Factom-Asset-Tokens/fatd/factom"
 )
 
 // Client makes RPC requests to fatd's APIs. Client embeds a jsonrpc2.Client,
@@ -51,6 +53,30 @@ func NewClient() *Client {
 	return c
 }
 
+// SetToken sets the Bearer token sent with each request to fatd, or removes
+// it if token is empty.
+func (c *Client) SetToken(token string) {
+	if len(token) == 0 {
+		c.Header.Del("Authorization")
+		return
+	}
+	if c.Header == nil {
+		c.Header = make(http.Header)
+	}
+	c.Header.Set("Authorization", "Bearer "+token)
+}
+
+// SetTLS configures the TLS used for requests to fatd servers with the https
+// scheme.
+func (c *Client) SetTLS(o factom.TLSOptions) error {
+	t, err := o.Transport()
+	if err != nil {
+		return err
+	}
+	c.Transport = t
+	return nil
+}
+
 // Request makes a request to fatd's v1 API.
 func (c *Client) Request(method string, params, result interface{}) error {
 	url := c.FatdServer + "/v1"
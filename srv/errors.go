@@ -34,4 +34,9 @@ var (
 		"token is in the process of syncing")
 	ErrorNoEC = jrpc.NewError(-32806, "No Entry Credits",
 		"not configured with entry credits")
+	ErrorHistoryNotAvailable = jrpc.NewError(-32807, "History Not Available",
+		"balance history is not available for the requested height or timestamp")
+	ErrorAdminFailed  = jrpc.NewError(-32808, "Admin Request Failed", nil)
+	ErrorTokenFaulted = jrpc.NewError(-32809, "Token Faulted",
+		"token failed to sync and is being retried, see get-sync-status")
 )
@@ -26,6 +26,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"sort"
 
 	jrpc "github.com/AdamSLevy/jsonrpc2/v11"
 	"github.com/gocraft/dbr"
@@ -49,6 +50,7 @@ var jrpcMethods = jrpc.MethodMap{
 	"get-transaction-entry":  getTransaction(true),
 	"get-transactions":       getTransactions(false),
 	"get-transactions-entry": getTransactions(true),
+	"get-transaction-status": getTransactionStatus,
 	"get-balance":            getBalance,
 	"get-balances":           getBalances,
 	"get-nf-balance":         getNFBalance,
@@ -56,6 +58,9 @@ var jrpcMethods = jrpc.MethodMap{
 	"get-nf-token":           getNFToken,
 	"get-nf-tokens":          getNFTokens,
 
+	"get-pending-transactions":       getPendingTransactions(false),
+	"get-pending-transactions-entry": getPendingTransactions(true),
+
 	"send-transaction": sendTransaction,
 
 	"get-daemon-tokens":     getDaemonTokens,
@@ -98,6 +103,32 @@ type ResultGetTransaction struct {
 	Hash      *factom.Bytes32 `json:"entryhash"`
 	Timestamp int64           `json:"timestamp"`
 	Tx        interface{}     `json:"data"`
+
+	// Status is omitted from the results of get-transactions, which only
+	// returns confirmed transactions.
+	Status string `json:"status,omitempty"`
+
+	// The location in the blockchain where the transaction was confirmed.
+	// These are omitted if the location was not recorded.
+	Height         uint32          `json:"height,omitempty"`
+	EBlockKeyMR    *factom.Bytes32 `json:"ebkeymr,omitempty"`
+	EBlockSequence *uint32         `json:"ebsequence,omitempty"`
+	EntryIndex     *uint32         `json:"entryindex,omitempty"`
+}
+
+func newResultGetTransaction(e state.SavedEntry, tx interface{}) ResultGetTransaction {
+	res := ResultGetTransaction{
+		Hash:      e.Hash,
+		Timestamp: e.Timestamp.Unix(),
+		Tx:        tx,
+		Height:    e.Height,
+	}
+	if e.EBlockKeyMR != nil {
+		res.EBlockKeyMR = e.EBlockKeyMR
+		res.EBlockSequence = &e.EBlockSequence
+		res.EntryIndex = &e.EntryIndex
+	}
+	return res
 }
 
 func getTransaction(getEntry bool) jrpc.MethodFunc {
@@ -108,42 +139,159 @@ func getTransaction(getEntry bool) jrpc.MethodFunc {
 			return err
 		}
 
-		entry, err := chain.GetEntry(params.Hash)
-		if err == gorm.ErrRecordNotFound {
+		entry, status, _, found := lookupTransaction(chain, params.Hash)
+		if !found {
 			return ErrorTransactionNotFound
 		}
-		if err != nil {
-			panic(err)
-		}
 
 		if getEntry {
-			return entry
+			return entry.Entry
 		}
 
-		switch chain.Type {
-		case fat0.Type:
-			tx := fat0.NewTransaction(entry)
-			if err := tx.UnmarshalEntry(); err != nil {
+		tx, err := unmarshalTransaction(chain, entry.Entry)
+		if err != nil {
+			if status != TransactionStatusRejected {
 				panic(err)
 			}
-			return ResultGetTransaction{
-				Hash:      tx.Hash,
-				Timestamp: tx.Timestamp.Unix(),
-				Tx:        tx,
+			// Rejected entries need not be valid transactions.
+			tx = nil
+		}
+		res := newResultGetTransaction(entry, tx)
+		res.Status = status
+		return res
+	}
+}
+
+// lookupTransaction returns the confirmed, pending, or most recently rejected
+// transaction entry for hash, in that order of preference, along with its
+// status. If the entry was rejected, its most recent rejection is also
+// returned.
+func lookupTransaction(chain *state.Chain, hash *factom.Bytes32) (
+	state.SavedEntry, string, state.InvalidEntry, bool) {
+	entry, err := chain.GetEntry(hash)
+	if err == nil {
+		return entry, TransactionStatusConfirmed, state.InvalidEntry{}, true
+	}
+	if err != gorm.ErrRecordNotFound {
+		panic(err)
+	}
+
+	if pe, ok := chain.GetPendingEntry(hash); ok {
+		entry := state.SavedEntry{Entry: pe.Entry}
+		entry.Timestamp = pe.Seen
+		return entry, TransactionStatusPending, state.InvalidEntry{}, true
+	}
+
+	invalid, err := chain.GetInvalidEntries(hash)
+	if err != nil {
+		panic(err)
+	}
+	if len(invalid) == 0 {
+		return state.SavedEntry{}, "", state.InvalidEntry{}, false
+	}
+	return invalid[0].SavedEntry(), TransactionStatusRejected, invalid[0], true
+}
+
+func unmarshalTransaction(chain *state.Chain, e factom.Entry) (interface{}, error) {
+	switch chain.Type {
+	case fat0.Type:
+		tx := fat0.NewTransaction(e)
+		if err := tx.UnmarshalEntry(); err != nil {
+			return nil, err
+		}
+		return tx, nil
+	case fat1.Type:
+		tx := fat1.NewTransaction(e)
+		if err := tx.UnmarshalEntry(); err != nil {
+			return nil, err
+		}
+		return tx, nil
+	default:
+		panic(fmt.Sprintf("unknown FAT type: %v", chain.Type))
+	}
+}
+
+const (
+	TransactionStatusPending   = "pending"
+	TransactionStatusConfirmed = "confirmed"
+	TransactionStatusRejected  = "rejected"
+)
+
+type ResultGetTransactionStatus struct {
+	Hash   *factom.Bytes32 `json:"entryhash"`
+	Status string          `json:"status"`
+
+	// The reason the transaction was rejected. If the entry was rejected
+	// more than once, this is the most recent rejection.
+	Code   state.RejectionCode `json:"code,omitempty"`
+	Reason string              `json:"reason,omitempty"`
+
+	// The location in the blockchain of the entry that was confirmed or
+	// rejected.
+	Height         uint32          `json:"height,omitempty"`
+	EBlockKeyMR    *factom.Bytes32 `json:"ebkeymr,omitempty"`
+	EBlockSequence *uint32         `json:"ebsequence,omitempty"`
+	EntryIndex     *uint32         `json:"entryindex,omitempty"`
+}
+
+func getTransactionStatus(data json.RawMessage) interface{} {
+	params := ParamsGetTransaction{}
+	chain, err := validate(data, &params)
+	if err != nil {
+		return err
+	}
+
+	entry, status, rej, found := lookupTransaction(chain, params.Hash)
+	if !found {
+		return ErrorTransactionNotFound
+	}
+	res := ResultGetTransactionStatus{
+		Hash:   params.Hash,
+		Status: status,
+		Code:   rej.Code,
+		Reason: rej.Reason,
+		Height: entry.Height,
+	}
+	if entry.EBlockKeyMR != nil {
+		res.EBlockKeyMR = entry.EBlockKeyMR
+		res.EBlockSequence = &entry.EBlockSequence
+		res.EntryIndex = &entry.EntryIndex
+	}
+	return res
+}
+
+func getPendingTransactions(getEntry bool) jrpc.MethodFunc {
+	return func(data json.RawMessage) interface{} {
+		params := ParamsToken{}
+		chain, err := validate(data, &params)
+		if err != nil {
+			return err
+		}
+
+		pending := chain.GetPendingEntries()
+		if getEntry {
+			// Omit the ChainID from the response since the client
+			// already knows it.
+			es := make([]factom.Entry, len(pending))
+			for i := range pending {
+				es[i] = pending[i].Entry
+				es[i].ChainID = nil
 			}
-		case fat1.Type:
-			tx := fat1.NewTransaction(entry)
-			if err := tx.UnmarshalEntry(); err != nil {
+			return es
+		}
+
+		txs := make([]ResultGetTransaction, len(pending))
+		for i, pe := range pending {
+			tx, err := unmarshalTransaction(chain, pe.Entry)
+			if err != nil {
 				panic(err)
 			}
-			return ResultGetTransaction{
-				Hash:      tx.Hash,
-				Timestamp: tx.Timestamp.Unix(),
-				Tx:        tx,
-			}
-		default:
-			panic(fmt.Sprintf("unknown FAT type: %v", chain.Type))
+			entry := state.SavedEntry{Entry: pe.Entry}
+			entry.Timestamp = pe.Seen
+			txs[i] = newResultGetTransaction(entry, tx)
+			txs[i].Status = TransactionStatusPending
 		}
+		return txs
 	}
 }
 
@@ -163,6 +311,7 @@ func getTransactions(getEntry bool) jrpc.MethodFunc {
 		// Lookup Txs
 		entries, err := chain.GetEntries(params.StartHash,
 			params.Addresses, params.NFTokenID,
+			params.StartHeight, params.EndHeight,
 			params.ToFrom, params.Order,
 			params.Page, params.Limit)
 		if err == dbr.ErrNotFound {
@@ -177,35 +326,33 @@ func getTransactions(getEntry bool) jrpc.MethodFunc {
 		if getEntry {
 			// Omit the ChainID from the response since the client
 			// already knows it.
+			es := make([]factom.Entry, len(entries))
 			for i := range entries {
-				entries[i].ChainID = nil
+				es[i] = entries[i].Entry
+				es[i].ChainID = nil
 			}
-			return entries
+			return es
 		}
 
 		switch chain.Type {
 		case fat0.Type:
 			txs := make([]ResultGetTransaction, len(entries))
 			for i := range txs {
-				tx := fat0.NewTransaction(entries[i])
+				tx := fat0.NewTransaction(entries[i].Entry)
 				if err := tx.UnmarshalEntry(); err != nil {
 					panic(err)
 				}
-				txs[i].Hash = entries[i].Hash
-				txs[i].Timestamp = entries[i].Timestamp.Unix()
-				txs[i].Tx = tx
+				txs[i] = newResultGetTransaction(entries[i], tx)
 			}
 			return txs
 		case fat1.Type:
 			txs := make([]ResultGetTransaction, len(entries))
 			for i := range txs {
-				tx := fat1.NewTransaction(entries[i])
+				tx := fat1.NewTransaction(entries[i].Entry)
 				if err := tx.UnmarshalEntry(); err != nil {
 					panic(err)
 				}
-				txs[i].Hash = entries[i].Hash
-				txs[i].Timestamp = entries[i].Timestamp.Unix()
-				txs[i].Tx = tx
+				txs[i] = newResultGetTransaction(entries[i], tx)
 			}
 			return txs
 		default:
@@ -222,6 +369,18 @@ func getBalance(data json.RawMessage) interface{} {
 		return err
 	}
 
+	if params.ParamsHistory.IsSet() {
+		balance, err := chain.GetBalanceAsOf(params.Address,
+			params.AsOf())
+		if err == state.ErrHistoryNotAvailable {
+			return ErrorHistoryNotAvailable
+		}
+		if err != nil {
+			panic(err)
+		}
+		return balance
+	}
+
 	adr, err := chain.GetAddress(params.Address)
 	if err != nil {
 		panic(err)
@@ -264,12 +423,26 @@ func getBalances(data json.RawMessage) interface{} {
 	balances := make(ResultGetBalances, len(issuedIDs))
 	for _, chainID := range issuedIDs {
 		chain := state.Chains.Get(&chainID)
-		adr, err := chain.GetAddress(params.Address)
-		if err != nil {
-			panic(err)
+		var balance uint64
+		if params.ParamsHistory.IsSet() {
+			var err error
+			balance, err = chain.GetBalanceAsOf(params.Address,
+				params.AsOf())
+			if err == state.ErrHistoryNotAvailable {
+				return ErrorHistoryNotAvailable
+			}
+			if err != nil {
+				panic(err)
+			}
+		} else {
+			adr, err := chain.GetAddress(params.Address)
+			if err != nil {
+				panic(err)
+			}
+			balance = adr.Balance
 		}
-		if adr.Balance > 0 {
-			balances[chainID] = adr.Balance
+		if balance > 0 {
+			balances[chainID] = balance
 		}
 	}
 	return balances
@@ -288,8 +461,17 @@ func getNFBalance(data json.RawMessage) interface{} {
 		return err
 	}
 
-	tkns, err := chain.GetNFTokensForOwner(params.Address,
-		params.Page, params.Limit, params.Order)
+	var tkns fat1.NFTokens
+	if params.ParamsHistory.IsSet() {
+		tkns, err = chain.GetNFTokensForOwnerAsOf(params.Address,
+			params.AsOf(), params.Page, params.Limit, params.Order)
+		if err == state.ErrHistoryNotAvailable {
+			return ErrorHistoryNotAvailable
+		}
+	} else {
+		tkns, err = chain.GetNFTokensForOwner(params.Address,
+			params.Page, params.Limit, params.Order)
+	}
 	if err != nil {
 		panic(err)
 	}
@@ -327,7 +509,7 @@ func getStats(data json.RawMessage) interface{} {
 		panic(err)
 	}
 	burned := coinbase.Balance
-	txs, err := chain.GetEntries(nil, nil, nil, "", "", 0, 0)
+	txs, err := chain.GetEntries(nil, nil, nil, nil, nil, "", "", 0, 0)
 	if err != nil {
 		panic(err)
 	}
@@ -428,6 +610,7 @@ func sendTransaction(data json.RawMessage) interface{} {
 
 	entry := params.Entry()
 	hash, _ := entry.ComputeHash()
+	entry.Hash = &hash
 	transaction, err := chain.GetEntry(&hash)
 	if transaction.IsPopulated() {
 		err := ErrorInvalidTransaction
@@ -438,18 +621,24 @@ func sendTransaction(data json.RawMessage) interface{} {
 		panic(err)
 	}
 
-	switch chain.Type {
-	case fat0.Type:
-		if err := validFAT0Transaction(chain, entry); err != nil {
-			return err
-		}
-	case fat1.Type:
-		if err := validFAT1Transaction(chain, entry); err != nil {
-			return err
+	// Validate the transaction against the saved state after all pending
+	// transactions, and reserve its inputs until it is confirmed.
+	if err := chain.AddPendingEntry(entry); err != nil {
+		reason, ok := state.RejectionReason(err)
+		if !ok {
+			log.Error(err)
+			panic(err)
 		}
-	default:
-		panic("invalid FAT type")
+		rpcErr := ErrorInvalidTransaction
+		rpcErr.Data = reason
+		return rpcErr
 	}
+	var submitted bool
+	defer func() {
+		if !submitted {
+			chain.RemovePendingEntry(&hash)
+		}
+	}()
 
 	balance, err := flag.ECAdr.GetBalance(c)
 	if err != nil {
@@ -469,6 +658,7 @@ func sendTransaction(data json.RawMessage) interface{} {
 		log.Error(err)
 		panic(err)
 	}
+	submitted = true
 
 	return struct {
 		ChainID *factom.Bytes32 `json:"chainid"`
@@ -477,95 +667,6 @@ func sendTransaction(data json.RawMessage) interface{} {
 	}{ChainID: chain.ID, TxID: txID, Hash: entry.Hash}
 }
 
-func validFAT0Transaction(chain *state.Chain, entry factom.Entry) error {
-	tx := fat0.NewTransaction(entry)
-	rpcErr := ErrorInvalidTransaction
-	if err := tx.Valid(chain.ID1); err != nil {
-		rpcErr.Data = err.Error()
-		return rpcErr
-	}
-
-	// check balances
-	if tx.IsCoinbase() {
-		if tx.Inputs.Sum() > uint64(chain.Supply)-chain.Issued {
-			rpcErr.Data = "insufficient coinbase supply"
-			return rpcErr
-		}
-		return nil
-	}
-	for rcdHash, amount := range tx.Inputs {
-		adr, err := chain.GetAddress(&rcdHash)
-		if err != nil {
-			log.Error(err)
-			panic(err)
-		}
-		if amount > adr.Balance {
-			rpcErr.Data = fmt.Sprintf("insufficient balance: %v", rcdHash)
-			return rpcErr
-		}
-	}
-	return nil
-}
-
-func validFAT1Transaction(chain *state.Chain, entry factom.Entry) error {
-	tx := fat1.NewTransaction(entry)
-	rpcErr := ErrorInvalidTransaction
-	if err := tx.Valid(chain.ID1); err != nil {
-		rpcErr.Data = err.Error()
-		return rpcErr
-	}
-
-	for rcdHash, tkns := range tx.Inputs {
-		adr, err := chain.GetAddress(&rcdHash)
-		if err != nil {
-			log.Error(err)
-			panic(err)
-		}
-		if tx.IsCoinbase() {
-			if chain.Supply > 0 &&
-				uint64(chain.Supply)-chain.Issued < uint64(len(tkns)) {
-				// insufficient coinbase supply
-				rpcErr.Data = "insufficient coinbase supply"
-				return rpcErr
-			}
-			for tknID := range tkns {
-				tkn := state.NFToken{NFTokenID: tknID}
-				err := chain.GetNFToken(&tkn)
-				if err == nil {
-					rpcErr.Data = fmt.Sprintf(
-						"NFTokenID(%v) already exists", tknID)
-					return rpcErr
-				}
-				if err != gorm.ErrRecordNotFound {
-					log.Error(err)
-					panic(err)
-				}
-			}
-			break
-		}
-		if adr.Balance < uint64(len(tkns)) {
-			rpcErr.Data = fmt.Sprintf("insufficient balance: %v", rcdHash)
-			return rpcErr
-		}
-		for tknID := range tkns {
-			tkn := state.NFToken{NFTokenID: tknID, OwnerID: adr.ID}
-			err := chain.GetNFToken(&tkn)
-			if err == gorm.ErrRecordNotFound {
-				rpcErr.Data = fmt.Sprintf(
-					"NFTokenID(%v) is not owned by %v",
-					tknID, rcdHash)
-				return rpcErr
-			}
-			if err != nil {
-				log.Error(err)
-				panic(err)
-			}
-		}
-	}
-
-	return nil
-}
-
 func getDaemonTokens(data json.RawMessage) interface{} {
 	if _, err := validate(data, nil); err != nil {
 		return err
@@ -598,11 +699,39 @@ func getDaemonProperties(data json.RawMessage) interface{} {
 type ResultGetSyncStatus struct {
 	Sync    uint32 `json:"syncheight"`
 	Current uint32 `json:"factomheight"`
+
+	// Faulted lists the chains that failed to sync and are being retried.
+	Faulted []ResultFaultedChain `json:"faulted,omitempty"`
+}
+
+type ResultFaultedChain struct {
+	ChainID   *factom.Bytes32 `json:"chainid"`
+	Height    uint32          `json:"height"`
+	Error     string          `json:"error"`
+	Since     int64           `json:"since"`
+	Retries   uint            `json:"retries"`
+	NextRetry int64           `json:"nextretry"`
 }
 
 func getSyncStatus(data json.RawMessage) interface{} {
 	sync, current := engine.GetSyncStatus()
-	return ResultGetSyncStatus{Sync: sync, Current: current}
+	result := ResultGetSyncStatus{Sync: sync, Current: current}
+	for id, fault := range state.GetFaults() {
+		id := id
+		result.Faulted = append(result.Faulted, ResultFaultedChain{
+			ChainID:   &id,
+			Height:    state.Chains.Get(&id).Metadata.Height,
+			Error:     fault.Err,
+			Since:     fault.Since.Unix(),
+			Retries:   fault.Retries,
+			NextRetry: fault.NextRetry.Unix(),
+		})
+	}
+	sort.Slice(result.Faulted, func(i, j int) bool {
+		return result.Faulted[i].ChainID.String() <
+			result.Faulted[j].ChainID.String()
+	})
+	return result
 }
 
 func validate(data json.RawMessage, params Params) (*state.Chain, error) {
@@ -624,6 +753,9 @@ func validate(data json.RawMessage, params Params) (*state.Chain, error) {
 	chainID := params.ValidChainID()
 	if chainID != nil {
 		chain := state.Chains.Get(chainID)
+		if chain.IsFaulted() {
+			return nil, ErrorTokenFaulted
+		}
 		if !chain.IsIssued() {
 			return nil, ErrorTokenNotFound
 		}
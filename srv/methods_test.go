@@ -23,14 +23,8 @@
 package srv
 
 import (
-	"bytes"
 	"encoding/json"
-	"fmt"
-	"io/ioutil"
-	"math/rand"
-	"net/http"
 	"testing"
-	"time"
 
 	jrpc "github.com/AdamSLevy/jsonrpc2/v11"
 	"github.com/Factom-Asset-Tokens/fatd/factom"
@@ -39,265 +33,165 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-//// We make copies because the original is modified during the method call.
-//var tokenParamsRes = cpResponse(TokenParamsRes)
-//var tokenNotFoundRes = cpResponse(TokenNotFoundRes)
-//var transactionNotFoundRes = cpResponse(TransactionNotFoundRes)
-//var getTransactionParamsRes = cpResponse(GetTransactionParamsRes)
-//var getTransactionsParamsRes = cpResponse(GetTransactionsParamsRes)
-//var getBalanceParamsRes = cpResponse(GetBalanceParamsRes)
-var getBalanceValidRes = jrpc.NewResponse(float64(0))
-
 var tokenID = "invalid"
 
 type Test struct {
 	Params      interface{}
 	Description string
 	Result      interface{}
-	Error       interface{}
+	Error       *jrpc.Error
 }
 
 var getIssuanceTests = []Test{{
 	Description: "nil params",
-	Error:       TokenParamsError,
+	Error: jrpc.InvalidParams(
+		`required: either "chainid" or both "tokenid" and "issuerid"`),
 }, {
-	Params:      TokenParams{},
+	Params:      ParamsToken{},
 	Description: "empty params",
-	Error:       TokenParamsError,
+	Error: jrpc.InvalidParams(
+		`required: either "chainid" or both "tokenid" and "issuerid"`),
 }, {
 	Params: struct {
-		TokenParams
+		ParamsToken
 		NewField string
-	}{TokenParams: TokenParams{ChainID: factom.NewBytes32(nil)}, NewField: "hello"},
+	}{ParamsToken: ParamsToken{ChainID: factom.NewBytes32(nil)},
+		NewField: "hello"},
 	Description: "unknown field",
-	Error:       jrpc.NewInvalidParamsError(`json: unknown field "NewField"`),
+	Error:       jrpc.InvalidParams(`json: unknown field "NewField"`),
 }, {
-	Params:      TokenParams{ChainID: factom.NewBytes32(nil), TokenID: &tokenID},
+	Params: ParamsToken{ChainID: factom.NewBytes32(nil),
+		TokenID: tokenID},
 	Description: "chain id and token id",
-	Error:       TokenParamsError,
+	Error: jrpc.InvalidParams(
+		`cannot use "chainid" with "tokenid" or "issuerid"`),
 }, {
-	Params: TokenParams{ChainID: factom.NewBytes32(nil),
+	Params: ParamsToken{ChainID: factom.NewBytes32(nil),
 		IssuerChainID: factom.NewBytes32(nil)},
 	Description: "chain id and issuer chain id",
-	Error:       TokenParamsError,
+	Error: jrpc.InvalidParams(
+		`cannot use "chainid" with "tokenid" or "issuerid"`),
+}, {
+	Params:      ParamsToken{TokenID: tokenID},
+	Description: "token id",
+	Error:       jrpc.InvalidParams(`"issuerid" is required with "tokenid"`),
 }, {
-	Params: TokenParams{ChainID: factom.NewBytes32(nil),
-		IssuerChainID: factom.NewBytes32(nil), TokenID: &tokenID},
-	Description: "chain id and token id and issuer chain id",
-	Error:       TokenParamsError,
+	Params:      ParamsToken{IssuerChainID: factom.NewBytes32(nil)},
+	Description: "issuer chain id",
+	Error:       jrpc.InvalidParams(`"tokenid" is required with "issuerid"`),
 }, {
-	Params: TokenParams{IssuerChainID: factom.NewBytes32(nil),
-		TokenID: &tokenID},
+	Params: ParamsToken{IssuerChainID: factom.NewBytes32(nil),
+		TokenID: tokenID},
 	Description: "token id and issuer chain id",
-	Error:       TokenNotFoundError,
+	Error:       ErrorTokenNotFound,
 }, {
-	Params:      TokenParams{ChainID: factom.NewBytes32(nil)},
+	Params:      ParamsToken{ChainID: factom.NewBytes32(nil)},
 	Description: "chain id",
-	Error:       TokenNotFoundError,
-},
-}
+	Error:       ErrorTokenNotFound,
+}}
 
 var getTransactionTests = []Test{{
-	Params: TokenParams{ChainID: factom.NewBytes32(nil),
-		IssuerChainID: factom.NewBytes32(nil)},
+	Params:      ParamsToken{ChainID: factom.NewBytes32(nil)},
 	Description: "no hash",
-	Error:       GetTransactionParamsError,
+	Error:       jrpc.InvalidParams(`required: "entryhash"`),
 }, {
-	Params: GetTransactionParams{
-		TokenParams: TokenParams{ChainID: factom.NewBytes32(nil)},
+	Params: ParamsGetTransaction{
+		ParamsToken: ParamsToken{ChainID: factom.NewBytes32(nil)},
 		Hash:        factom.NewBytes32(nil)},
-	Description: "tx not found",
-	Error:       TransactionNotFoundError,
-},
-}
-
-var getTransactionsTests = []Test{{
-	Params: GetTransactionsParams{
-		TokenParams: TokenParams{ChainID: factom.NewBytes32(nil)},
-		Hash:        factom.NewBytes32(nil), Start: new(uint)},
-	Description: "hash and start",
-	Error:       GetTransactionsParamsError,
-}, {
-	Params: GetTransactionsParams{
-		TokenParams: TokenParams{ChainID: factom.NewBytes32(nil)},
-		Hash:        factom.NewBytes32(nil)},
-	Description: "tx not found, with hash",
-	Error:       TransactionNotFoundError,
-}, {
-	Params: GetTransactionsParams{
-		TokenParams: TokenParams{ChainID: factom.NewBytes32(nil)}, Limit: new(uint)},
-	Description: "zero limit",
-	Error:       GetTransactionsParamsError,
-}, {
-	Params: GetTransactionsParams{
-		TokenParams: TokenParams{ChainID: factom.NewBytes32(nil)}},
-	Description: "tx not found",
-	Error:       TransactionNotFoundError,
-},
-}
+	Description: "token not found",
+	Error:       ErrorTokenNotFound,
+}}
 
 var getBalanceTests = []Test{{
-	Params: GetBalanceParams{
-		TokenParams: TokenParams{ChainID: factom.NewBytes32(nil)}},
+	Params: ParamsGetBalance{
+		ParamsToken: ParamsToken{ChainID: factom.NewBytes32(nil)}},
 	Description: "no address",
-	Error:       GetBalanceParamsError,
+	Error:       jrpc.InvalidParams(`required: "address"`),
 }, {
-	Params: GetBalanceParams{
-		Address: &factom.Address{}},
+	Params:      ParamsGetBalance{Address: &factom.FAAddress{}},
 	Description: "no chain",
-	Error:       GetBalanceParamsError,
-}, {
-	Params: GetBalanceParams{TokenParams: TokenParams{ChainID: factom.NewBytes32(nil)},
-		Address: &factom.Address{}},
-	Description: "valid",
-	Result:      0,
-},
-}
-
-var getStatsTests = []Test{{
-	Description: "no params",
-	Error:       TokenParamsError,
-}, {
-	Params:      TokenParams{ChainID: factom.NewBytes32(nil)},
-	Description: "valid",
-	Result: struct {
-		Supply                   int `json:"supply"`
-		CirculatingSupply        int `json:"circulating-supply"`
-		Transactions             int `json:"transactions"`
-		IssuanceTimestamp        int `json:"issuance-timestamp"`
-		LastTransactionTimestamp int `json:"last-transaction-timestamp"`
-	}{},
+	Error: jrpc.InvalidParams(
+		`required: either "chainid" or both "tokenid" and "issuerid"`),
+}, {
+	Params: ParamsGetBalance{
+		ParamsToken: ParamsToken{ChainID: factom.NewBytes32(nil)},
+		Address:     &factom.FAAddress{}},
+	Description: "token not found",
+	Error:       ErrorTokenNotFound,
 }}
 
-var NFTokenID = "test"
-
 var getNFTokenTests = []Test{{
-	Params: GetNFTokenParams{
-		TokenParams: TokenParams{ChainID: factom.NewBytes32(nil)}},
-	Description: "no nf token param",
-	Error:       GetNFTokenParamsError,
-}, {
-	Params: GetNFTokenParams{NonFungibleTokenID: &NFTokenID,
-		TokenParams: TokenParams{ChainID: factom.NewBytes32(nil)}},
-	Description: "valid",
-	Error:       TokenNotFoundError,
+	Params: ParamsGetNFToken{
+		ParamsToken: ParamsToken{ChainID: factom.NewBytes32(nil)}},
+	Description: "no nf token id",
+	Error:       jrpc.InvalidParams(`required: "nftokenid"`),
 }}
 
 var sendTransactionTests = []Test{{
 	Description: "no params",
-	Error:       SendTransactionParamsError,
+	Error: jrpc.InvalidParams(
+		`required: either "chainid" or both "tokenid" and "issuerid"`),
 }, {
-	Params: SendTransactionParams{Content: factom.Bytes{0x00},
-		ExtIDs:      []factom.Bytes{{0x00}},
-		TokenParams: TokenParams{ChainID: factom.NewBytes32(nil)}},
-	Description: "invalid token",
-	Error:       TokenNotFoundError,
-}}
-
-var getDaemonTokensTests = []Test{{
-	Description: "no params",
-	Result: []struct {
-		TokenID  string          `json:"token-id"`
-		IssuerID *factom.Bytes32 `json:"issuer-id"`
-		ChainID  *factom.Bytes32 `json:"chain-id"`
-	}{{}},
+	Params: ParamsSendTransaction{
+		ParamsToken: ParamsToken{ChainID: factom.NewBytes32(nil)}},
+	Description: "no content",
+	Error:       jrpc.InvalidParams(`required: "content" and "extids"`),
 }, {
-	Params:      TokenParams{ChainID: factom.NewBytes32(nil)},
-	Description: "valid",
-	Error:       NoParamsError,
+	Params: ParamsSendTransaction{Content: factom.Bytes{0x00},
+		ExtIDs:      []factom.Bytes{{0x00}},
+		ParamsToken: ParamsToken{ChainID: factom.NewBytes32(nil)}},
+	Description: "token not found",
+	Error:       ErrorTokenNotFound,
 }}
 
 var getDaemonPropertiesTests = []Test{{
 	Params:      []int{0},
-	Description: "invalid params",
-	Error:       NoParamsError,
+	Description: "params",
+	Error:       jrpc.InvalidParams(`no "params" accepted`),
 }, {
 	Description: "no params",
-	Result: struct {
-		FatdVersion string `json:"fatd-version"`
-		APIVersion  string `json:"api-version"`
-	}{FatdVersion: "0.0.0", APIVersion: "v0"},
+	Result: ResultGetDaemonProperties{
+		FatdVersion: flag.Revision, APIVersion: APIVersion},
 }}
 
 var methodTests = map[string][]Test{
-	"get-issuance":          getIssuanceTests,
-	"get-transaction":       getTransactionTests,
-	"get-transactions":      getTransactionsTests,
-	"get-balance":           getBalanceTests,
-	"get-stats":             getStatsTests,
-	"get-nf-token":          getNFTokenTests,
-	"send-transaction":      sendTransactionTests,
-	"get-daemon-tokens":     getDaemonTokensTests,
-	"get-daemon-properties": getDaemonPropertiesTests,
+	"get-issuance":           getIssuanceTests,
+	"get-transaction":        getTransactionTests,
+	"get-transaction-status": getTransactionTests,
+	"get-balance":            getBalanceTests,
+	"get-nf-token":           getNFTokenTests,
+	"send-transaction":       sendTransactionTests,
+	"get-daemon-properties":  getDaemonPropertiesTests,
 }
 
 func TestMethods(t *testing.T) {
-	flag.APIAddress = "localhost:18888"
-	Start()
+	defer func(esAdr factom.EsAddress) { flag.EsAdr = esAdr }(flag.EsAdr)
+	flag.EsAdr = factom.EsAddress{}
+	assert.Equal(t, ErrorNoEC, jrpcMethods["send-transaction"](nil))
+	var err error
+	flag.EsAdr, err = factom.GenerateEsAddress()
+	require.NoError(t, err)
+
 	for method, tests := range methodTests {
+		method, tests := method, tests
 		t.Run(method, func(t *testing.T) {
 			for _, test := range tests {
+				test := test
 				t.Run(test.Description, func(t *testing.T) {
-					assert := assert.New(t)
-					require := require.New(t)
-					res, err := request(method,
-						test.Params, &json.RawMessage{})
-					assert.NoError(err)
-					assert.NotNil(res.ID)
-					if test.Result != nil {
-						data, err := json.Marshal(test.Result)
-						require.NoError(err)
-						result := res.Result.(*json.RawMessage)
-						require.NotEmpty(result)
-						assert.JSONEq(string(data), string(*result),
-							"Result")
-					} else {
-						require.NotNil(res.Error)
-						assert.Equal(test.Error, *res.Error, "Error")
+					var data json.RawMessage
+					if test.Params != nil {
+						var err error
+						data, err = json.Marshal(test.Params)
+						require.NoError(t, err)
+					}
+					res := jrpcMethods[method](data)
+					if test.Error != nil {
+						assert.Equal(t, test.Error, res)
+						return
 					}
+					assert.Equal(t, test.Result, res)
 				})
 			}
 		})
 	}
-	Stop()
-}
-
-func request(method string, params interface{}, result interface{}) (jrpc.Response, error) {
-	// Generate a random ID for this request.
-	id := rand.Uint32()%200 + 500
-
-	// Marshal the JSON RPC Request.
-	reqBytes, err := json.Marshal(jrpc.NewRequest(method, id, params))
-	if err != nil {
-		return jrpc.Response{}, err
-	}
-
-	// Make the HTTP request.
-	endpoint := "http://" + flag.APIAddress
-	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewBuffer(reqBytes))
-	if err != nil {
-		return jrpc.Response{}, err
-	}
-	req.Header.Add("Content-Type", "application/json")
-	c := http.Client{Timeout: 2 * time.Second}
-	res, err := c.Do(req)
-	if err != nil {
-		return jrpc.Response{}, err
-	}
-	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusBadRequest {
-		return jrpc.Response{}, fmt.Errorf("http: %v", res.Status)
-	}
-
-	// Read the HTTP response.
-	resBytes, err := ioutil.ReadAll(res.Body)
-	if err != nil {
-		return jrpc.Response{}, fmt.Errorf("ioutil.ReadAll(http.Response.Body): %v", err)
-	}
-
-	// Unmarshal the HTTP response into a JSON RPC response.
-	resJrpc := jrpc.NewResponse(result)
-	if err := json.Unmarshal(resBytes, &resJrpc); err != nil {
-		return jrpc.Response{}, fmt.Errorf("json.Unmarshal(): %v", err)
-	}
-	return resJrpc, nil
 }
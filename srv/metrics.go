@@ -0,0 +1,77 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package srv
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	jrpc "github.com/AdamSLevy/jsonrpc2/v11"
+	"github.com/Factom-Asset-Tokens/fatd/metrics"
+)
+
+var (
+	rpcRequestSeconds = metrics.NewHistogram("fatd_rpc_request_seconds",
+		"Latency of JSON-RPC requests by method.",
+		metrics.DefBuckets, "method")
+	rpcErrorsTotal = metrics.NewCounter("fatd_rpc_errors_total",
+		"Number of JSON-RPC requests that returned an error by method "+
+			"and error code.",
+		"method", "code")
+)
+
+// instrument returns a copy of methods with each MethodFunc wrapped to record
+// its latency and any returned error. Panics are recorded as an Internal
+// Error.
+func instrument(methods jrpc.MethodMap) jrpc.MethodMap {
+	instrumented := make(jrpc.MethodMap, len(methods))
+	for name, method := range methods {
+		instrumented[name] = instrumentMethod(name, method)
+	}
+	return instrumented
+}
+
+func instrumentMethod(name string, method jrpc.MethodFunc) jrpc.MethodFunc {
+	return func(params json.RawMessage) interface{} {
+		start := time.Now()
+		// The code is only cleared if the method does not panic.
+		var code jrpc.ErrorCode = jrpc.InternalErrorCode
+		defer func() {
+			rpcRequestSeconds.ObserveDuration(start, name)
+			if code != 0 {
+				rpcErrorsTotal.Inc(name, fmt.Sprint(int(code)))
+			}
+		}()
+		result := method(params)
+		switch err := result.(type) {
+		case *jrpc.Error:
+			code = err.Code
+		case jrpc.Error:
+			code = err.Code
+		default:
+			code = 0
+		}
+		return result
+	}
+}
@@ -23,6 +23,7 @@
 package srv
 
 import (
+	"fmt"
 	"strings"
 	"time"
 
@@ -30,6 +31,7 @@ import (
 	"github.com/Factom-Asset-Tokens/fatd/factom"
 	"github.com/Factom-Asset-Tokens/fatd/fat"
 	"github.com/Factom-Asset-Tokens/fatd/fat/fat1"
+	"github.com/Factom-Asset-Tokens/fatd/state"
 )
 
 type Params interface {
@@ -100,6 +102,34 @@ func (p *ParamsPagination) IsValid() error {
 	return nil
 }
 
+// ParamsHistory optionally scopes a balance query to a point in the past
+// using either a DBlock Height or a Unix Timestamp.
+type ParamsHistory struct {
+	Height    *uint32 `json:"height,omitempty"`
+	Timestamp *int64  `json:"timestamp,omitempty"`
+}
+
+func (p ParamsHistory) IsValid() error {
+	if p.Height != nil && p.Timestamp != nil {
+		return jrpc.InvalidParams(
+			`cannot use "height" with "timestamp"`)
+	}
+	return nil
+}
+
+// IsSet returns true if either the Height or the Timestamp is set.
+func (p ParamsHistory) IsSet() bool {
+	return p.Height != nil || p.Timestamp != nil
+}
+
+// AsOf returns the point in history selected by p.
+func (p ParamsHistory) AsOf() state.AsOf {
+	if p.Timestamp != nil {
+		return state.AsOf{Timestamp: time.Unix(*p.Timestamp, 0)}
+	}
+	return state.AsOf{Height: *p.Height}
+}
+
 // ParamsGetTransaction is used to query for a single particular transaction
 // with the given Entry Hash.
 type ParamsGetTransaction struct {
@@ -125,6 +155,10 @@ type ParamsGetTransactions struct {
 	Addresses []factom.FAAddress `json:"addresses,omitempty"`
 	StartHash *factom.Bytes32    `json:"entryhash,omitempty"`
 	ToFrom    string             `json:"tofrom,omitempty"`
+
+	// Inclusive range of DBlock heights.
+	StartHeight *uint32 `json:"startheight,omitempty"`
+	EndHeight   *uint32 `json:"endheight,omitempty"`
 }
 
 func (p *ParamsGetTransactions) IsValid() error {
@@ -148,6 +182,12 @@ func (p *ParamsGetTransactions) IsValid() error {
 		return jrpc.InvalidParams(
 			`"tofrom" value must be either "to" or "from"`)
 	}
+
+	if p.StartHeight != nil && p.EndHeight != nil &&
+		*p.StartHeight > *p.EndHeight {
+		return jrpc.InvalidParams(
+			`"startheight" may not be greater than "endheight"`)
+	}
 	return nil
 }
 
@@ -168,6 +208,7 @@ func (p ParamsGetNFToken) IsValid() error {
 
 type ParamsGetBalance struct {
 	ParamsToken
+	ParamsHistory
 	Address *factom.FAAddress `json:"address,omitempty"`
 }
 
@@ -175,6 +216,9 @@ func (p ParamsGetBalance) IsValid() error {
 	if err := p.ParamsToken.IsValid(); err != nil {
 		return err
 	}
+	if err := p.ParamsHistory.IsValid(); err != nil {
+		return err
+	}
 	if p.Address == nil {
 		return jrpc.InvalidParams(`required: "address"`)
 	}
@@ -182,10 +226,14 @@ func (p ParamsGetBalance) IsValid() error {
 }
 
 type ParamsGetBalances struct {
+	ParamsHistory
 	Address *factom.FAAddress `json:"address,omitempty"`
 }
 
 func (p ParamsGetBalances) IsValid() error {
+	if err := p.ParamsHistory.IsValid(); err != nil {
+		return err
+	}
 	if p.Address == nil {
 		return jrpc.InvalidParams(`required: "address"`)
 	}
@@ -198,6 +246,7 @@ func (p ParamsGetBalances) ValidChainID() *factom.Bytes32 {
 type ParamsGetNFBalance struct {
 	ParamsToken
 	ParamsPagination
+	ParamsHistory
 	Address *factom.FAAddress `json:"address,omitempty"`
 }
 
@@ -205,6 +254,9 @@ func (p *ParamsGetNFBalance) IsValid() error {
 	if err := p.ParamsToken.IsValid(); err != nil {
 		return err
 	}
+	if err := p.ParamsHistory.IsValid(); err != nil {
+		return err
+	}
 	if err := p.ParamsPagination.IsValid(); err != nil {
 		return err
 	}
@@ -253,3 +305,127 @@ func (p ParamsSendTransaction) Entry() factom.Entry {
 		ChainID:   p.ChainID,
 	}
 }
+
+// ParamsSubscribe is used to subscribe to events over a WebSocket connection.
+// Transactions subscriptions are scoped to a single token and may be further
+// limited to those that include any of the Addresses or that transfer the
+// NFTokenID.
+type ParamsSubscribe struct {
+	ParamsToken
+	Type      string             `json:"type"`
+	Addresses []factom.FAAddress `json:"addresses,omitempty"`
+	NFTokenID *fat1.NFTokenID    `json:"nftokenid,omitempty"`
+}
+
+func (p ParamsSubscribe) IsValid() error {
+	switch p.Type {
+	case SubscriptionTransactions:
+		return p.ParamsToken.IsValid()
+	case SubscriptionIssuances, SubscriptionSyncHeight:
+		if p.ChainID != nil || len(p.TokenID) > 0 ||
+			p.IssuerChainID != nil ||
+			len(p.Addresses) > 0 || p.NFTokenID != nil {
+			return jrpc.InvalidParams(fmt.Sprintf(
+				`%q subscriptions accept no other params`, p.Type))
+		}
+		return nil
+	}
+	return jrpc.InvalidParams(fmt.Sprintf(`"type" must be one of %q, %q or %q`,
+		SubscriptionTransactions, SubscriptionIssuances,
+		SubscriptionSyncHeight))
+}
+
+func (p ParamsSubscribe) ValidChainID() *factom.Bytes32 {
+	if p.Type != SubscriptionTransactions {
+		return nil
+	}
+	return p.ParamsToken.ValidChainID()
+}
+
+type ParamsUnsubscribe struct {
+	Subscription uint64 `json:"subscription"`
+}
+
+func (p ParamsUnsubscribe) IsValid() error {
+	if p.Subscription == 0 {
+		return jrpc.InvalidParams(`required: "subscription"`)
+	}
+	return nil
+}
+
+func (p ParamsUnsubscribe) ValidChainID() *factom.Bytes32 {
+	return nil
+}
+
+// ParamsTokens is a list of tokens, each identified by either its ChainID or
+// both its TokenID and IssuerChainID.
+type ParamsTokens []ParamsToken
+
+func (p ParamsTokens) IsValid() error {
+	for _, token := range p {
+		if err := token.IsValid(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ChainIDs returns the ChainIDs of all tokens in p, which must be valid.
+func (p ParamsTokens) ChainIDs() []factom.Bytes32 {
+	chainIDs := make([]factom.Bytes32, len(p))
+	for i, token := range p {
+		chainIDs[i] = *token.ValidChainID()
+	}
+	return chainIDs
+}
+
+// ParamsSetChainFilter replaces the whitelist and blacklist of tracked token
+// chains.
+type ParamsSetChainFilter struct {
+	Whitelist ParamsTokens `json:"whitelist"`
+	Blacklist ParamsTokens `json:"blacklist"`
+}
+
+func (p ParamsSetChainFilter) IsValid() error {
+	if err := p.Whitelist.IsValid(); err != nil {
+		return err
+	}
+	return p.Blacklist.IsValid()
+}
+
+func (p ParamsSetChainFilter) ValidChainID() *factom.Bytes32 {
+	return nil
+}
+
+// ParamsAdminChain identifies a single token chain for an admin method. Unlike
+// ParamsToken, the chain does not need to be issued or tracked.
+type ParamsAdminChain struct {
+	ParamsToken
+}
+
+func (p ParamsAdminChain) ValidChainID() *factom.Bytes32 {
+	return nil
+}
+
+// ParamsResyncChain rewinds a tracked token chain to Height and resyncs it.
+type ParamsResyncChain struct {
+	ParamsAdminChain
+	Height *uint32 `json:"height"`
+}
+
+func (p ParamsResyncChain) IsValid() error {
+	if err := p.ParamsAdminChain.IsValid(); err != nil {
+		return err
+	}
+	if p.Height == nil {
+		return jrpc.InvalidParams(`required: "height"`)
+	}
+	return nil
+}
+
+// ParamsValidateChain runs an integrity check on a tracked token chain and
+// optionally repairs it.
+type ParamsValidateChain struct {
+	ParamsAdminChain
+	Repair bool `json:"repair,omitempty"`
+}
@@ -23,12 +23,13 @@
 package srv
 
 import (
+	"crypto/tls"
 	"net/http"
 
 	jrpc "github.com/AdamSLevy/jsonrpc2/v11"
 	"github.com/Factom-Asset-Tokens/fatd/flag"
 	_log "github.com/Factom-Asset-Tokens/fatd/log"
-	"github.com/rs/cors"
+	"github.com/Factom-Asset-Tokens/fatd/metrics"
 )
 
 var (
@@ -49,26 +50,55 @@ func Start(stop <-chan struct{}) (done <-chan struct{}) {
 
 	// Set up JSON RPC 2.0 handler with correct headers.
 	jrpc.DebugMethodFunc = true
-	jrpcHandler := jrpc.HTTPRequestHandler(jrpcMethods)
+	jrpcHandler := jrpc.HTTPRequestHandler(instrument(jrpcMethods))
 	var handler http.HandlerFunc = func(w http.ResponseWriter, r *http.Request) {
 		header := w.Header()
 		header.Add(FatdVersionHeaderKey, flag.Revision)
 		header.Add(FatdAPIVersionHeaderKey, APIVersion)
 		jrpcHandler(w, r)
 	}
+	adminJRPCHandler := jrpc.HTTPRequestHandler(instrument(adminMethods))
+	admin := adminHandler(func(w http.ResponseWriter, r *http.Request) {
+		header := w.Header()
+		header.Add(FatdVersionHeaderKey, flag.Revision)
+		header.Add(FatdAPIVersionHeaderKey, APIVersion)
+		adminJRPCHandler(w, r)
+	})
 
-	// Set up server.
+	// Set up server. The admin API uses its own bearer token instead of
+	// the -apiuser or -apitoken.
+	ws := wsHandler(stop)
 	srvMux := http.NewServeMux()
-	srvMux.Handle("/", handler)
-	srvMux.Handle("/v1", handler)
-	cors := cors.New(cors.Options{AllowedOrigins: []string{"*"}})
-	srv = http.Server{Handler: cors.Handler(srvMux)}
+	srvMux.Handle("/", authHandler(handler))
+	srvMux.Handle("/v1", authHandler(handler))
+	srvMux.Handle("/ws", authHandler(ws))
+	srvMux.Handle("/v1/ws", authHandler(ws))
+	srvMux.Handle("/metrics", authHandler(metrics.Handler()))
+	srvMux.Handle("/admin", admin)
+	srvMux.Handle("/v1/admin", admin)
+	srv = http.Server{Handler: corsHandler(srvMux)}
 	srv.Addr = flag.APIAddress
 
-	// Start server.
 	_done := make(chan struct{})
+	if len(flag.APITLSCert) > 0 {
+		certs, err := newCertReloader(flag.APITLSCert, flag.APITLSKey)
+		if err != nil {
+			log.Errorf("TLS certificate: %v", err)
+			close(_done)
+			return _done
+		}
+		srv.TLSConfig = &tls.Config{GetCertificate: certs.GetCertificate}
+	}
+
+	// Start server.
 	go func() {
-		if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		var err error
+		if srv.TLSConfig != nil {
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != http.ErrServerClosed {
 			log.Errorf("srv.ListenAndServe(): %v", err)
 		}
 		close(_done)
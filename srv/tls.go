@@ -0,0 +1,94 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package srv
+
+import (
+	"crypto/tls"
+	"os"
+	"sync"
+	"time"
+)
+
+// certReloader serves the TLS certificate in certFile and keyFile, and loads
+// them again during the next TLS handshake after either file changes. If the
+// new files fail to load, the previous certificate continues to be served.
+type certReloader struct {
+	certFile, keyFile string
+
+	cert              *tls.Certificate
+	certMod, keyMod   time.Time
+	certSize, keySize int64
+	sync.Mutex
+}
+
+// newCertReloader returns a certReloader for certFile and keyFile, or an
+// error if they cannot be loaded.
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	if _, err := r.GetCertificate(nil); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate.
+func (r *certReloader) GetCertificate(
+	*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.Lock()
+	defer r.Unlock()
+	certInfo, err := os.Stat(r.certFile)
+	if err != nil {
+		return r.failed(err)
+	}
+	keyInfo, err := os.Stat(r.keyFile)
+	if err != nil {
+		return r.failed(err)
+	}
+	if r.certMod.Equal(certInfo.ModTime()) &&
+		r.keyMod.Equal(keyInfo.ModTime()) &&
+		r.certSize == certInfo.Size() && r.keySize == keyInfo.Size() {
+		return r.cert, nil
+	}
+	// Only attempt to load the files again after they change again.
+	r.certMod, r.keyMod = certInfo.ModTime(), keyInfo.ModTime()
+	r.certSize, r.keySize = certInfo.Size(), keyInfo.Size()
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return r.failed(err)
+	}
+	if r.cert != nil {
+		log.Infof("Reloaded TLS certificate %v", r.certFile)
+	}
+	r.cert = &cert
+	return r.cert, nil
+}
+
+// failed returns the previous certificate, if any, and otherwise err.
+func (r *certReloader) failed(err error) (*tls.Certificate, error) {
+	if r.cert == nil {
+		return nil, err
+	}
+	log.Errorf("TLS certificate: %v", err)
+	return r.cert, nil
+}
@@ -0,0 +1,109 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package srv
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_log "github.com/Factom-Asset-Tokens/fatd/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestCert writes a new self signed certificate for commonName and its
+// key to certFile and keyFile, with the given modification time.
+func writeTestCert(t *testing.T, certFile, keyFile, commonName string,
+	mod time.Time) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template,
+		&key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(certFile, pem.EncodeToMemory(
+		&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(
+		&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	require.NoError(t, os.Chtimes(certFile, mod, mod))
+	require.NoError(t, os.Chtimes(keyFile, mod, mod))
+}
+
+func TestCertReloader(t *testing.T) {
+	log = _log.New("srv")
+	dir, err := ioutil.TempDir("", "fatd-srv-tls-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	certFile := filepath.Join(dir, "fatd.pem")
+	keyFile := filepath.Join(dir, "fatd-key.pem")
+
+	_, err = newCertReloader(certFile, keyFile)
+	assert.Error(t, err, "missing files")
+
+	commonName := func(r *certReloader) string {
+		cert, err := r.GetCertificate(nil)
+		require.NoError(t, err)
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		require.NoError(t, err)
+		return leaf.Subject.CommonName
+	}
+
+	mod := time.Now().Add(-time.Hour)
+	writeTestCert(t, certFile, keyFile, "first", mod)
+	r, err := newCertReloader(certFile, keyFile)
+	require.NoError(t, err)
+	assert.Equal(t, "first", commonName(r))
+
+	mod = mod.Add(time.Minute)
+	writeTestCert(t, certFile, keyFile, "second", mod)
+	assert.Equal(t, "second", commonName(r))
+
+	// Invalid or missing files keep the previous certificate.
+	mod = mod.Add(time.Minute)
+	require.NoError(t, ioutil.WriteFile(keyFile, []byte("invalid"), 0600))
+	require.NoError(t, os.Chtimes(keyFile, mod, mod))
+	assert.Equal(t, "second", commonName(r))
+	require.NoError(t, os.Remove(certFile))
+	assert.Equal(t, "second", commonName(r))
+
+	mod = mod.Add(time.Minute)
+	writeTestCert(t, certFile, keyFile, "third", mod)
+	assert.Equal(t, "third", commonName(r))
+}
@@ -0,0 +1,331 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package srv
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	jrpc "github.com/AdamSLevy/jsonrpc2/v11"
+	"github.com/gorilla/websocket"
+
+	"github.com/Factom-Asset-Tokens/fatd/events"
+	"github.com/Factom-Asset-Tokens/fatd/factom"
+	"github.com/Factom-Asset-Tokens/fatd/fat/fat1"
+)
+
+// Subscription types.
+const (
+	SubscriptionTransactions = "transactions"
+	SubscriptionIssuances    = "issuances"
+	SubscriptionSyncHeight   = "sync-height"
+)
+
+const (
+	wsWriteTimeout = 10 * time.Second
+	wsPongTimeout  = 60 * time.Second
+	wsPingInterval = wsPongTimeout * 9 / 10
+	wsMaxMessage   = 10240
+)
+
+var wsUpgrader = websocket.Upgrader{
+	// Allow the same origins as the JSON-RPC CORS policy.
+	CheckOrigin: wsCheckOrigin,
+}
+
+// wsRequest is a JSON-RPC 2.0 request received over a WebSocket.
+type wsRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	ID      interface{}     `json:"id"`
+}
+
+type ResultSubscribe struct {
+	Subscription uint64 `json:"subscription"`
+}
+
+// ResultSubscription is the params of a "subscription" notification.
+type ResultSubscription struct {
+	Subscription uint64      `json:"subscription"`
+	Type         string      `json:"type"`
+	Result       interface{} `json:"result"`
+}
+
+// subscription is a ParamsSubscribe with the token chain resolved and the
+// filters indexed.
+type subscription struct {
+	Type      string
+	ChainID   *factom.Bytes32
+	Addresses map[factom.FAAddress]struct{}
+	NFTokenID *fat1.NFTokenID
+}
+
+func (sub subscription) matches(evt interface{}) bool {
+	switch evt := evt.(type) {
+	case events.Transaction:
+		if sub.Type != SubscriptionTransactions ||
+			*sub.ChainID != *evt.ChainID {
+			return false
+		}
+		if len(sub.Addresses) > 0 {
+			var found bool
+			for _, adr := range evt.Addresses {
+				if _, found = sub.Addresses[adr]; found {
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		if sub.NFTokenID != nil {
+			if _, ok := evt.NFTokens[*sub.NFTokenID]; !ok {
+				return false
+			}
+		}
+		return true
+	case events.Issuance:
+		return sub.Type == SubscriptionIssuances
+	case events.SyncHeight:
+		return sub.Type == SubscriptionSyncHeight
+	}
+	return false
+}
+
+// wsConn is a WebSocket connection along with its subscriptions.
+type wsConn struct {
+	conn *websocket.Conn
+
+	// responses to requests, which are written along with notifications
+	// by the writing goroutine.
+	responses chan jrpc.Response
+	// closed is closed when the writing goroutine exits.
+	closed chan struct{}
+
+	subs   map[uint64]subscription
+	nextID uint64
+	sync.Mutex
+}
+
+func wsHandler(stop <-chan struct{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		conn, err := wsUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			// Upgrade already replied with an HTTP error.
+			log.Debugf("wsUpgrader.Upgrade(): %v", err)
+			return
+		}
+		ws := wsConn{conn: conn,
+			responses: make(chan jrpc.Response),
+			closed:    make(chan struct{}),
+			subs:      make(map[uint64]subscription)}
+		done := make(chan struct{})
+		go ws.read(done)
+		ws.write(stop, done)
+	}
+}
+
+// read handles requests until the connection is closed, and then closes done.
+func (ws *wsConn) read(done chan struct{}) {
+	defer close(done)
+	ws.conn.SetReadLimit(wsMaxMessage)
+	ws.conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	ws.conn.SetPongHandler(func(string) error {
+		return ws.conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	})
+	for {
+		_, data, err := ws.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err,
+				websocket.CloseGoingAway,
+				websocket.CloseNormalClosure) {
+				log.Debugf("ws.conn.ReadMessage(): %v", err)
+			}
+			return
+		}
+		res := ws.handle(data)
+		select {
+		case ws.responses <- res:
+		case <-ws.closed:
+			return
+		}
+	}
+}
+
+func (ws *wsConn) handle(data []byte) jrpc.Response {
+	var req wsRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return jrpc.Response{Error: jrpc.NewError(-32700, "Parse error",
+			err.Error())}
+	}
+	res := jrpc.Response{ID: req.ID}
+	if req.JSONRPC != jrpc.Version {
+		res.Error = jrpc.NewError(-32600, "Invalid Request",
+			`"jsonrpc" must be "2.0"`)
+		return res
+	}
+	var result interface{}
+	switch req.Method {
+	case "subscribe":
+		result = ws.subscribe(req.Params)
+	case "unsubscribe":
+		result = ws.unsubscribe(req.Params)
+	default:
+		res.Error = jrpc.NewError(-32601, "Method not found", nil)
+		return res
+	}
+	if err, ok := result.(*jrpc.Error); ok {
+		res.Error = err
+		return res
+	}
+	res.Result = result
+	return res
+}
+
+func (ws *wsConn) subscribe(data json.RawMessage) interface{} {
+	params := ParamsSubscribe{}
+	chain, err := validate(data, &params)
+	if err != nil {
+		return err
+	}
+	sub := subscription{Type: params.Type, NFTokenID: params.NFTokenID}
+	if chain != nil {
+		sub.ChainID = chain.ID
+		if params.NFTokenID != nil && chain.Type != fat1.Type {
+			err := ErrorTokenNotFound
+			err.Data = "Token Chain is not FAT-1"
+			return err
+		}
+	}
+	if len(params.Addresses) > 0 {
+		sub.Addresses = make(map[factom.FAAddress]struct{},
+			len(params.Addresses))
+		for _, adr := range params.Addresses {
+			sub.Addresses[adr] = struct{}{}
+		}
+	}
+
+	ws.Lock()
+	defer ws.Unlock()
+	ws.nextID++
+	ws.subs[ws.nextID] = sub
+	return ResultSubscribe{Subscription: ws.nextID}
+}
+
+func (ws *wsConn) unsubscribe(data json.RawMessage) interface{} {
+	params := ParamsUnsubscribe{}
+	if _, err := validate(data, &params); err != nil {
+		return err
+	}
+	ws.Lock()
+	defer ws.Unlock()
+	if _, ok := ws.subs[params.Subscription]; !ok {
+		return jrpc.InvalidParams("no such subscription")
+	}
+	delete(ws.subs, params.Subscription)
+	return true
+}
+
+// write sends responses and notifications for all matching events until done
+// or stop is closed, or an error occurs, and then closes the connection.
+func (ws *wsConn) write(stop <-chan struct{}, done <-chan struct{}) {
+	defer close(ws.closed)
+	evts := events.Subscribe()
+	defer evts.Cancel()
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+	defer ws.conn.Close()
+	for {
+		var err error
+		select {
+		case res := <-ws.responses:
+			err = ws.writeJSON(res)
+		case evt, ok := <-evts.C:
+			if !ok {
+				// This client has fallen too far behind to
+				// catch up.
+				ws.close(websocket.ClosePolicyViolation,
+					"too many queued events")
+				return
+			}
+			err = ws.notify(evt)
+		case <-ping.C:
+			ws.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			err = ws.conn.WriteMessage(websocket.PingMessage, nil)
+		case <-stop:
+			ws.close(websocket.CloseGoingAway, "server shutting down")
+			return
+		case <-done:
+			return
+		}
+		if err != nil {
+			log.Debugf("ws.write(): %v", err)
+			return
+		}
+	}
+}
+
+func (ws *wsConn) notify(evt interface{}) error {
+	var typ string
+	switch evt.(type) {
+	case events.Transaction:
+		typ = SubscriptionTransactions
+	case events.Issuance:
+		typ = SubscriptionIssuances
+	case events.SyncHeight:
+		typ = SubscriptionSyncHeight
+	}
+	ws.Lock()
+	var ids []uint64
+	for id, sub := range ws.subs {
+		if sub.matches(evt) {
+			ids = append(ids, id)
+		}
+	}
+	ws.Unlock()
+	for _, id := range ids {
+		if err := ws.writeJSON(jrpc.NewRequest("subscription", nil,
+			ResultSubscription{
+				Subscription: id,
+				Type:         typ,
+				Result:       evt,
+			})); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ws *wsConn) writeJSON(v interface{}) error {
+	ws.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	return ws.conn.WriteJSON(v)
+}
+
+func (ws *wsConn) close(code int, text string) {
+	ws.conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(code, text),
+		time.Now().Add(wsWriteTimeout))
+}
@@ -0,0 +1,151 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package state
+
+import (
+	"fmt"
+	"os"
+
+	jrpc "github.com/AdamSLevy/jsonrpc2/v11"
+
+	"github.com/Factom-Asset-Tokens/fatd/factom"
+	"github.com/Factom-Asset-Tokens/fatd/flag"
+)
+
+// Backfill syncs the chain with the given id up to and including height, which
+// should be the current sync height, by walking back through its EBlocks from
+// its chain head, instead of scanning every DBlock. If the chain is not
+// tracked, it is loaded from its database, if one exists, or else it is
+// tracked from its first EBlock. Only the EBlocks after the saved height of the
+// chain are processed. Backfill does nothing if the chain does not exist yet.
+// Backfill must not be called concurrently with Process.
+func Backfill(id factom.Bytes32, height uint32) error {
+	chain := Chains.Get(&id)
+	if chain.IsFaulted() {
+		// Faulted chains are synced by RetryFaulted.
+		return nil
+	}
+	if !chain.IsTracked() {
+		chain = Chain{ID: &id}
+		fname := fmt.Sprintf("%v%v", chain.ID, dbFileExtension)
+		if _, err := os.Stat(flag.DBPath + "/" + fname); err == nil {
+			chain.ChainStatus = ChainStatusTracked
+			if err := chain.load(fname); err != nil {
+				return err
+			}
+			Chains.set(chain.ID, &chain)
+		}
+	}
+	if chain.Metadata.Height >= height {
+		return nil
+	}
+
+	log.Infof("ChainID(%v): backfilling from height %v to %v...",
+		chain.ID, chain.Metadata.Height, height)
+	ebs, err := getEBlocks(&id, chain.Metadata.Height, height)
+	if err != nil {
+		if _, ok := err.(jrpc.Error); ok {
+			// The chain does not exist yet, so it will be found
+			// by Process once it is created.
+			return nil
+		}
+		return err
+	}
+	for _, eb := range ebs {
+		if err := setTimestamp(&eb); err != nil {
+			return err
+		}
+		if err := chain.Process(eb); err != nil {
+			return err
+		}
+		if chain.IsIgnored() {
+			log.Infof("ChainID(%v): not a valid token chain", id)
+			return nil
+		}
+	}
+	if !chain.IsTracked() {
+		return nil
+	}
+	// Save the height so that the chain does not need to be synced from
+	// its last EBlock the next time it is loaded.
+	if err := chain.saveHeight(height); err != nil {
+		return err
+	}
+	Chains.set(chain.ID, &chain)
+	log.Infof("ChainID(%v): backfilled", id)
+	return nil
+}
+
+// getEBlocks returns the EBlocks of the chain with the given id with heights
+// after start up to and including end, in order from earliest to latest. The
+// EBlocks are found by walking back from the chain head, so only the EBlocks
+// after start, and any after end, are downloaded.
+func getEBlocks(id *factom.Bytes32, start, end uint32) ([]factom.EBlock, error) {
+	var ebs []factom.EBlock
+	eb := factom.EBlock{ChainID: id}
+	for {
+		if err := eb.Get(c); err != nil {
+			return nil, fmt.Errorf("%#v.Get(c): %v", eb, err)
+		}
+		if eb.Height <= start {
+			break
+		}
+		if eb.Height <= end {
+			ebs = append(ebs, eb)
+		}
+		if eb.IsFirst() {
+			break
+		}
+		eb = eb.Prev()
+	}
+	// Reverse the order so that the earliest EBlock is first.
+	for i, j := 0, len(ebs)-1; i < j; i, j = i+1, j-1 {
+		ebs[i], ebs[j] = ebs[j], ebs[i]
+	}
+	return ebs, nil
+}
+
+// setTimestamp sets the Timestamp of eb and its Entries from the DBlock at the
+// eb.Height. The Timestamps of EBlocks are otherwise only set by DBlock.Get. If
+// -verifysync is set, eb is also verified against the DBlock.
+func setTimestamp(eb *factom.EBlock) error {
+	var dblock factom.DBlock
+	dblock.Header.Height = eb.Height
+	if err := dblock.Get(c); err != nil {
+		return fmt.Errorf("%#v.Get(c): %v", dblock, err)
+	}
+	if flag.VerifySync {
+		if err := eb.VerifyDBlock(dblock); err != nil {
+			return err
+		}
+	}
+	ts := dblock.Header.Timestamp
+	for i := range eb.Entries {
+		e := &eb.Entries[i]
+		// The Entry Timestamps are offset from eb.Timestamp by their
+		// minute.
+		e.Timestamp = ts.Add(e.Timestamp.Sub(eb.Timestamp))
+	}
+	eb.Timestamp = ts
+	return nil
+}
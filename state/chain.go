@@ -58,6 +58,7 @@ func (chain *Chain) track(first factom.Entry) error {
 	chain.Metadata.Token = string(first.ExtIDs[1])
 	chain.Metadata.Issuer = chain.Identity.ChainID
 	chain.Metadata.Height = first.Height
+	chain.Metadata.NetworkID = NetworkID
 
 	if err := chain.setupDB(); err != nil {
 		return err
@@ -65,11 +66,12 @@ func (chain *Chain) track(first factom.Entry) error {
 	log.Debugf("Tracked: %v", chain)
 	return nil
 }
-func (chain *Chain) issue(issuance fat.Issuance) error {
+func (chain *Chain) issue(issuance fat.Issuance,
+	eb *factom.EBlock, index int) error {
 	chain.ChainStatus = ChainStatusIssued
 	chain.Issuance = issuance
 
-	if err := chain.saveIssuance(); err != nil {
+	if err := chain.saveIssuance(eb, index); err != nil {
 		return err
 	}
 	log.Debugf("Issued: %v", chain)
@@ -23,6 +23,7 @@
 package state
 
 import (
+	"sort"
 	"sync"
 
 	"github.com/Factom-Asset-Tokens/fatd/factom"
@@ -30,9 +31,9 @@ import (
 
 var (
 	Chains = ChainMap{m: map[factom.Bytes32]Chain{
-		factom.Bytes32{31: 0x0a}: Chain{ChainStatus: ChainStatusIgnored},
-		factom.Bytes32{31: 0x0c}: Chain{ChainStatus: ChainStatusIgnored},
-		factom.Bytes32{31: 0x0f}: Chain{ChainStatus: ChainStatusIgnored},
+		factom.AdminBlockChainID:       Chain{ChainStatus: ChainStatusIgnored},
+		factom.EntryCreditBlockChainID: Chain{ChainStatus: ChainStatusIgnored},
+		factom.FactoidBlockChainID:     Chain{ChainStatus: ChainStatusIgnored},
 	}, RWMutex: &sync.RWMutex{}}
 )
 
@@ -45,10 +46,19 @@ type ChainMap struct {
 func (cm *ChainMap) set(id *factom.Bytes32, chain *Chain) {
 	defer cm.Unlock()
 	cm.Lock()
+	prev, ok := cm.m[*id]
 	if chain.IsIssued() {
-		if chain, ok := cm.m[*id]; !ok || !chain.IsIssued() {
+		if !ok || !prev.IsIssued() {
 			cm.ids = append(cm.ids, *id)
 		}
+	} else if prev.IsIssued() {
+		// The chain may no longer be issued if it is faulted.
+		for i := range cm.ids {
+			if cm.ids[i] == *id {
+				cm.ids = append(cm.ids[:i:i], cm.ids[i+1:]...)
+				break
+			}
+		}
 	}
 	cm.m[*id] = *chain
 }
@@ -68,3 +78,19 @@ func (cm ChainMap) GetIssued() []factom.Bytes32 {
 	cm.RLock()
 	return cm.ids
 }
+
+// GetTracked returns all tracked and faulted chains in order of ChainID.
+func (cm ChainMap) GetTracked() []Chain {
+	defer cm.RUnlock()
+	cm.RLock()
+	var chains []Chain
+	for _, chain := range cm.m {
+		if chain.IsTracked() || chain.IsFaulted() {
+			chains = append(chains, chain)
+		}
+	}
+	sort.Slice(chains, func(i, j int) bool {
+		return chains[i].ID.String() < chains[j].ID.String()
+	})
+	return chains
+}
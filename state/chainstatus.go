@@ -29,6 +29,7 @@ const (
 	ChainStatusTracked ChainStatus = 1
 	ChainStatusIssued  ChainStatus = 3
 	ChainStatusIgnored ChainStatus = 4
+	ChainStatusFaulted ChainStatus = 8
 )
 
 func (status ChainStatus) IsUnknown() bool {
@@ -37,6 +38,9 @@ func (status ChainStatus) IsUnknown() bool {
 func (status ChainStatus) IsIgnored() bool {
 	return status == ChainStatusIgnored
 }
+func (status ChainStatus) IsFaulted() bool {
+	return status == ChainStatusFaulted
+}
 func (status ChainStatus) IsTracked() bool {
 	return status&ChainStatusTracked == ChainStatusTracked
 }
@@ -53,6 +57,8 @@ func (status ChainStatus) String() string {
 		s = "Issued"
 	case ChainStatusIgnored:
 		s = "Ignored"
+	case ChainStatusFaulted:
+		s = "Faulted"
 	}
 	return s
 }
@@ -34,23 +34,27 @@ const (
 	trackedID
 	issuedID
 	ignoredID
+	faultedID
 )
 
 var chainStatusTests = []struct {
 	ChainStatus
-	expected [4]bool
+	expected [5]bool
 }{{
 	ChainStatus: ChainStatusUnknown,
-	expected:    [4]bool{unknownID: true},
+	expected:    [5]bool{unknownID: true},
 }, {
 	ChainStatus: ChainStatusTracked,
-	expected:    [4]bool{trackedID: true},
+	expected:    [5]bool{trackedID: true},
 }, {
 	ChainStatus: ChainStatusIssued,
-	expected:    [4]bool{trackedID: true, issuedID: true},
+	expected:    [5]bool{trackedID: true, issuedID: true},
 }, {
 	ChainStatus: ChainStatusIgnored,
-	expected:    [4]bool{ignoredID: true},
+	expected:    [5]bool{ignoredID: true},
+}, {
+	ChainStatus: ChainStatusFaulted,
+	expected:    [5]bool{faultedID: true},
 }}
 
 func TestChainStatus(t *testing.T) {
@@ -67,6 +71,8 @@ func TestChainStatus(t *testing.T) {
 				"IsIssued()")
 			assert.Equalf(expected[ignoredID], status.IsIgnored(),
 				"IsIgnored()")
+			assert.Equalf(expected[faultedID], status.IsFaulted(),
+				"IsFaulted()")
 		})
 	}
 }
@@ -44,8 +44,11 @@ import (
 
 var (
 	SavedHeight uint32
-	log         _log.Log
-	c           = flag.FactomClient
+	// NetworkID is the Factom network being synced. It must be set prior
+	// to calling Load.
+	NetworkID factom.NetworkID
+	log       _log.Log
+	c         = flag.FactomClient
 )
 
 // Load state from all existing databases
@@ -57,6 +60,8 @@ func Load() error {
 		return fmt.Errorf("os.Mkdir(%#v)", flag.DBPath)
 	}
 
+	SetChainFilter(flag.Whitelist, flag.Blacklist)
+
 	minHeight := uint32(math.MaxUint32)
 
 	// Scan through all files within the database directory. Ignore invalid
@@ -72,16 +77,16 @@ func Load() error {
 		if chain.ID = fnameToChainID(fname); chain.ID == nil {
 			continue
 		}
-		log.Debugf("loading chain: %v", chain.ID)
-		var err error
-		if err = chain.open(fname); err != nil {
-			return err
-		}
-		if err := chain.loadMetadata(); err != nil {
-			return err
+		if !IsAllowed(chain.ID) {
+			log.Debugf("skipping chain: %v", chain.ID)
+			continue
 		}
-		if err := chain.loadIssuance(); err != nil {
-			return err
+		log.Debugf("loading chain: %v", chain.ID)
+		if err := chain.load(fname); err != nil {
+			// Other chains can still be synced, so the chain is
+			// retried later by RetryFaulted.
+			chain.fault(err)
+			continue
 		}
 
 		Chains.set(chain.ID, &chain)
@@ -98,6 +103,64 @@ func Load() error {
 	}
 	return nil
 }
+
+// load the chain from the existing database file fname.
+func (chain *Chain) load(fname string) (err error) {
+	if err = chain.open(fname); err != nil {
+		return err
+	}
+	// Ensure the db gets closed if there are any issues.
+	defer func() {
+		if err != nil {
+			chain.Close()
+			chain.DB = nil
+		}
+	}()
+	if err := chain.loadMetadata(); err != nil {
+		return err
+	}
+	if err := chain.checkNetworkID(); err != nil {
+		return err
+	}
+	// Entries must be validated before the Issuance is loaded. Any
+	// mismatches are logged before anything is repaired.
+	var mismatches []string
+	var backedUp bool
+	if flag.ValidateDB {
+		if mismatches, err = chain.checkEntries(
+			flag.RepairDB, &backedUp); err != nil {
+			return err
+		}
+	}
+	if err := chain.loadIssuance(); err != nil {
+		return err
+	}
+	if flag.ValidateDB && chain.IsIssued() {
+		stateMismatches, err := chain.checkState(
+			flag.RepairDB, &backedUp)
+		if err != nil {
+			return err
+		}
+		mismatches = append(mismatches, stateMismatches...)
+	}
+	if len(mismatches) > 0 && !flag.RepairDB {
+		for _, mismatch := range mismatches {
+			log.Warnf("ChainID(%v): %v", chain.ID, mismatch)
+		}
+		return fmt.Errorf("ChainID(%v): failed validation "+
+			"with %v mismatches, use -repairdb to repair",
+			chain.ID, len(mismatches))
+	}
+	if !chain.IsIssued() {
+		return nil
+	}
+	// Any entries saved after the chain's height are from an EBlock that
+	// was only partially processed before fatd stopped. They are removed
+	// so that the whole EBlock is processed again, and the
+	// TransactionHooks are called for each of its valid transactions.
+	return chain.rewindPartial()
+}
+
 func fnameToChainID(fname string) *factom.Bytes32 {
 	if len(fname) != dbFileNameLen ||
 		fname[dbFileNameLen-len(dbFileExtension):dbFileNameLen] != dbFileExtension {
@@ -125,9 +188,8 @@ func Close() {
 }
 
 func SaveHeight(height uint32) error {
+	failed := make(map[factom.Bytes32]error)
 	Chains.Lock()
-	defer Chains.Unlock()
-
 	for _, chain := range Chains.m {
 		if !chain.IsTracked() ||
 			chain.Metadata.Height >= height ||
@@ -135,10 +197,18 @@ func SaveHeight(height uint32) error {
 			continue
 		}
 		if err := chain.saveHeight(height); err != nil {
-			return err
+			failed[*chain.ID] = err
+			continue
 		}
 		Chains.m[*chain.ID] = chain
 	}
+	Chains.Unlock()
+
+	// Chains are faulted after Chains is unlocked, since fault updates
+	// Chains.
+	for id, err := range failed {
+		FaultChain(id, fmt.Errorf("saveHeight(%v): %v", height, err))
+	}
 	SavedHeight = height
 	return nil
 }
@@ -151,7 +221,11 @@ const (
 
 // open a database
 func (c *Chain) open(fname string) error {
-	fpath := flag.DBPath + "/" + fname
+	return c.openPath(flag.DBPath + "/" + fname)
+}
+
+// openPath opens the database at fpath.
+func (c *Chain) openPath(fpath string) error {
 	db, err := gorm.Open(dbDriver, fpath)
 	if err != nil {
 		return err
@@ -163,7 +237,7 @@ func (c *Chain) open(fname string) error {
 		}
 	}()
 	db.LogMode(false)
-	if err = autoMigrate(db); err != nil {
+	if err = migrate(db); err != nil {
 		return err
 	}
 	c.DB = db
@@ -173,25 +247,6 @@ func (c *Chain) open(fname string) error {
 	}
 	return nil
 }
-func autoMigrate(db *gorm.DB) error {
-	if err := deleteEmptyTables(db); err != nil {
-		return fmt.Errorf("deleteEmptyTables(): %v", err)
-	}
-	if err := db.AutoMigrate(&entry{}).Error; err != nil {
-		return fmt.Errorf("db.AutoMigrate(&Entry{}): %v", err)
-	}
-	if err := db.AutoMigrate(&Address{}).Error; err != nil {
-		return fmt.Errorf("db.AutoMigrate(&Address{}): %v", err)
-	}
-	if err := db.AutoMigrate(&Metadata{}).Error; err != nil {
-		return fmt.Errorf("db.AutoMigrate(&Metadata{}): %v", err)
-	}
-	if err := db.AutoMigrate(&NFToken{}).Error; err != nil {
-		return fmt.Errorf("db.AutoMigrate(&Metadata{}): %v", err)
-	}
-	return nil
-}
-
 func deleteEmptyTables(db *gorm.DB) error {
 	var tables []struct{ Name string }
 	var selectQry = "SELECT name FROM sqlite_master "
@@ -235,8 +290,13 @@ func deleteEmptyTables(db *gorm.DB) error {
 // setupDB a database for a given token chain.
 func (chain *Chain) setupDB() error {
 	fname := fmt.Sprintf("%v%v", chain.ID, dbFileExtension)
+	return chain.setupDBPath(flag.DBPath + "/" + fname)
+}
+
+// setupDBPath creates the database for a new chain at fpath.
+func (chain *Chain) setupDBPath(fpath string) error {
 	var err error
-	if err = chain.open(fname); err != nil {
+	if err = chain.openPath(fpath); err != nil {
 		return err
 	}
 	// Ensure the db gets closed if there are any issues.
@@ -276,6 +336,22 @@ func (chain *Chain) loadMetadata() error {
 	return nil
 }
 
+// checkNetworkID returns an error if the chain was synced from a network other
+// than NetworkID. Databases for which the network is unknown are assumed to be
+// for NetworkID, which is then recorded.
+func (chain *Chain) checkNetworkID() error {
+	switch chain.Metadata.NetworkID {
+	case NetworkID:
+		return nil
+	case factom.NetworkID{}:
+		chain.Metadata.NetworkID = NetworkID
+		return chain.saveMetadata()
+	}
+	return fmt.Errorf("ChainID(%v): database is for network %v "+
+		"but factomd is on network %v",
+		chain.ID, chain.Metadata.NetworkID, NetworkID)
+}
+
 func (chain *Chain) loadIssuance() error {
 	e := entry{}
 	if err := chain.First(&e).Error; err != nil {
@@ -298,7 +374,7 @@ func (chain *Chain) loadIssuance() error {
 	return nil
 }
 
-func (chain *Chain) saveIssuance() error {
+func (chain *Chain) saveIssuance(eb *factom.EBlock, index int) error {
 	var entriesTableCount int
 	if err := chain.DB.Model(&entry{}).Count(&entriesTableCount).Error; err != nil {
 		return err
@@ -307,7 +383,8 @@ func (chain *Chain) saveIssuance() error {
 		return fmt.Errorf(`table "entries" must be empty prior to issuance`)
 	}
 
-	if _, err := chain.createEntry(chain.Issuance.Entry.Entry); err != nil {
+	if _, err := chain.createEntry(chain.Issuance.Entry.Entry,
+		eb, index); err != nil {
 		return err
 	}
 	chain.ChainStatus = ChainStatusIssued
@@ -319,8 +396,9 @@ func (chain *Chain) saveMetadata() error {
 	}
 	return nil
 }
-func (chain *Chain) createEntry(fe factom.Entry) (*entry, error) {
-	e := newEntry(fe)
+func (chain *Chain) createEntry(fe factom.Entry,
+	eb *factom.EBlock, index int) (*entry, error) {
+	e := newEntry(fe, eb, index)
 	if !e.IsValid() {
 		return nil, fmt.Errorf("invalid hash: factom.Entry%+v", fe)
 	}
@@ -334,6 +412,12 @@ func (chain *Chain) createEntry(fe factom.Entry) (*entry, error) {
 	return &e, nil
 }
 
+func (chain *Chain) saveInvalidEntry(fe factom.Entry,
+	eb *factom.EBlock, index int, rej rejection) error {
+	e := newInvalidEntry(fe, eb, index, rej)
+	return chain.Create(&e).Error
+}
+
 func (chain *Chain) createNFToken(tknID fat1.NFTokenID,
 	metadata json.RawMessage) (*NFToken, error) {
 	tkn := NFToken{NFTokenID: tknID, Metadata: metadata}
@@ -432,12 +516,12 @@ func (chain *Chain) rollbackUnlessCommitted(savedChain Chain, err *error) {
 	chain.Issued = savedChain.Issued
 }
 
-func (chain Chain) GetEntry(hash *factom.Bytes32) (factom.Entry, error) {
+func (chain Chain) GetEntry(hash *factom.Bytes32) (SavedEntry, error) {
 	e, err := chain.getEntry(hash)
 	if e == nil {
-		return factom.Entry{}, err
+		return SavedEntry{}, err
 	}
-	return e.Entry(), nil
+	return e.SavedEntry(), nil
 }
 
 func (chain Chain) getEntry(hash *factom.Bytes32) (*entry, error) {
@@ -450,12 +534,24 @@ func (chain Chain) getEntry(hash *factom.Bytes32) (*entry, error) {
 	return &e, nil
 }
 
+// GetInvalidEntries returns every saved rejection of the entry with the given
+// hash, most recent first.
+func (chain Chain) GetInvalidEntries(hash *factom.Bytes32) ([]InvalidEntry, error) {
+	var es []InvalidEntry
+	if err := chain.Where("hash = ?", hash).Order("id DESC").
+		Find(&es).Error; err != nil {
+		return nil, err
+	}
+	return es, nil
+}
+
 const LimitMax = 1000
 
 func (chain Chain) GetEntries(hash *factom.Bytes32,
 	rcdHashes []factom.FAAddress, tknID *fat1.NFTokenID,
+	startHeight, endHeight *uint32,
 	toFrom, order string,
-	page, limit uint64) ([]factom.Entry, error) {
+	page, limit uint64) ([]SavedEntry, error) {
 	if limit == 0 || limit > LimitMax {
 		limit = LimitMax
 	}
@@ -502,6 +598,13 @@ func (chain Chain) GetEntries(hash *factom.Bytes32,
 		stmt.Where("id IN ?", entryIDs)
 	}
 
+	if startHeight != nil {
+		stmt.Where("height >= ?", *startHeight)
+	}
+	if endHeight != nil {
+		stmt.Where("height <= ?", *endHeight)
+	}
+
 	if tknID != nil {
 		tokenIDStmt := dbr.Select("id").From("nf_tokens").
 			Where("nf_token_id == ?", tknID)
@@ -515,9 +618,9 @@ func (chain Chain) GetEntries(hash *factom.Bytes32,
 	if _, err := stmt.Load(&es); err != nil {
 		return nil, err
 	}
-	entries := make([]factom.Entry, len(es))
+	entries := make([]SavedEntry, len(es))
 	for i, e := range es {
-		entries[i] = e.Entry()
+		entries[i] = e.SavedEntry()
 	}
 	return entries, nil
 }
@@ -0,0 +1,89 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package state
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetEntries(t *testing.T) {
+	defer setupTestDBPath(t)()
+	chain, _ := newTestFAT0Chain(t)
+	defer chain.Close()
+
+	uint32p := func(x uint32) *uint32 { return &x }
+	for _, test := range []struct {
+		Name                   string
+		StartHeight, EndHeight *uint32
+		Heights                []uint32
+	}{{
+		Name:    "all",
+		Heights: []uint32{10, 20},
+	}, {
+		Name:        "start",
+		StartHeight: uint32p(11),
+		Heights:     []uint32{20},
+	}, {
+		Name:      "end",
+		EndHeight: uint32p(19),
+		Heights:   []uint32{10},
+	}, {
+		Name:        "range",
+		StartHeight: uint32p(20),
+		EndHeight:   uint32p(20),
+		Heights:     []uint32{20},
+	}, {
+		Name:        "empty range",
+		StartHeight: uint32p(11),
+		EndHeight:   uint32p(19),
+	}} {
+		test := test
+		t.Run(test.Name, func(t *testing.T) {
+			es, err := chain.GetEntries(nil, nil, nil,
+				test.StartHeight, test.EndHeight, "", "", 0, 0)
+			require.NoError(t, err)
+			heights := make([]uint32, len(es))
+			for i, e := range es {
+				heights[i] = e.Height
+			}
+			assert.ElementsMatch(t, test.Heights, heights)
+		})
+	}
+
+	es, err := chain.GetEntries(nil, nil, nil, nil, nil, "", "", 0, 0)
+	require.NoError(t, err)
+	require.Len(t, es, 2)
+	e, err := chain.GetEntry(es[1].Hash)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(20), e.Height)
+	require.NotNil(t, e.EBlockKeyMR)
+	assert.Equal(t, byte(2), e.EBlockKeyMR[0])
+	assert.Equal(t, uint32(1), e.EBlockSequence)
+	assert.Equal(t, uint32(0), e.EntryIndex)
+
+	// The coinbase transaction follows the issuance in its EBlock.
+	assert.Equal(t, uint32(1), es[0].EntryIndex)
+}
@@ -0,0 +1,229 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package state
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Factom-Asset-Tokens/fatd/factom"
+	"github.com/Factom-Asset-Tokens/fatd/fat"
+	"github.com/Factom-Asset-Tokens/fatd/flag"
+)
+
+const (
+	// faultRetryMin is the delay before a faulted chain is first retried.
+	faultRetryMin = 10 * time.Second
+	// faultRetryMax is the maximum delay between retries, which doubles
+	// after each failed retry.
+	faultRetryMax = time.Hour
+)
+
+// Fault records the error that caused a chain to be faulted and when syncing
+// the chain will next be retried.
+type Fault struct {
+	Err       string
+	Since     time.Time
+	Retries   uint
+	NextRetry time.Time
+}
+
+// faults holds the Fault of every faulted chain. Faults are kept separately
+// from Chains so that faulted chains can be retried without scanning all
+// Chains, and so that a Fault survives a failed retry.
+var faults = struct {
+	m map[factom.Bytes32]Fault
+	sync.RWMutex
+}{m: make(map[factom.Bytes32]Fault)}
+
+// fault stops syncing the chain and schedules a retry with exponential
+// backoff. The database is closed, but the ID and Metadata are kept.
+func (chain *Chain) fault(err error) {
+	log.Errorf("ChainID(%v): faulted: %v", chain.ID, err)
+	if chain.DB != nil {
+		chain.Close()
+	}
+	id := *chain.ID
+	*chain = Chain{ID: &id, ChainStatus: ChainStatusFaulted,
+		Metadata: chain.Metadata}
+	Chains.set(chain.ID, chain)
+
+	faults.Lock()
+	defer faults.Unlock()
+	now := time.Now()
+	fault, ok := faults.m[id]
+	if ok {
+		fault.Retries++
+	} else {
+		fault.Since = now
+	}
+	fault.Err = err.Error()
+	delay := faultRetryMax
+	if fault.Retries < 32 && faultRetryMin<<fault.Retries < faultRetryMax {
+		delay = faultRetryMin << fault.Retries
+	}
+	fault.NextRetry = now.Add(delay)
+	faults.m[id] = fault
+}
+
+// FaultChain faults the chain with the given id, because err occurred while
+// syncing it outside of Process, such as during Backfill. FaultChain must not
+// be called concurrently with Process.
+func FaultChain(id factom.Bytes32, err error) {
+	chain := Chains.Get(&id)
+	chain.ID = &id
+	chain.fault(err)
+}
+
+func unfault(id factom.Bytes32) {
+	faults.Lock()
+	defer faults.Unlock()
+	delete(faults.m, id)
+}
+
+// GetFaults returns the Fault of every faulted chain.
+func GetFaults() map[factom.Bytes32]Fault {
+	faults.RLock()
+	defer faults.RUnlock()
+	m := make(map[factom.Bytes32]Fault, len(faults.m))
+	for id, fault := range faults.m {
+		m[id] = fault
+	}
+	return m
+}
+
+// RetryFaulted attempts to recover all faulted chains that are due to be
+// retried, and syncs them up to height. Chains that fail again are retried
+// later with a longer delay. RetryFaulted must not be called concurrently with
+// Process.
+func RetryFaulted(height uint32) {
+	now := time.Now()
+	for id, fault := range GetFaults() {
+		id := id
+		if now.Before(fault.NextRetry) {
+			continue
+		}
+		chain := Chains.Get(&id)
+		if !chain.IsFaulted() {
+			// The chain was untracked by the chain filter.
+			unfault(id)
+			continue
+		}
+		log.Infof("ChainID(%v): retrying (%v)...", id, fault.Retries+1)
+		if err := chain.recover(height); err != nil {
+			FaultChain(id, err)
+			continue
+		}
+		unfault(id)
+		log.Infof("ChainID(%v): recovered", id)
+	}
+}
+
+// recover reloads the faulted chain from its database, removes anything saved
+// from a partially processed EBlock, and then syncs it up to height using
+// Backfill. Chains that were not yet issued are synced again from their first
+// EBlock.
+func (chain *Chain) recover(height uint32) error {
+	if err := chain.reopen(); err != nil {
+		return err
+	}
+	return Backfill(*chain.ID, height)
+}
+
+// reopen replaces the faulted chain in Chains with the chain loaded from its
+// database, after any partially processed EBlock has been rewound by load. If
+// the chain was not yet issued, its database is removed and the chain is
+// replaced by an untracked chain, so that Backfill syncs it again from its
+// first EBlock.
+func (chain *Chain) reopen() error {
+	fresh := Chain{ID: chain.ID}
+	fname := fmt.Sprintf("%v%v", chain.ID, dbFileExtension)
+	fpath := flag.DBPath + "/" + fname
+	if _, err := os.Stat(fpath); err == nil {
+		fresh.ChainStatus = ChainStatusTracked
+		if err := fresh.load(fname); err != nil {
+			return err
+		}
+		if !fresh.IsIssued() {
+			// Only the chain's height has been saved, so it is
+			// simplest to sync it again from its first EBlock.
+			if err := fresh.Close(); err != nil {
+				return err
+			}
+			if err := os.Remove(fpath); err != nil {
+				return err
+			}
+			fresh = Chain{ID: chain.ID}
+		}
+	}
+	// Backfill only loads chains that are not already tracked, so the
+	// faulted chain must be replaced even if its database was removed.
+	Chains.set(chain.ID, &fresh)
+	return nil
+}
+
+// reopenFaulted reopens the faulted chain with the given id so that an admin
+// request can rewind or validate it, clears its Fault, and returns the
+// reopened chain. The caller must then sync the chain using Backfill, which
+// faults it again if it fails.
+func reopenFaulted(id factom.Bytes32) (Chain, error) {
+	chain := Chains.Get(&id)
+	if err := chain.reopen(); err != nil {
+		return Chain{}, err
+	}
+	unfault(id)
+	log.Infof("ChainID(%v): reopened", id)
+	return Chains.Get(&id), nil
+}
+
+// rewindPartial removes any entries saved after the chain's height, which are
+// from an EBlock that was only partially processed before the chain faulted or
+// fatd stopped. If the Issuance is removed, the chain is no longer issued.
+func (chain *Chain) rewindPartial() error {
+	var count int
+	if err := chain.DB.Model(&entry{}).
+		Where("height > ?", chain.Metadata.Height).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	var invalid int
+	if err := chain.DB.Model(&InvalidEntry{}).
+		Where("height > ?", chain.Metadata.Height).
+		Count(&invalid).Error; err != nil {
+		return err
+	}
+	if count+invalid == 0 {
+		return nil
+	}
+	if err := chain.rewind(chain.Metadata.Height); err != nil {
+		return err
+	}
+	if chain.IsIssued() && chain.Issuance.Height > chain.Metadata.Height {
+		// The Issuance itself was removed.
+		chain.Issuance = fat.Issuance{}
+		chain.ChainStatus = ChainStatusTracked
+	}
+	return nil
+}
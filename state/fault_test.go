@@ -0,0 +1,140 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package state
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Factom-Asset-Tokens/fatd/factom"
+)
+
+func TestFault(t *testing.T) {
+	defer setupTestDBPath(t)()
+	assert := assert.New(t)
+	require := require.New(t)
+	chain, _ := newTestFAT0Chain(t)
+	require.NoError(chain.saveHeight(15))
+	Chains.set(chain.ID, chain)
+	id := *chain.ID
+	defer func() {
+		Chains.Lock()
+		if chain := Chains.m[id]; chain.DB != nil {
+			chain.Close()
+		}
+		delete(Chains.m, id)
+		Chains.ids = nil
+		Chains.Unlock()
+		unfault(id)
+	}()
+
+	start := time.Now()
+	FaultChain(id, fmt.Errorf("failed"))
+	faulted := Chains.Get(&id)
+	assert.True(faulted.IsFaulted())
+	assert.Equal(uint32(15), faulted.Metadata.Height)
+	assert.NotContains(Chains.GetIssued(), id)
+	fault := GetFaults()[id]
+	assert.Equal("failed", fault.Err)
+	assert.Equal(uint(0), fault.Retries)
+	assert.False(fault.NextRetry.Before(start.Add(faultRetryMin)))
+
+	// Faulted chains are not processed or backfilled.
+	assert.NoError(Process(factom.EBlock{ChainID: &id, Height: 30}))
+	assert.NoError(Backfill(id, 30))
+	assert.True(Chains.Get(&id).IsFaulted())
+
+	// Retries are delayed with exponential backoff.
+	FaultChain(id, fmt.Errorf("failed again"))
+	fault = GetFaults()[id]
+	assert.Equal("failed again", fault.Err)
+	assert.Equal(uint(1), fault.Retries)
+	assert.False(fault.NextRetry.Before(start.Add(2 * faultRetryMin)))
+
+	// Chains are not retried until they are due.
+	RetryFaulted(15)
+	assert.Equal(uint(1), GetFaults()[id].Retries)
+
+	// A chain that fails to recover is faulted again. The test chain's
+	// issuer is not a valid identity chain ID, so its database fails to
+	// load, like a corrupted database.
+	faults.Lock()
+	fault.NextRetry = time.Now()
+	faults.m[id] = fault
+	faults.Unlock()
+	RetryFaulted(15)
+	assert.True(Chains.Get(&id).IsFaulted())
+	fault = GetFaults()[id]
+	assert.Equal(uint(2), fault.Retries)
+	assert.Contains(fault.Err, `corrupted "metadata" table`)
+
+	// Rewinding or validating a faulted chain reopens it like a retry, so
+	// it fails in the same way and the chain stays faulted.
+	err := Rewind(id, 10)
+	require.Error(err)
+	assert.Contains(err.Error(), `corrupted "metadata" table`)
+	_, err = ValidateChain(id, false)
+	require.Error(err)
+	assert.Contains(err.Error(), `corrupted "metadata" table`)
+	assert.True(Chains.Get(&id).IsFaulted())
+	assert.Equal(uint(2), GetFaults()[id].Retries)
+}
+
+func TestRewindPartial(t *testing.T) {
+	defer setupTestDBPath(t)()
+	assert := assert.New(t)
+	require := require.New(t)
+	chain, adrs := newTestFAT0Chain(t)
+	defer chain.Close()
+
+	// Nothing is removed if the height was saved.
+	require.NoError(chain.saveHeight(20))
+	require.NoError(chain.rewindPartial())
+	var count int
+	require.NoError(chain.DB.Model(&entry{}).Count(&count).Error)
+	assert.Equal(3, count)
+
+	// Simulate a failure while processing the EBlock at height 20, after
+	// its transaction was committed but before the height was saved.
+	require.NoError(chain.saveHeight(15))
+	require.NoError(chain.rewindPartial())
+	require.NoError(chain.DB.Model(&entry{}).Count(&count).Error)
+	assert.Equal(2, count)
+	for i, balance := range []uint64{100, 0} {
+		rcdHash := adrs[i].FAAddress()
+		adr, err := chain.GetAddress(&rcdHash)
+		require.NoError(err)
+		assert.Equal(balance, adr.Balance)
+	}
+
+	// If the Issuance is removed, the chain is no longer issued.
+	require.NoError(chain.saveHeight(5))
+	require.NoError(chain.rewindPartial())
+	assert.False(chain.IsIssued())
+	require.NoError(chain.DB.Model(&entry{}).Count(&count).Error)
+	assert.Equal(0, count)
+}
@@ -0,0 +1,157 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package state
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/Factom-Asset-Tokens/fatd/factom"
+)
+
+// chainFilter restricts which token chains are tracked. If the whitelist is
+// not empty, only whitelisted chains are tracked. Blacklisted chains are never
+// tracked.
+var chainFilter = struct {
+	whitelist, blacklist map[factom.Bytes32]struct{}
+	sync.RWMutex
+}{}
+
+// SetChainFilter replaces the whitelist and blacklist of token chains. Call
+// ApplyChainFilter for the change to take effect for chains that are already
+// tracked, or that must be backfilled.
+func SetChainFilter(whitelist, blacklist []factom.Bytes32) {
+	chainFilter.Lock()
+	defer chainFilter.Unlock()
+	chainFilter.whitelist = newChainIDSet(whitelist)
+	chainFilter.blacklist = newChainIDSet(blacklist)
+}
+
+func newChainIDSet(ids []factom.Bytes32) map[factom.Bytes32]struct{} {
+	set := make(map[factom.Bytes32]struct{}, len(ids))
+	for _, id := range ids {
+		set[id] = struct{}{}
+	}
+	return set
+}
+
+// AllowChain removes the chain with the given id from the blacklist and, if
+// the whitelist is not empty, adds it to the whitelist.
+func AllowChain(id factom.Bytes32) {
+	chainFilter.Lock()
+	defer chainFilter.Unlock()
+	delete(chainFilter.blacklist, id)
+	if len(chainFilter.whitelist) > 0 {
+		chainFilter.whitelist[id] = struct{}{}
+	}
+}
+
+// DisallowChain adds the chain with the given id to the blacklist. It is left
+// in the whitelist, if present, since removing the last whitelisted chain
+// would allow all chains.
+func DisallowChain(id factom.Bytes32) {
+	chainFilter.Lock()
+	defer chainFilter.Unlock()
+	if chainFilter.blacklist == nil {
+		chainFilter.blacklist = make(map[factom.Bytes32]struct{})
+	}
+	chainFilter.blacklist[id] = struct{}{}
+}
+
+// GetChainFilter returns the current whitelist and blacklist of token chains
+// in sorted order.
+func GetChainFilter() (whitelist, blacklist []factom.Bytes32) {
+	chainFilter.RLock()
+	defer chainFilter.RUnlock()
+	return chainIDSetSlice(chainFilter.whitelist),
+		chainIDSetSlice(chainFilter.blacklist)
+}
+
+func chainIDSetSlice(set map[factom.Bytes32]struct{}) []factom.Bytes32 {
+	ids := make([]factom.Bytes32, 0, len(set))
+	for id := range set {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i].String() < ids[j].String()
+	})
+	return ids
+}
+
+// IsAllowed returns true if the chain filter allows the chain with the given
+// id to be tracked.
+func IsAllowed(id *factom.Bytes32) bool {
+	chainFilter.RLock()
+	defer chainFilter.RUnlock()
+	if _, ok := chainFilter.blacklist[*id]; ok {
+		return false
+	}
+	if len(chainFilter.whitelist) == 0 {
+		return true
+	}
+	_, ok := chainFilter.whitelist[*id]
+	return ok
+}
+
+// ApplyChainFilter stops tracking all chains that are not allowed by the chain
+// filter and returns the IDs of the whitelisted chains that are not tracked,
+// which should be backfilled. The databases of chains that are no longer
+// tracked are closed but not deleted. ApplyChainFilter must not be called
+// concurrently with Process.
+func ApplyChainFilter() []factom.Bytes32 {
+	Chains.Lock()
+	for id, chain := range Chains.m {
+		id := id
+		if (!chain.IsTracked() && !chain.IsFaulted()) ||
+			IsAllowed(&id) {
+			continue
+		}
+		if chain.DB != nil {
+			if err := chain.Close(); err != nil {
+				log.Errorf("ChainID(%v): %v", id, err)
+			}
+		}
+		unfault(id)
+		Chains.m[id] = Chain{ChainStatus: ChainStatusIgnored}
+		for i := range Chains.ids {
+			if Chains.ids[i] == id {
+				Chains.ids = append(Chains.ids[:i:i],
+					Chains.ids[i+1:]...)
+				break
+			}
+		}
+		log.Infof("ChainID(%v): no longer tracked", id)
+	}
+	Chains.Unlock()
+
+	whitelist, _ := GetChainFilter()
+	var untracked []factom.Bytes32
+	for _, id := range whitelist {
+		id := id
+		if chain := Chains.Get(&id); !chain.IsTracked() &&
+			!chain.IsFaulted() && IsAllowed(&id) {
+			untracked = append(untracked, id)
+		}
+	}
+	return untracked
+}
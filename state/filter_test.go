@@ -0,0 +1,98 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package state
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Factom-Asset-Tokens/fatd/factom"
+)
+
+func TestChainFilter(t *testing.T) {
+	defer SetChainFilter(nil, nil)
+	assert := assert.New(t)
+	a, b, c := factom.Bytes32{1}, factom.Bytes32{2}, factom.Bytes32{3}
+
+	SetChainFilter(nil, nil)
+	assert.True(IsAllowed(&a))
+
+	SetChainFilter(nil, []factom.Bytes32{a})
+	assert.False(IsAllowed(&a))
+	assert.True(IsAllowed(&b))
+
+	SetChainFilter([]factom.Bytes32{c, b, a}, []factom.Bytes32{a})
+	assert.False(IsAllowed(&a))
+	assert.True(IsAllowed(&b))
+	assert.True(IsAllowed(&c))
+	assert.False(IsAllowed(&factom.Bytes32{4}))
+
+	whitelist, blacklist := GetChainFilter()
+	assert.Equal([]factom.Bytes32{a, b, c}, whitelist)
+	assert.Equal([]factom.Bytes32{a}, blacklist)
+
+	AllowChain(a)
+	assert.True(IsAllowed(&a))
+	DisallowChain(b)
+	assert.False(IsAllowed(&b))
+
+	SetChainFilter(nil, nil)
+	AllowChain(a)
+	DisallowChain(b)
+	whitelist, blacklist = GetChainFilter()
+	assert.Empty(whitelist)
+	assert.Equal([]factom.Bytes32{b}, blacklist)
+	AllowChain(b)
+	assert.True(IsAllowed(&b))
+}
+
+func TestApplyChainFilter(t *testing.T) {
+	defer setupTestDBPath(t)()
+	defer SetChainFilter(nil, nil)
+	assert := assert.New(t)
+	require := require.New(t)
+	chain, _ := newTestFAT0Chain(t)
+	Chains.set(chain.ID, chain)
+	defer func() {
+		Chains.Lock()
+		delete(Chains.m, *chain.ID)
+		Chains.ids = nil
+		Chains.Unlock()
+	}()
+	require.Contains(Chains.GetIssued(), *chain.ID)
+
+	other := factom.Bytes32{1}
+	SetChainFilter([]factom.Bytes32{*chain.ID, other}, nil)
+	assert.Equal([]factom.Bytes32{other}, ApplyChainFilter())
+	assert.True(Chains.Get(chain.ID).IsIssued())
+
+	SetChainFilter([]factom.Bytes32{other}, nil)
+	assert.Equal([]factom.Bytes32{other}, ApplyChainFilter())
+	assert.True(Chains.Get(chain.ID).IsIgnored())
+	assert.NotContains(Chains.GetIssued(), *chain.ID)
+
+	// Ignored chains are not processed.
+	assert.NoError(Process(factom.EBlock{ChainID: chain.ID, Height: 30}))
+}
@@ -0,0 +1,158 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package state
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jinzhu/gorm"
+
+	"github.com/Factom-Asset-Tokens/fatd/factom"
+	"github.com/Factom-Asset-Tokens/fatd/fat/fat1"
+)
+
+// ErrHistoryNotAvailable is returned when a historical query is made for a
+// height or time prior to when the chain's history started being recorded.
+var ErrHistoryNotAvailable = fmt.Errorf("history not available")
+
+// AsOf selects a point in the history of a chain. If Timestamp is not zero,
+// the state as of Timestamp is selected. Otherwise the state as of the end of
+// the DBlock at Height is selected.
+type AsOf struct {
+	Height    uint32
+	Timestamp time.Time
+}
+
+// where returns the query condition for selecting rows of BalanceChange or
+// NFTokenOwnerChange that occurred at or before asOf.
+func (chain Chain) where(asOf AsOf) (string, interface{}, error) {
+	if !asOf.Timestamp.IsZero() {
+		if asOf.Timestamp.Before(chain.HistoryTimestamp) {
+			return "", nil, ErrHistoryNotAvailable
+		}
+		return "timestamp <= ?", asOf.Timestamp.UTC(), nil
+	}
+	if asOf.Height < chain.HistoryHeight {
+		return "", nil, ErrHistoryNotAvailable
+	}
+	return "height <= ?", asOf.Height, nil
+}
+
+// GetBalanceAsOf returns the balance of rcdHash at the point in history
+// selected by asOf.
+func (chain Chain) GetBalanceAsOf(rcdHash *factom.FAAddress,
+	asOf AsOf) (uint64, error) {
+	qry, arg, err := chain.where(asOf)
+	if err != nil {
+		return 0, err
+	}
+	adr := Address{}
+	if err := chain.Where("rcd_hash = ?", rcdHash).
+		First(&adr).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return 0, nil
+		}
+		return 0, err
+	}
+	var balance int64
+	if err := chain.DB.Model(&BalanceChange{}).
+		Select("COALESCE(SUM(delta), 0)").
+		Where("address_id = ?", adr.ID).Where(qry, arg).
+		Row().Scan(&balance); err != nil {
+		return 0, err
+	}
+	return uint64(balance), nil
+}
+
+// GetNFTokensForOwnerAsOf returns the NFTokenIDs owned by rcdHash at the point
+// in history selected by asOf.
+func (chain Chain) GetNFTokensForOwnerAsOf(rcdHash *factom.FAAddress,
+	asOf AsOf, page, limit uint64, order string) (fat1.NFTokens, error) {
+	qry, arg, err := chain.where(asOf)
+	if err != nil {
+		return nil, err
+	}
+	switch order {
+	case "", "asc":
+		order = "asc"
+	case "desc":
+	default:
+		panic(fmt.Sprintf("invalid order value: %#v", order))
+	}
+	adr := Address{}
+	if err := chain.Where("rcd_hash = ?", rcdHash).
+		First(&adr).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return make(fat1.NFTokens), nil
+		}
+		return nil, err
+	}
+
+	// Select the tokens whose most recent owner change as of asOf was to
+	// adr. The timestamp comparison must be done by gorm, and not dbr, so
+	// that the time is formatted the same way as it is saved.
+	latest := chain.DB.Model(&NFTokenOwnerChange{}).Select("MAX(id)").
+		Where(qry, arg).Group("nf_token_id").QueryExpr()
+	stmt := chain.Where("owner_id = ?", adr.ID).
+		Where("id IN (?)", latest).
+		Order("nf_token_id " + order).
+		Limit(limit)
+	if page > 0 {
+		stmt = stmt.Offset((page - 1) * limit)
+	}
+	var changes []NFTokenOwnerChange
+	if err := stmt.Find(&changes).Error; err != nil {
+		return nil, err
+	}
+	tkns := make(fat1.NFTokens, len(changes))
+	for _, change := range changes {
+		tkns[change.NFTokenID] = struct{}{}
+	}
+	return tkns, nil
+}
+
+// saveBalanceChange records the change in the balance of adr due to e.
+func (chain *Chain) saveBalanceChange(adr Address, e *entry, delta int64) error {
+	change := BalanceChange{
+		AddressID: adr.ID,
+		EntryID:   e.ID,
+		Height:    e.Height,
+		Timestamp: e.Timestamp.UTC(),
+		Delta:     delta,
+	}
+	return chain.Create(&change).Error
+}
+
+// saveNFTokenOwnerChange records the transfer of tknID to owner due to e.
+func (chain *Chain) saveNFTokenOwnerChange(tknID fat1.NFTokenID,
+	owner Address, e *entry) error {
+	change := NFTokenOwnerChange{
+		NFTokenID: tknID,
+		OwnerID:   owner.ID,
+		EntryID:   e.ID,
+		Height:    e.Height,
+		Timestamp: e.Timestamp.UTC(),
+	}
+	return chain.Create(&change).Error
+}
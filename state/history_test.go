@@ -0,0 +1,134 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package state
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Factom-Asset-Tokens/fatd/fat/fat1"
+)
+
+func TestGetBalanceAsOf(t *testing.T) {
+	defer setupTestDBPath(t)()
+	chain, adrs := newTestFAT0Chain(t)
+	defer chain.Close()
+
+	var cbTs, txTs time.Time
+	require.NoError(t, chain.DB.Model(&entry{}).Where("id = 2").
+		Select("timestamp").Row().Scan(&cbTs))
+	require.NoError(t, chain.DB.Model(&entry{}).Where("id = 3").
+		Select("timestamp").Row().Scan(&txTs))
+
+	for _, test := range []struct {
+		Name     string
+		AsOf     AsOf
+		Balances [2]uint64
+	}{{
+		Name: "before coinbase",
+		AsOf: AsOf{Height: 9},
+	}, {
+		Name:     "coinbase",
+		AsOf:     AsOf{Height: 10},
+		Balances: [2]uint64{100, 0},
+	}, {
+		Name:     "before tx",
+		AsOf:     AsOf{Height: 19},
+		Balances: [2]uint64{100, 0},
+	}, {
+		Name:     "tx",
+		AsOf:     AsOf{Height: 20},
+		Balances: [2]uint64{60, 40},
+	}, {
+		Name:     "after tx",
+		AsOf:     AsOf{Height: 1000},
+		Balances: [2]uint64{60, 40},
+	}, {
+		Name: "timestamp before coinbase",
+		AsOf: AsOf{Timestamp: cbTs.Add(-time.Second)},
+	}, {
+		Name:     "timestamp coinbase",
+		AsOf:     AsOf{Timestamp: cbTs},
+		Balances: [2]uint64{100, 0},
+	}, {
+		Name:     "timestamp tx",
+		AsOf:     AsOf{Timestamp: txTs},
+		Balances: [2]uint64{60, 40},
+	}} {
+		test := test
+		t.Run(test.Name, func(t *testing.T) {
+			for i, adr := range adrs {
+				rcdHash := adr.FAAddress()
+				balance, err := chain.GetBalanceAsOf(&rcdHash,
+					test.AsOf)
+				require.NoError(t, err)
+				assert.Equal(t, test.Balances[i], balance)
+			}
+		})
+	}
+
+	// FAT-0 chains have no NF token history.
+	rcdHash := adrs[0].FAAddress()
+	tkns, err := chain.GetNFTokensForOwnerAsOf(&rcdHash, AsOf{Height: 20},
+		1, 10, "")
+	require.NoError(t, err)
+	assert.Empty(t, tkns)
+	tkns, err = chain.GetNFTokensForOwnerAsOf(&rcdHash, AsOf{Timestamp: txTs},
+		1, 10, "desc")
+	require.NoError(t, err)
+	assert.Empty(t, tkns)
+
+	// Save some NF token history directly.
+	var owners [2]Address
+	for i, adr := range adrs {
+		rcdHash := adr.FAAddress()
+		owners[i], err = chain.GetAddress(&rcdHash)
+		require.NoError(t, err)
+	}
+	for _, change := range []NFTokenOwnerChange{
+		{NFTokenID: 1, OwnerID: owners[0].ID, Height: 10, Timestamp: cbTs},
+		{NFTokenID: 2, OwnerID: owners[0].ID, Height: 10, Timestamp: cbTs},
+		{NFTokenID: 1, OwnerID: owners[1].ID, Height: 20, Timestamp: txTs},
+	} {
+		require.NoError(t, chain.Create(&change).Error)
+	}
+	tkns, err = chain.GetNFTokensForOwnerAsOf(&rcdHash, AsOf{Height: 10},
+		1, 10, "")
+	require.NoError(t, err)
+	assert.Equal(t, fat1.NFTokens{1: {}, 2: {}}, tkns)
+	tkns, err = chain.GetNFTokensForOwnerAsOf(&rcdHash, AsOf{Timestamp: txTs},
+		1, 10, "")
+	require.NoError(t, err)
+	assert.Equal(t, fat1.NFTokens{2: {}}, tkns)
+
+	chain.HistoryHeight = 15
+	chain.HistoryTimestamp = txTs
+	_, err = chain.GetBalanceAsOf(&rcdHash, AsOf{Height: 14})
+	assert.Equal(t, ErrHistoryNotAvailable, err)
+	_, err = chain.GetBalanceAsOf(&rcdHash,
+		AsOf{Timestamp: txTs.Add(-time.Second)})
+	assert.Equal(t, ErrHistoryNotAvailable, err)
+}
@@ -0,0 +1,46 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package state
+
+import "github.com/Factom-Asset-Tokens/fatd/metrics"
+
+var (
+	transactionsTotal = metrics.NewCounter("fatd_transactions_total",
+		"Number of valid transactions processed by token chain.",
+		"chainid")
+	rejectedTransactionsTotal = metrics.NewCounter(
+		"fatd_rejected_transactions_total",
+		"Number of invalid transaction entries processed by token chain "+
+			"and rejection reason.",
+		"chainid", "reason")
+)
+
+func init() {
+	metrics.NewGaugeFunc("fatd_faulted_chains",
+		"Number of token chains that failed to sync and are being retried.",
+		func() float64 {
+			faults.RLock()
+			defer faults.RUnlock()
+			return float64(len(faults.m))
+		})
+}
@@ -0,0 +1,247 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package state
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// migrations is the ordered list of all forward schema migrations. The schema
+// version of a database is the number of migrations that have been applied to
+// it, which is recorded in the SQLite user_version pragma. Databases created
+// before versioning was introduced have a user_version of 0.
+//
+// Migrations must never be removed or reordered. New migrations must only be
+// appended. Each migration is pinned to the exact DDL of the version that it
+// creates and must never use the models in schema.go, which always describe
+// the latest version.
+var migrations = []func(*gorm.DB) error{
+	migrateV0toV1,
+	migrateV1toV2,
+	migrateV2toV3,
+	migrateV3toV4,
+	migrateV4toV5,
+}
+
+// DBVersion is the schema version of the databases created by this build.
+var DBVersion = len(migrations)
+
+// migrate applies all migrations required to bring db up to DBVersion. Each
+// migration is applied in its own transaction along with the update to the
+// recorded schema version, so a failed migration leaves the database at the
+// last successfully applied version.
+func migrate(db *gorm.DB) error {
+	version, err := getDBVersion(db)
+	if err != nil {
+		return err
+	}
+	if version > DBVersion {
+		return fmt.Errorf("database version %v is newer than the "+
+			"supported version %v, upgrade fatd", version, DBVersion)
+	}
+	for ; version < DBVersion; version++ {
+		if err := applyMigration(db, version); err != nil {
+			return fmt.Errorf("migrate v%v to v%v: %v",
+				version, version+1, err)
+		}
+		log.Debugf("Migrated database to version %v", version+1)
+	}
+	return nil
+}
+
+func applyMigration(db *gorm.DB, version int) (err error) {
+	tx := db.Begin()
+	if err := tx.Error; err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+	if err = migrations[version](tx); err != nil {
+		return err
+	}
+	if err = setDBVersion(tx, version+1); err != nil {
+		return err
+	}
+	return tx.Commit().Error
+}
+
+func getDBVersion(db *gorm.DB) (int, error) {
+	var version int
+	if err := db.Raw("PRAGMA user_version;").Row().Scan(&version); err != nil {
+		return 0, fmt.Errorf("PRAGMA user_version: %v", err)
+	}
+	return version, nil
+}
+
+func setDBVersion(db *gorm.DB, version int) error {
+	qry := fmt.Sprintf("PRAGMA user_version = %v;", version)
+	if err := db.Exec(qry).Error; err != nil {
+		return fmt.Errorf("%#v: %v", qry, err)
+	}
+	return nil
+}
+
+// execAll executes each of the qries on db in order.
+func execAll(db *gorm.DB, qries ...string) error {
+	for _, qry := range qries {
+		if err := db.Exec(qry).Error; err != nil {
+			return fmt.Errorf("%#v: %v", qry, err)
+		}
+	}
+	return nil
+}
+
+// migrateV0toV1 brings unversioned databases up to the original schema. Empty
+// tables are dropped first so that they are recreated with any changes made to
+// the schema prior to versioning.
+func migrateV0toV1(db *gorm.DB) error {
+	if err := deleteEmptyTables(db); err != nil {
+		return fmt.Errorf("deleteEmptyTables(): %v", err)
+	}
+	return execAll(db,
+		`CREATE TABLE IF NOT EXISTS "entries" ("id" integer primary key autoincrement,"hash" VARCHAR(32) NOT NULL,"timestamp" datetime NOT NULL,"data" blob NOT NULL );`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS uix_entries_hash ON "entries"("hash") ;`,
+		`CREATE TABLE IF NOT EXISTS "address_transactions_to" ("address_id" integer,"entry_id" bigint, PRIMARY KEY ("address_id","entry_id"));`,
+		`CREATE TABLE IF NOT EXISTS "address_transactions_from" ("address_id" integer,"entry_id" bigint, PRIMARY KEY ("address_id","entry_id"));`,
+		`CREATE TABLE IF NOT EXISTS "addresses" ("id" integer primary key autoincrement,"created_at" datetime,"updated_at" datetime,"deleted_at" datetime,"rcd_hash" varchar(32) NOT NULL,"balance" bigint NOT NULL );`,
+		`CREATE INDEX IF NOT EXISTS idx_addresses_deleted_at ON "addresses"(deleted_at) ;`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS uix_addresses_rcd_hash ON "addresses"(rcd_hash) ;`,
+		`CREATE TABLE IF NOT EXISTS "metadata" ("id" integer primary key autoincrement,"created_at" datetime,"updated_at" datetime,"deleted_at" datetime,"height" integer,"token" varchar(255),"issuer" blob,"issued" bigint );`,
+		`CREATE INDEX IF NOT EXISTS idx_metadata_deleted_at ON "metadata"(deleted_at) ;`,
+		`CREATE TABLE IF NOT EXISTS "nf_token_previousowners" ("nf_token_id" integer,"address_id" integer, PRIMARY KEY ("nf_token_id","address_id"));`,
+		`CREATE TABLE IF NOT EXISTS "nf_token_transactions" ("nf_token_id" integer,"entry_id" bigint, PRIMARY KEY ("nf_token_id","entry_id"));`,
+		`CREATE TABLE IF NOT EXISTS "nf_tokens" ("id" integer primary key autoincrement,"created_at" datetime,"updated_at" datetime,"deleted_at" datetime,"nf_token_id" bigint,"metadata" blob,"owner_id" integer );`,
+		`CREATE INDEX IF NOT EXISTS idx_nf_tokens_deleted_at ON "nf_tokens"(deleted_at) ;`,
+		`CREATE INDEX IF NOT EXISTS idx_nf_tokens_owner_id ON "nf_tokens"(owner_id) ;`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS uix_nf_tokens_nf_token_id ON "nf_tokens"(nf_token_id) ;`,
+	)
+}
+
+// migrateV1toV2 adds the entry heights and the tables used for historical
+// balance queries. The history of existing chains is unknown, so their current
+// balances and NF token owners are recorded as of the current height, which
+// becomes the earliest height for which history is available.
+func migrateV1toV2(db *gorm.DB) error {
+	if err := execAll(db,
+		`ALTER TABLE "entries" ADD "height" integer NOT NULL  DEFAULT 0;`,
+		`CREATE INDEX idx_entries_height ON "entries"("height") ;`,
+		`ALTER TABLE "metadata" ADD "history_height" integer;`,
+		`ALTER TABLE "metadata" ADD "history_timestamp" datetime;`,
+		`CREATE TABLE "balance_changes" ("id" integer primary key autoincrement,"address_id" integer NOT NULL,"entry_id" bigint NOT NULL,"height" integer NOT NULL,"timestamp" datetime NOT NULL,"delta" bigint NOT NULL );`,
+		`CREATE INDEX idx_balance_changes_address_id ON "balance_changes"(address_id) ;`,
+		`CREATE INDEX idx_balance_changes_height ON "balance_changes"("height") ;`,
+		`CREATE TABLE "nf_token_owner_changes" ("id" integer primary key autoincrement,"nf_token_id" bigint NOT NULL,"owner_id" integer NOT NULL,"entry_id" bigint NOT NULL,"height" integer NOT NULL,"timestamp" datetime NOT NULL );`,
+		`CREATE INDEX idx_nf_token_owner_changes_height ON "nf_token_owner_changes"("height") ;`,
+		`CREATE INDEX idx_nf_token_owner_changes_nf_token_id ON "nf_token_owner_changes"(nf_token_id) ;`,
+		`CREATE INDEX idx_nf_token_owner_changes_owner_id ON "nf_token_owner_changes"(owner_id) ;`,
+	); err != nil {
+		return err
+	}
+
+	var height uint32
+	err := db.Raw(`SELECT "height" FROM "metadata" WHERE "deleted_at" IS NULL
+		ORDER BY "id" LIMIT 1;`).Row().Scan(&height)
+	if err == sql.ErrNoRows {
+		// New database.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var historyHeight uint32
+	var historyTimestamp time.Time
+	var last time.Time
+	err = db.Raw(`SELECT "timestamp" FROM "entries" WHERE "id" != 1
+		ORDER BY "id" DESC LIMIT 1;`).Row().Scan(&last)
+	switch err {
+	case nil:
+		historyHeight = height
+		historyTimestamp = last.UTC()
+		if err := saveHistoryBaseline(db,
+			historyHeight, historyTimestamp); err != nil {
+			return err
+		}
+	case sql.ErrNoRows:
+		// No transactions, so the full history is known.
+	default:
+		return err
+	}
+	qry := `UPDATE "metadata" SET "history_height" = ?, "history_timestamp" = ?;`
+	if err := db.Exec(qry, historyHeight, historyTimestamp).Error; err != nil {
+		return fmt.Errorf("%#v: %v", qry, err)
+	}
+	return nil
+}
+
+// saveHistoryBaseline records all current balances and NF token owners as
+// having changed at the given height and time.
+func saveHistoryBaseline(db *gorm.DB, height uint32, ts time.Time) error {
+	for _, qry := range []string{
+		`INSERT INTO "balance_changes"
+			("address_id", "entry_id", "height", "timestamp", "delta")
+			SELECT "id", 0, ?, ?, "balance" FROM "addresses"
+			WHERE "balance" > 0 AND "deleted_at" IS NULL;`,
+		`INSERT INTO "nf_token_owner_changes"
+			("nf_token_id", "owner_id", "entry_id", "height", "timestamp")
+			SELECT "nf_token_id", "owner_id", 0, ?, ? FROM "nf_tokens"
+			WHERE "deleted_at" IS NULL;`,
+	} {
+		if err := db.Exec(qry, height, ts).Error; err != nil {
+			return fmt.Errorf("%#v: %v", qry, err)
+		}
+	}
+	return nil
+}
+
+// migrateV2toV3 adds the EBlock KeyMR and Sequence and the entry index to the
+// entries. These are unknown for existing entries.
+func migrateV2toV3(db *gorm.DB) error {
+	return execAll(db,
+		`ALTER TABLE "entries" ADD "e_block_key_mr" VARCHAR(32);`,
+		`ALTER TABLE "entries" ADD "e_block_sequence" integer NOT NULL  DEFAULT 0;`,
+		`ALTER TABLE "entries" ADD "entry_index" integer NOT NULL  DEFAULT 0;`,
+	)
+}
+
+// migrateV3toV4 adds the table of rejected transaction entries. Entries
+// rejected prior to this migration were not saved.
+func migrateV3toV4(db *gorm.DB) error {
+	return execAll(db,
+		`CREATE TABLE "invalid_entries" ("id" integer primary key autoincrement,"hash" VARCHAR(32) NOT NULL,"timestamp" datetime NOT NULL,"data" blob NOT NULL,"height" integer NOT NULL,"e_block_key_mr" VARCHAR(32),"e_block_sequence" integer NOT NULL,"entry_index" integer NOT NULL,"code" varchar(255) NOT NULL,"reason" varchar(255) );`,
+		`CREATE INDEX idx_invalid_entries_hash ON "invalid_entries"("hash") ;`,
+	)
+}
+
+// migrateV4toV5 adds the NetworkID to the metadata. The network of existing
+// databases is unknown, so it is left as zero and recorded the next time the
+// database is loaded.
+func migrateV4toV5(db *gorm.DB) error {
+	return execAll(db, `ALTER TABLE "metadata" ADD "network_id" blob;`)
+}
@@ -0,0 +1,249 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package state
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Factom-Asset-Tokens/fatd/factom"
+	"github.com/Factom-Asset-Tokens/fatd/flag"
+	_log "github.com/Factom-Asset-Tokens/fatd/log"
+)
+
+const testDBFileName = "0000000000000000000000000000000000000000000000000000000000000000" +
+	dbFileExtension
+
+// setupTestDBPath points flag.DBPath at a new temporary directory and returns a
+// function that restores it and removes the directory.
+func setupTestDBPath(t *testing.T) func() {
+	log = _log.New("state")
+	dir, err := ioutil.TempDir("", "fatd-state-test")
+	require.NoError(t, err)
+	dbPath := flag.DBPath
+	flag.DBPath = dir
+	return func() {
+		flag.DBPath = dbPath
+		os.RemoveAll(dir)
+	}
+}
+
+// loadFixture creates the test database from the SQL script in fname.
+func loadFixture(t *testing.T, fname string) {
+	script, err := ioutil.ReadFile(fname)
+	require.NoError(t, err)
+	db, err := gorm.Open(dbDriver, filepath.Join(flag.DBPath, testDBFileName))
+	require.NoError(t, err)
+	defer db.Close()
+	require.NoError(t, db.Exec(string(script)).Error)
+}
+
+func TestMigrate(t *testing.T) {
+	fixtures, err := filepath.Glob("testdata/v*.sql")
+	require.NoError(t, err)
+	require.NotEmpty(t, fixtures)
+	for _, fname := range fixtures {
+		version, err := strconv.Atoi(strings.TrimSuffix(
+			strings.TrimPrefix(filepath.Base(fname), "v"), ".sql"))
+		require.NoError(t, err, fname)
+		t.Run(fmt.Sprintf("v%v", version), func(t *testing.T) {
+			defer setupTestDBPath(t)()
+			assert := assert.New(t)
+			require := require.New(t)
+			loadFixture(t, fname)
+
+			var chain Chain
+			require.NoError(chain.open(testDBFileName))
+			defer chain.Close()
+
+			v, err := getDBVersion(chain.DB)
+			require.NoError(err)
+			assert.Equal(DBVersion, v)
+
+			var metadata Metadata
+			require.NoError(chain.First(&metadata).Error)
+			assert.Equal("test", metadata.Token)
+			assert.Equal(uint32(170000), metadata.Height)
+			assert.Equal(uint64(100), metadata.Issued)
+
+			var adrs []Address
+			require.NoError(chain.Order("id").Find(&adrs).Error)
+			require.Len(adrs, 2)
+			assert.Equal(uint64(100), adrs[1].Balance)
+
+			var count int
+			require.NoError(chain.DB.Model(&entry{}).Count(&count).Error)
+			assert.Equal(2, count)
+
+			// Entries saved prior to migration must still load.
+			_, err = chain.GetEntries(nil, nil, nil, nil, nil, "", "", 0, 0)
+			require.NoError(err)
+
+			// The current balances are the earliest available history.
+			assert.Equal(uint32(170000), metadata.HistoryHeight)
+			require.NoError(chain.DB.Model(&BalanceChange{}).
+				Where("height = ? AND delta = ?", 170000, 100).
+				Count(&count).Error)
+			assert.Equal(1, count)
+
+			// The network is unknown until the database is loaded.
+			assert.Equal(factom.NetworkID{}, metadata.NetworkID)
+
+			// Data added by later versions is preserved.
+			if version >= 3 {
+				e, err := chain.GetEntry(factom.NewBytes32(
+					bytes.Repeat([]byte{0xef}, 32)))
+				require.NoError(err)
+				assert.Equal(factom.NewBytes32(bytes.Repeat(
+					[]byte{0xab}, 32)), e.EBlockKeyMR)
+				assert.Equal(uint32(5), e.EBlockSequence)
+				assert.Equal(uint32(3), e.EntryIndex)
+			}
+			if version >= 4 {
+				invalid, err := chain.GetInvalidEntries(
+					factom.NewBytes32(bytes.Repeat(
+						[]byte{0xde}, 32)))
+				require.NoError(err)
+				require.Len(invalid, 1)
+				assert.Equal(RejectionInvalidData, invalid[0].Code)
+			}
+
+			assertSchema(t, chain.DB)
+		})
+	}
+}
+
+// assertSchema asserts that the columns and indexes of all tables in db match
+// those created by AutoMigrate for the current models.
+func assertSchema(t *testing.T, db *gorm.DB) {
+	require := require.New(t)
+	latest, err := gorm.Open(dbDriver, ":memory:")
+	require.NoError(err)
+	defer latest.Close()
+	require.NoError(latest.AutoMigrate(&entry{}, &Address{}, &Metadata{},
+		&NFToken{}, &BalanceChange{}, &NFTokenOwnerChange{},
+		&InvalidEntry{}).Error)
+	assert.Equal(t, schema(t, latest), schema(t, db))
+}
+
+// schema returns a description of every column and index of every table in
+// db.
+func schema(t *testing.T, db *gorm.DB) map[string][]string {
+	require := require.New(t)
+	var tables []struct{ Name string }
+	require.NoError(db.Raw("SELECT name FROM sqlite_master " +
+		"WHERE type = 'table' AND name != 'sqlite_sequence';").
+		Scan(&tables).Error)
+	desc := make(map[string][]string, len(tables))
+	for _, table := range tables {
+		rows, err := db.Raw(fmt.Sprintf("PRAGMA table_info(%q);",
+			table.Name)).Rows()
+		require.NoError(err)
+		for rows.Next() {
+			var cid, notNull, pk int
+			var name, typ string
+			var dflt *string
+			require.NoError(rows.Scan(&cid, &name, &typ, &notNull,
+				&dflt, &pk))
+			d := "NULL"
+			if dflt != nil {
+				d = *dflt
+			}
+			desc[table.Name] = append(desc[table.Name], fmt.Sprintf(
+				"column %v %v notnull=%v default=%v pk=%v",
+				name, strings.ToLower(typ), notNull, d, pk))
+		}
+		rows.Close()
+		var indexes []struct{ Name, SQL string }
+		require.NoError(db.Raw("SELECT name, sql FROM sqlite_master "+
+			"WHERE type = 'index' AND tbl_name = ? "+
+			"AND sql IS NOT NULL ORDER BY name;", table.Name).
+			Scan(&indexes).Error)
+		for _, index := range indexes {
+			desc[table.Name] = append(desc[table.Name], fmt.Sprintf(
+				"index %v %v", index.Name, index.SQL))
+		}
+	}
+	return desc
+}
+
+func TestCheckNetworkID(t *testing.T) {
+	defer setupTestDBPath(t)()
+	defer func(netID factom.NetworkID) { NetworkID = netID }(NetworkID)
+	assert := assert.New(t)
+	require := require.New(t)
+	loadFixture(t, "testdata/v1.sql")
+
+	chain := Chain{ID: new(factom.Bytes32)}
+	require.NoError(chain.open(testDBFileName))
+	defer chain.Close()
+	require.NoError(chain.First(&chain.Metadata).Error)
+
+	// The unknown network is recorded as the current network.
+	NetworkID = factom.TestnetID
+	require.NoError(chain.checkNetworkID())
+	var metadata Metadata
+	require.NoError(chain.First(&metadata).Error)
+	assert.Equal(factom.TestnetID, metadata.NetworkID)
+
+	require.NoError(chain.checkNetworkID())
+
+	NetworkID = factom.MainnetID
+	assert.EqualError(chain.checkNetworkID(), "ChainID("+
+		"0000000000000000000000000000000000000000000000000000000000000000"+
+		"): database is for network testnet but factomd is on network "+
+		"mainnet")
+}
+
+func TestMigrateNew(t *testing.T) {
+	defer setupTestDBPath(t)()
+	var chain Chain
+	require.NoError(t, chain.open(testDBFileName))
+	defer chain.Close()
+	v, err := getDBVersion(chain.DB)
+	require.NoError(t, err)
+	assert.Equal(t, DBVersion, v)
+}
+
+func TestMigrateNewerVersion(t *testing.T) {
+	defer setupTestDBPath(t)()
+	db, err := gorm.Open(dbDriver, filepath.Join(flag.DBPath, testDBFileName))
+	require.NoError(t, err)
+	require.NoError(t, setDBVersion(db, DBVersion+1))
+	db.Close()
+
+	var chain Chain
+	assert.EqualError(t, chain.open(testDBFileName), fmt.Sprintf(
+		"database version %v is newer than the supported version %v, "+
+			"upgrade fatd", DBVersion+1, DBVersion))
+}
@@ -0,0 +1,602 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package state
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Factom-Asset-Tokens/fatd/factom"
+	"github.com/Factom-Asset-Tokens/fatd/fat/fat0"
+	"github.com/Factom-Asset-Tokens/fatd/fat/fat1"
+)
+
+// PendingExpiration is how long after a pending entry is first seen that it is
+// dropped if factomd no longer lists it and it has not been saved as a valid
+// or invalid transaction.
+const PendingExpiration = 30 * time.Minute
+
+// PendingEntry is a transaction entry that has been acknowledged by factomd
+// but not yet confirmed in an EBlock.
+type PendingEntry struct {
+	factom.Entry
+
+	// Seen is when the entry was first seen as pending.
+	Seen time.Time
+
+	// rejection is set if the transaction is not valid when applied to the
+	// saved state after all earlier pending transactions.
+	rejection *rejection
+}
+
+// pendingChain holds the pending entries of a chain in the order that they
+// were first seen.
+type pendingChain struct {
+	entries []PendingEntry
+	index   map[factom.Bytes32]int
+}
+
+func newPendingChain() *pendingChain {
+	return &pendingChain{index: make(map[factom.Bytes32]int)}
+}
+
+func (pc *pendingChain) add(pe PendingEntry) {
+	pc.index[*pe.Hash] = len(pc.entries)
+	pc.entries = append(pc.entries, pe)
+}
+
+func (pc *pendingChain) copy() *pendingChain {
+	cp := newPendingChain()
+	for _, pe := range pc.entries {
+		cp.add(pe)
+	}
+	return cp
+}
+
+func (pc *pendingChain) get(hash *factom.Bytes32) (PendingEntry, bool) {
+	if pc == nil {
+		return PendingEntry{}, false
+	}
+	i, ok := pc.index[*hash]
+	if !ok {
+		return PendingEntry{}, false
+	}
+	return pc.entries[i], true
+}
+
+// pending holds the pending entries of all issued chains. The pendingChains
+// are never modified once they are in m, so they may be used after the lock is
+// released.
+//
+// submitted holds the entries added by AddPendingEntry since ProcessPending
+// last started, so that they are not lost when ProcessPending replaces m.
+var pending = struct {
+	m         map[factom.Bytes32]*pendingChain
+	submitted map[factom.Bytes32][]PendingEntry
+	sync.RWMutex
+}{m: make(map[factom.Bytes32]*pendingChain),
+	submitted: make(map[factom.Bytes32][]PendingEntry)}
+
+// ProcessPending updates the pending transactions of all issued chains from
+// pe and speculatively validates them, in the order they were first seen,
+// against the saved state of each chain.
+func ProcessPending(pe factom.PendingEntries) error {
+	pending.Lock()
+	prev := make(map[factom.Bytes32]*pendingChain, len(pending.m))
+	for chainID, pc := range pending.m {
+		prev[chainID] = pc
+	}
+	pending.submitted = make(map[factom.Bytes32][]PendingEntry)
+	pending.Unlock()
+
+	now := time.Now()
+	next := make(map[factom.Bytes32]*pendingChain)
+	for _, chainID := range Chains.GetIssued() {
+		chainID := chainID
+		chain := Chains.Get(&chainID)
+		pc, err := chain.processPending(prev[chainID],
+			pe.Entries(chainID), now)
+		if err != nil {
+			return err
+		}
+		if len(pc.entries) > 0 {
+			next[chainID] = pc
+		}
+	}
+
+	pending.Lock()
+	defer pending.Unlock()
+	for chainID, es := range pending.submitted {
+		pc := next[chainID]
+		if pc == nil {
+			pc = newPendingChain()
+		}
+		pc = pc.copy()
+		for _, pe := range es {
+			if _, ok := pc.get(pe.Hash); !ok {
+				pc.add(pe)
+			}
+		}
+		next[chainID] = pc
+	}
+	pending.m = next
+	return nil
+}
+
+func (chain Chain) processPending(prev *pendingChain, es []factom.Entry,
+	now time.Time) (*pendingChain, error) {
+	listed := make(map[factom.Bytes32]struct{}, len(es))
+	for _, e := range es {
+		if e.Hash != nil {
+			listed[*e.Hash] = struct{}{}
+		}
+	}
+
+	next := newPendingChain()
+	// Retain previously seen entries that have not been saved or expired.
+	if prev != nil {
+		for _, pe := range prev.entries {
+			if _, ok := listed[*pe.Hash]; !ok &&
+				now.Sub(pe.Seen) > PendingExpiration {
+				continue
+			}
+			saved, err := chain.isSaved(pe.Hash)
+			if err != nil {
+				return nil, err
+			}
+			if saved {
+				continue
+			}
+			next.add(pe)
+		}
+	}
+	// Add newly pending entries.
+	for _, e := range es {
+		if e.Hash == nil || e.ChainID == nil {
+			// The entry has not yet been revealed.
+			continue
+		}
+		if _, ok := next.get(e.Hash); ok {
+			continue
+		}
+		saved, err := chain.isSaved(e.Hash)
+		if err != nil {
+			return nil, err
+		}
+		if saved {
+			continue
+		}
+		if err := e.Get(c); err != nil {
+			log.Debugf("Pending Entry%v.Get(c): %v", e.Hash, err)
+			continue
+		}
+		next.add(PendingEntry{Entry: e, Seen: now})
+	}
+
+	es = make([]factom.Entry, len(next.entries))
+	for i, pe := range next.entries {
+		es[i] = pe.Entry
+	}
+	rejs, err := chain.applyPending(es)
+	if err != nil {
+		return nil, err
+	}
+	for i := range next.entries {
+		next.entries[i].rejection = rejs[i]
+	}
+	return next, nil
+}
+
+// isSaved returns true if the entry with the given hash has been saved as
+// either a valid or an invalid transaction.
+func (chain Chain) isSaved(hash *factom.Bytes32) (bool, error) {
+	var count int
+	if err := chain.DB.Model(&entry{}).Where("hash = ?", hash).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return true, nil
+	}
+	if err := chain.DB.Model(&InvalidEntry{}).Where("hash = ?", hash).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// GetPendingEntries returns the speculatively valid pending transaction
+// entries in the order they were first seen.
+func (chain Chain) GetPendingEntries() []PendingEntry {
+	pending.RLock()
+	defer pending.RUnlock()
+	pc := pending.m[*chain.ID]
+	if pc == nil {
+		return nil
+	}
+	es := make([]PendingEntry, 0, len(pc.entries))
+	for _, pe := range pc.entries {
+		if pe.rejection == nil {
+			es = append(es, pe)
+		}
+	}
+	return es
+}
+
+// GetPendingEntry returns the pending entry with the given hash, if it is
+// speculatively valid.
+func (chain Chain) GetPendingEntry(hash *factom.Bytes32) (PendingEntry, bool) {
+	pending.RLock()
+	defer pending.RUnlock()
+	pe, ok := pending.m[*chain.ID].get(hash)
+	if !ok || pe.rejection != nil {
+		return PendingEntry{}, false
+	}
+	return pe, true
+}
+
+// AddPendingEntry speculatively applies the transaction in e, which must have
+// its Hash populated, to the saved state of the chain after all speculatively
+// valid pending transactions. If it is valid, e is tracked as pending until it
+// is confirmed or rejected, or until it expires if factomd never lists it.
+// Otherwise, the returned error can be passed to RejectionReason.
+//
+// The saved state is read without holding the pending lock. If the pending
+// entries of the chain change in the meantime, e is applied again.
+func (chain Chain) AddPendingEntry(e factom.Entry) error {
+	for {
+		pending.RLock()
+		pc := pending.m[*chain.ID]
+		pending.RUnlock()
+
+		if _, ok := pc.get(e.Hash); ok {
+			return reject(RejectionReplay, "duplicate transaction")
+		}
+		var es []factom.Entry
+		if pc != nil {
+			for _, pe := range pc.entries {
+				if pe.rejection == nil {
+					es = append(es, pe.Entry)
+				}
+			}
+		}
+		es = append(es, e)
+		rejs, err := chain.applyPending(es)
+		if err != nil {
+			return err
+		}
+		if rej := rejs[len(rejs)-1]; rej != nil {
+			return *rej
+		}
+
+		if chain.addPendingEntry(pc, e) {
+			return nil
+		}
+	}
+}
+
+// addPendingEntry adds e to the pending entries of the chain, unless they are
+// no longer prev, in which case false is returned.
+func (chain Chain) addPendingEntry(prev *pendingChain, e factom.Entry) bool {
+	pending.Lock()
+	defer pending.Unlock()
+	pc := pending.m[*chain.ID]
+	if pc != prev {
+		return false
+	}
+	if pc == nil {
+		pc = newPendingChain()
+	}
+	pc = pc.copy()
+	pe := PendingEntry{Entry: e, Seen: time.Now()}
+	pc.add(pe)
+	pending.m[*chain.ID] = pc
+	pending.submitted[*chain.ID] = append(pending.submitted[*chain.ID], pe)
+	return true
+}
+
+// RemovePendingEntry stops tracking the pending entry with the given hash. This
+// is used if an entry added by AddPendingEntry could not be submitted.
+func (chain Chain) RemovePendingEntry(hash *factom.Bytes32) {
+	pending.Lock()
+	defer pending.Unlock()
+
+	pc := newPendingChain()
+	if prev := pending.m[*chain.ID]; prev != nil {
+		for _, pe := range prev.entries {
+			if *pe.Hash != *hash {
+				pc.add(pe)
+			}
+		}
+	}
+	pending.m[*chain.ID] = pc
+
+	submitted := pending.submitted[*chain.ID]
+	for i, pe := range submitted {
+		if *pe.Hash == *hash {
+			pending.submitted[*chain.ID] = append(
+				submitted[:i:i], submitted[i+1:]...)
+			break
+		}
+	}
+}
+
+// pendingState is an in-memory overlay of the changes that pending
+// transactions make to the saved state of a chain. The saved balances, owners
+// and entries that the transactions refer to are read once, so speculatively
+// applying transactions never writes to, or holds a lock on, the database.
+type pendingState struct {
+	issued uint64
+
+	saved    map[factom.Bytes32]struct{}
+	balances map[factom.FAAddress]uint64
+	// owners omits NFTokens that have not been issued.
+	owners map[fat1.NFTokenID]factom.FAAddress
+}
+
+// pendingQueryLimit is the maximum number of values bound to a single query,
+// which keeps below the SQLite default limit of 999.
+const pendingQueryLimit = 500
+
+// applyPending speculatively applies the transactions in es, in order, to the
+// saved state of the chain, as if they were the entries of the next EBlock,
+// using the same rules as processTransactions. The returned rejections
+// correspond to es, and are nil for valid transactions. Nothing is written to
+// the database.
+func (chain Chain) applyPending(es []factom.Entry) ([]*rejection, error) {
+	txs := make([]transaction, len(es))
+	errs := make([]error, len(es))
+	hashes := make([]factom.Bytes32, 0, len(es))
+	adrs := make(map[factom.FAAddress]struct{})
+	tkns := make(fat1.NFTokens)
+	for i, e := range es {
+		if e.Hash != nil {
+			hashes = append(hashes, *e.Hash)
+		}
+		switch chain.Type {
+		case fat0.Type:
+			tx := fat0.NewTransaction(e)
+			txs[i] = &tx
+			if errs[i] = tx.UnmarshalEntry(); errs[i] != nil {
+				continue
+			}
+			for rcdHash := range tx.Inputs {
+				adrs[rcdHash] = struct{}{}
+			}
+			for rcdHash := range tx.Outputs {
+				adrs[rcdHash] = struct{}{}
+			}
+		case fat1.Type:
+			tx := fat1.NewTransaction(e)
+			txs[i] = &tx
+			if errs[i] = tx.UnmarshalEntry(); errs[i] != nil {
+				continue
+			}
+			for rcdHash, ids := range tx.Inputs {
+				adrs[rcdHash] = struct{}{}
+				for tknID := range ids {
+					tkns[tknID] = struct{}{}
+				}
+			}
+			for rcdHash := range tx.Outputs {
+				adrs[rcdHash] = struct{}{}
+			}
+		default:
+			return nil, fmt.Errorf("invalid token type: %v", chain.Type)
+		}
+	}
+
+	ps, err := chain.loadPendingState(hashes, adrs, tkns)
+	if err != nil {
+		return nil, err
+	}
+	rejs := make([]*rejection, len(es))
+	for i, tx := range txs {
+		err := errs[i]
+		if err != nil {
+			err = reject(RejectionInvalidData, "%v", err)
+		} else {
+			err = chain.applyPendingTransaction(&ps, es[i], tx)
+		}
+		if err != nil {
+			rej, ok := err.(rejection)
+			if !ok {
+				return nil, err
+			}
+			rejs[i] = &rej
+		}
+	}
+	return rejs, nil
+}
+
+// loadPendingState reads the saved entries with the given hashes, and the
+// saved balances and owners of the given addresses and NFTokens.
+func (chain Chain) loadPendingState(hashes []factom.Bytes32,
+	adrs map[factom.FAAddress]struct{},
+	tkns fat1.NFTokens) (pendingState, error) {
+	ps := pendingState{
+		issued:   chain.Issued,
+		saved:    make(map[factom.Bytes32]struct{}, len(hashes)),
+		balances: make(map[factom.FAAddress]uint64, len(adrs)),
+		owners:   make(map[fat1.NFTokenID]factom.FAAddress, len(tkns)),
+	}
+
+	for len(hashes) > 0 {
+		n := len(hashes)
+		if n > pendingQueryLimit {
+			n = pendingQueryLimit
+		}
+		var saved []entry
+		if err := chain.Select("hash").Where("hash IN (?)", hashes[:n]).
+			Find(&saved).Error; err != nil {
+			return ps, err
+		}
+		for _, e := range saved {
+			ps.saved[*e.Hash] = struct{}{}
+		}
+		hashes = hashes[n:]
+	}
+
+	rcdHashes := make([]factom.FAAddress, 0, len(adrs))
+	for rcdHash := range adrs {
+		rcdHashes = append(rcdHashes, rcdHash)
+	}
+	for len(rcdHashes) > 0 {
+		n := len(rcdHashes)
+		if n > pendingQueryLimit {
+			n = pendingQueryLimit
+		}
+		var saved []Address
+		if err := chain.Where("rcd_hash IN (?)", rcdHashes[:n]).
+			Find(&saved).Error; err != nil {
+			return ps, err
+		}
+		for _, a := range saved {
+			ps.balances[a.Address()] = a.Balance
+		}
+		rcdHashes = rcdHashes[n:]
+	}
+
+	tknIDs := make([]fat1.NFTokenID, 0, len(tkns))
+	for tknID := range tkns {
+		tknIDs = append(tknIDs, tknID)
+	}
+	for len(tknIDs) > 0 {
+		n := len(tknIDs)
+		if n > pendingQueryLimit {
+			n = pendingQueryLimit
+		}
+		var saved []NFToken
+		if err := chain.Preload("Owner").
+			Where("nf_token_id IN (?)", tknIDs[:n]).
+			Find(&saved).Error; err != nil {
+			return ps, err
+		}
+		for _, tkn := range saved {
+			ps.owners[tkn.NFTokenID] = tkn.Owner.Address()
+		}
+		tknIDs = tknIDs[n:]
+	}
+	return ps, nil
+}
+
+// applyPendingTransaction validates the unmarshaled tx from e against ps, and
+// then applies it to ps. The rejections are checked in the same order as by
+// validTransaction and applyFAT0 or applyFAT1.
+func (chain Chain) applyPendingTransaction(ps *pendingState, e factom.Entry,
+	tx transaction) error {
+	if err := chain.validSignatures(tx); err != nil {
+		return err
+	}
+	if e.Hash != nil {
+		if _, ok := ps.saved[*e.Hash]; ok {
+			return reject(RejectionReplay, "replayed transaction")
+		}
+	}
+
+	// Changes are only made to ps once the transaction is known to be
+	// valid.
+	issued := ps.issued
+	balances := make(map[factom.FAAddress]uint64)
+	balance := func(rcdHash factom.FAAddress) uint64 {
+		if b, ok := balances[rcdHash]; ok {
+			return b
+		}
+		return ps.balances[rcdHash]
+	}
+	owners := make(map[fat1.NFTokenID]factom.FAAddress)
+
+	switch tx := tx.(type) {
+	case *fat0.Transaction:
+		for rcdHash, amount := range tx.Inputs {
+			if tx.IsCoinbase() {
+				if chain.Supply > 0 &&
+					uint64(chain.Supply)-issued < amount {
+					return reject(RejectionInsufficientSupply,
+						"insufficient coinbase supply")
+				}
+				issued += amount
+				break
+			}
+			if balance(rcdHash) < amount {
+				return reject(RejectionInsufficientBalance,
+					"insufficient balance: %v", rcdHash)
+			}
+			balances[rcdHash] = balance(rcdHash) - amount
+		}
+		for rcdHash, amount := range tx.Outputs {
+			balances[rcdHash] = balance(rcdHash) + amount
+		}
+	case *fat1.Transaction:
+		for rcdHash, tkns := range tx.Inputs {
+			if tx.IsCoinbase() {
+				if chain.Supply > 0 && uint64(chain.Supply)-issued <
+					uint64(len(tkns)) {
+					return reject(RejectionInsufficientSupply,
+						"insufficient coinbase supply")
+				}
+				issued += uint64(len(tkns))
+				for tknID := range tkns {
+					if _, ok := ps.owners[tknID]; ok {
+						return reject(RejectionNFTokenExists,
+							"NFTokenID(%v) already exists",
+							tknID)
+					}
+				}
+				break
+			}
+			if balance(rcdHash) < uint64(len(tkns)) {
+				return reject(RejectionInsufficientBalance,
+					"insufficient balance: %v", rcdHash)
+			}
+			balances[rcdHash] = balance(rcdHash) - uint64(len(tkns))
+			for tknID := range tkns {
+				if owner, ok := ps.owners[tknID]; !ok ||
+					owner != rcdHash {
+					return reject(RejectionNFTokenNotOwned,
+						"NFTokenID(%v) is not owned by %v",
+						tknID, rcdHash)
+				}
+			}
+		}
+		for rcdHash, tkns := range tx.Outputs {
+			balances[rcdHash] = balance(rcdHash) + uint64(len(tkns))
+			for tknID := range tkns {
+				owners[tknID] = rcdHash
+			}
+		}
+	}
+
+	ps.issued = issued
+	for rcdHash, b := range balances {
+		ps.balances[rcdHash] = b
+	}
+	for tknID, owner := range owners {
+		ps.owners[tknID] = owner
+	}
+	if e.Hash != nil {
+		ps.saved[*e.Hash] = struct{}{}
+	}
+	return nil
+}
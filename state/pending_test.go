@@ -0,0 +1,313 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package state
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Factom-Asset-Tokens/fatd/factom"
+	"github.com/Factom-Asset-Tokens/fatd/fat"
+	"github.com/Factom-Asset-Tokens/fatd/fat/fat0"
+	"github.com/Factom-Asset-Tokens/fatd/fat/fat1"
+)
+
+// newTestSpend returns a function that returns a signed transaction of amount
+// from adrs[1] to adrs[0].
+func newTestSpend(t *testing.T, chain *Chain,
+	adrs []factom.FsAddress) func(amount uint64) factom.Entry {
+	return func(amount uint64) factom.Entry {
+		tx := fat0.NewTransaction(factom.Entry{ChainID: chain.ID})
+		tx.Inputs = fat0.AddressAmountMap{adrs[1].FAAddress(): amount}
+		tx.Outputs = fat0.AddressAmountMap{adrs[0].FAAddress(): amount}
+		require.NoError(t, tx.MarshalEntry())
+		tx.Sign(adrs[1])
+		hash, err := tx.ComputeHash()
+		require.NoError(t, err)
+		tx.Hash = &hash
+		return tx.Entry.Entry
+	}
+}
+
+func TestProcessPending(t *testing.T) {
+	defer setupTestDBPath(t)()
+	assert := assert.New(t)
+	require := require.New(t)
+	chain, adrs := newTestFAT0Chain(t)
+	defer chain.Close()
+
+	newTx := newTestSpend(t, chain, adrs)
+
+	es, err := chain.GetEntries(nil, nil, nil, nil, nil, "", "", 0, 0)
+	require.NoError(err)
+	confirmed := es[1].Entry
+
+	// adrs[1] has a balance of 40, so the second spend is only valid if the
+	// first is not pending.
+	spend30, spend20 := newTx(30), newTx(20)
+	now := time.Now()
+	pc, err := chain.processPending(nil,
+		[]factom.Entry{confirmed, spend30, spend20}, now)
+	require.NoError(err)
+	require.Len(pc.entries, 2, "confirmed entry is not pending")
+	assert.Nil(pc.entries[0].rejection)
+	require.NotNil(pc.entries[1].rejection)
+	assert.Equal(RejectionInsufficientBalance, pc.entries[1].rejection.Code)
+
+	pending.Lock()
+	pending.m[*chain.ID] = pc
+	pending.Unlock()
+	defer func() {
+		pending.Lock()
+		delete(pending.m, *chain.ID)
+		pending.Unlock()
+	}()
+	assert.Len(chain.GetPendingEntries(), 1)
+	_, ok := chain.GetPendingEntry(spend30.Hash)
+	assert.True(ok)
+	_, ok = chain.GetPendingEntry(spend20.Hash)
+	assert.False(ok, "speculatively invalid")
+
+	// Once spend30 is no longer listed and has expired, spend20 becomes
+	// valid.
+	pc, err = chain.processPending(pc, []factom.Entry{spend20},
+		now.Add(PendingExpiration+time.Second))
+	require.NoError(err)
+	require.Len(pc.entries, 1)
+	assert.Equal(spend20.Hash, pc.entries[0].Hash)
+	assert.Nil(pc.entries[0].rejection)
+	assert.Equal(now, pc.entries[0].Seen)
+}
+
+func TestAddPendingEntry(t *testing.T) {
+	defer setupTestDBPath(t)()
+	assert := assert.New(t)
+	require := require.New(t)
+	chain, adrs := newTestFAT0Chain(t)
+	defer chain.Close()
+	Chains.set(chain.ID, chain)
+	defer func() {
+		Chains.Lock()
+		delete(Chains.m, *chain.ID)
+		Chains.ids = nil
+		Chains.Unlock()
+		pending.Lock()
+		delete(pending.m, *chain.ID)
+		delete(pending.submitted, *chain.ID)
+		pending.Unlock()
+	}()
+
+	// adrs[1] has a balance of 40.
+	newTx := newTestSpend(t, chain, adrs)
+	spend30, spend20, spend10 := newTx(30), newTx(20), newTx(10)
+	require.NoError(chain.AddPendingEntry(spend30))
+
+	err := chain.AddPendingEntry(spend30)
+	reason, ok := RejectionReason(err)
+	require.True(ok, err)
+	assert.Equal("duplicate transaction", reason)
+
+	err = chain.AddPendingEntry(spend20)
+	reason, ok = RejectionReason(err)
+	require.True(ok, err)
+	assert.Contains(reason, "insufficient balance")
+
+	require.NoError(chain.AddPendingEntry(spend10))
+	assert.Len(chain.GetPendingEntries(), 2)
+
+	// Submitted entries survive ProcessPending even though factomd does
+	// not list them yet.
+	require.NoError(ProcessPending(nil))
+	assert.Len(chain.GetPendingEntries(), 2)
+
+	chain.RemovePendingEntry(spend30.Hash)
+	require.NoError(chain.AddPendingEntry(spend20))
+	assert.Len(chain.GetPendingEntries(), 2)
+
+	es, err := chain.GetEntries(nil, nil, nil, nil, nil, "", "", 0, 0)
+	require.NoError(err)
+	err = chain.AddPendingEntry(es[1].Entry)
+	reason, ok = RejectionReason(err)
+	require.True(ok, err)
+	assert.Equal("replayed transaction", reason)
+
+	// Pending transactions do not write to the database, so they are not
+	// blocked while an EBlock is being processed.
+	tx := chain.Begin()
+	defer tx.Rollback()
+	require.NoError(tx.Exec("UPDATE metadata SET height = height;").Error)
+	added := make(chan error, 1)
+	go func() { added <- chain.AddPendingEntry(newTx(5)) }()
+	select {
+	case err := <-added:
+		assert.NoError(err)
+	case <-time.After(time.Second):
+		t.Fatal("AddPendingEntry blocked on the database")
+	}
+}
+
+func TestPendingCoinbase(t *testing.T) {
+	defer setupTestDBPath(t)()
+	assert := assert.New(t)
+	require := require.New(t)
+	chain, adrs := newTestFAT0Chain(t)
+	defer chain.Close()
+
+	sk1, err := factom.GenerateSK1Key()
+	require.NoError(err)
+	otherSK1, err := factom.GenerateSK1Key()
+	require.NoError(err)
+	chain.ID1 = sk1.ID1Key()
+
+	newCoinbase := func(sk1 factom.SK1Key) factom.Entry {
+		tx := fat0.NewTransaction(factom.Entry{ChainID: chain.ID})
+		tx.Inputs = fat0.AddressAmountMap{fat.Coinbase(): 10}
+		tx.Outputs = fat0.AddressAmountMap{adrs[1].FAAddress(): 10}
+		require.NoError(tx.MarshalEntry())
+		tx.Sign(sk1)
+		hash, err := tx.ComputeHash()
+		require.NoError(err)
+		tx.Hash = &hash
+		return tx.Entry.Entry
+	}
+	rejs, err := chain.applyPending([]factom.Entry{
+		newCoinbase(otherSK1), newCoinbase(sk1)})
+	require.NoError(err)
+	require.Len(rejs, 2)
+	require.NotNil(rejs[0])
+	assert.Equal(RejectionInvalidSignature, rejs[0].Code)
+	assert.Nil(rejs[1])
+
+	// Nothing was saved.
+	rcdHash := adrs[1].FAAddress()
+	adr, err := chain.GetAddress(&rcdHash)
+	require.NoError(err)
+	assert.Equal(uint64(40), adr.Balance)
+
+	// Without the issuer's ID1Key no coinbase transaction is valid.
+	chain.ID1 = factom.ID1Key{}
+	rejs, err = chain.applyPending([]factom.Entry{newCoinbase(sk1)})
+	require.NoError(err)
+	require.NotNil(rejs[0])
+	assert.Equal(RejectionNoID1Key, rejs[0].Code)
+}
+
+func TestPendingFAT1(t *testing.T) {
+	defer setupTestDBPath(t)()
+	assert := assert.New(t)
+	require := require.New(t)
+
+	issuerSecret, err := factom.GenerateSK1Key()
+	require.NoError(err)
+	adrs := make([]factom.FsAddress, 2)
+	for i := range adrs {
+		adrs[i], err = factom.GenerateFsAddress()
+		require.NoError(err)
+	}
+	issuer := factom.NewBytes32(make([]byte, 32))
+	chainID := fat.ChainID("test", *issuer)
+	chain := &Chain{ID: &chainID}
+	chain.Metadata.Token = "test"
+	chain.Metadata.Issuer = issuer
+	chain.Identity.ChainID = issuer
+	chain.ID1 = issuerSecret.ID1Key()
+	require.NoError(chain.setupDB())
+	defer chain.Close()
+
+	eb := &factom.EBlock{KeyMR: factom.NewBytes32(make([]byte, 32)),
+		Height: 10}
+	issuance := fat.NewIssuance(factom.Entry{ChainID: chain.ID})
+	issuance.Type = fat1.Type
+	issuance.Supply = 4
+	require.NoError(issuance.MarshalEntry())
+	issuance.Sign(issuerSecret)
+	issuance.Height = eb.Height
+	require.NoError(chain.issue(issuance, eb, 0))
+
+	newTx := func(input factom.FAAddress, output factom.FAAddress,
+		ids ...fat1.NFTokenID) factom.Entry {
+		var setters []fat1.NFTokensSetter
+		for _, id := range ids {
+			setters = append(setters, id)
+		}
+		tkns, err := fat1.NewNFTokens(setters...)
+		require.NoError(err)
+		tx := fat1.NewTransaction(factom.Entry{ChainID: chain.ID})
+		tx.Inputs = fat1.AddressNFTokensMap{input: tkns}
+		tx.Outputs = fat1.AddressNFTokensMap{output: tkns}
+		require.NoError(tx.MarshalEntry())
+		if tx.IsCoinbase() {
+			tx.Sign(issuerSecret)
+		} else {
+			for _, adr := range adrs {
+				if adr.FAAddress() == input {
+					tx.Sign(adr)
+				}
+			}
+		}
+		hash, err := tx.ComputeHash()
+		require.NoError(err)
+		tx.Hash = &hash
+		tx.Height = eb.Height
+		return tx.Entry.Entry
+	}
+	adr0, adr1 := adrs[0].FAAddress(), adrs[1].FAAddress()
+	require.NoError(chain.applyTransaction(
+		newTx(fat.Coinbase(), adr0, 1, 2), eb, 1))
+
+	rejs, err := chain.applyPending([]factom.Entry{
+		newTx(adr0, adr1, 1),
+		newTx(adr0, adr1, 1),
+		newTx(fat.Coinbase(), adr0, 2),
+		newTx(fat.Coinbase(), adr1, 3),
+		newTx(adr1, adr0, 1, 3),
+		newTx(fat.Coinbase(), adr1, 4, 5),
+	})
+	require.NoError(err)
+	require.Len(rejs, 6)
+	for i, code := range []RejectionCode{
+		"",
+		RejectionNFTokenNotOwned,
+		RejectionNFTokenExists,
+		"",
+		"",
+		RejectionInsufficientSupply,
+	} {
+		if code == "" {
+			assert.Nil(rejs[i], i)
+			continue
+		}
+		require.NotNil(rejs[i], i)
+		assert.Equal(code, rejs[i].Code, i)
+	}
+
+	// Nothing was saved.
+	tkn := NFToken{NFTokenID: 1}
+	require.NoError(chain.GetNFToken(&tkn))
+	assert.Equal(adr0, tkn.Owner.Address())
+	tkn = NFToken{NFTokenID: 3}
+	assert.Error(chain.GetNFToken(&tkn))
+}
@@ -28,20 +28,37 @@ import (
 	jrpc "github.com/AdamSLevy/jsonrpc2/v11"
 	"github.com/jinzhu/gorm"
 
+	"github.com/Factom-Asset-Tokens/fatd/events"
 	"github.com/Factom-Asset-Tokens/fatd/factom"
 	"github.com/Factom-Asset-Tokens/fatd/fat"
 	"github.com/Factom-Asset-Tokens/fatd/fat/fat0"
 	"github.com/Factom-Asset-Tokens/fatd/fat/fat1"
+	"github.com/Factom-Asset-Tokens/fatd/flag"
 )
 
 func Process(eb factom.EBlock) error {
 	// Skip ignored chains or EBlocks for heights earlier than this chain's
 	// state.
 	chain := Chains.Get(eb.ChainID)
-	if chain.IsIgnored() || eb.Height <= chain.Metadata.Height {
+	if chain.IsIgnored() || chain.IsFaulted() ||
+		eb.Height <= chain.Metadata.Height {
 		return nil
 	}
-	return chain.Process(eb)
+	// Ignore chains that are not allowed by the chain filter. If the chain
+	// filter changes, ApplyChainFilter will find any whitelisted chains
+	// that were ignored so that they can be backfilled.
+	if !IsAllowed(eb.ChainID) {
+		chain.ignore()
+		Chains.set(eb.ChainID, &chain)
+		return nil
+	}
+	// A chain that fails to process is faulted, so that it does not stop
+	// other chains from syncing. It is retried later by RetryFaulted.
+	if err := chain.Process(eb); err != nil {
+		chain.fault(fmt.Errorf("EBlock{Height: %v, KeyMR: %v}: %v",
+			eb.Height, eb.KeyMR, err))
+	}
+	return nil
 }
 
 func (chain *Chain) Process(eb factom.EBlock) error {
@@ -57,7 +74,7 @@ func (chain *Chain) Process(eb factom.EBlock) error {
 	if eb.IsFirst() {
 		// Load first entry of new chain.
 		first := eb.Entries[0]
-		if err := first.Get(c); err != nil {
+		if err := getEntry(&eb, &first); err != nil {
 			return fmt.Errorf("%#v.Get(c): %v", first, err)
 		}
 
@@ -75,35 +92,47 @@ func (chain *Chain) Process(eb factom.EBlock) error {
 		if len(eb.Entries) == 1 {
 			return nil
 		}
-		// The first entry cannot be a valid Issuance entry, so discard
-		// it and process the rest.
-		eb.Entries = eb.Entries[1:]
+		// The first entry cannot be a valid Issuance entry, so skip it
+		// and process the rest.
+		return chain.process(eb, 1)
 	} else if !chain.IsTracked() {
 		// Ignore chains that are not already tracked.
 		chain.ignore()
 		return nil
 	}
 
-	return chain.process(eb)
+	return chain.process(eb, 0)
+}
+
+// getEntry populates e, and if -verifysync is set, verifies that e belongs in
+// eb.
+func getEntry(eb *factom.EBlock, e *factom.Entry) error {
+	if err := e.Get(c); err != nil {
+		return err
+	}
+	if flag.VerifySync {
+		return eb.VerifyEntry(*e)
+	}
+	return nil
 }
 
-func (chain *Chain) process(eb factom.EBlock) (err error) {
+// process the entries in eb starting at the given index.
+func (chain *Chain) process(eb factom.EBlock, start int) (err error) {
 	defer func() {
 		if err != nil {
 			return
 		}
 		chain.saveHeight(eb.Height)
 	}()
-	es := eb.Entries
 	if !chain.IsIssued() {
-		return chain.processIssuance(es)
+		return chain.processIssuance(&eb, start)
 	}
-	return chain.processTransactions(es)
+	return chain.processTransactions(&eb, start)
 }
 
 // In general the following checks are ordered from cheapest to most expensive
 // in terms of computation and memory.
-func (chain *Chain) processIssuance(es []factom.Entry) error {
+func (chain *Chain) processIssuance(eb *factom.EBlock, start int) error {
 	if !chain.Identity.IsPopulated() {
 		// The Identity may not have existed when this chain was first tracked.
 		// Attempt to retrieve it.
@@ -116,11 +145,12 @@ func (chain *Chain) processIssuance(es []factom.Entry) error {
 	}
 	// If these entries were created in a lower block height than the
 	// Identity entry, then none of them can be a valid Issuance entry.
-	if es[0].Height < chain.Identity.Height {
+	if eb.Height < chain.Identity.Height {
 		return nil
 	}
 
-	for i, e := range es {
+	for i, e := range eb.Entries[start:] {
+		i += start
 		// If this entry was created before the Identity entry then it
 		// can't be valid.
 		if e.Timestamp.Before(chain.Identity.Timestamp) {
@@ -129,7 +159,7 @@ func (chain *Chain) processIssuance(es []factom.Entry) error {
 			continue
 		}
 		// Get the data for the entry.
-		if err := e.Get(c); err != nil {
+		if err := getEntry(eb, &e); err != nil {
 			return fmt.Errorf("Entry%+v.Get(c): %v", e, err)
 		}
 		issuance := fat.NewIssuance(e)
@@ -138,198 +168,311 @@ func (chain *Chain) processIssuance(es []factom.Entry) error {
 			continue
 		}
 
-		if err := chain.issue(issuance); err != nil {
+		if err := chain.issue(issuance, eb, i); err != nil {
 			return err
 		}
+		events.Publish(events.Issuance{
+			ChainID:       chain.ID,
+			TokenID:       chain.Token,
+			IssuerChainID: chain.Issuer,
+			Hash:          issuance.Hash,
+			Timestamp:     issuance.Timestamp.Unix(),
+			Height:        eb.Height,
+			Issuance:      issuance,
+		})
 
 		// Process remaining entries as transactions
-		return chain.processTransactions(es[i+1:])
+		return chain.processTransactions(eb, i+1)
 	}
 	return nil
 }
 
-func (chain *Chain) processTransactions(es []factom.Entry) error {
-	for _, e := range es {
-		if err := e.Get(c); err != nil {
+func (chain *Chain) processTransactions(eb *factom.EBlock, start int) error {
+	for i, e := range eb.Entries[start:] {
+		i += start
+		if err := getEntry(eb, &e); err != nil {
 			return fmt.Errorf("Entry%v.Get(c): %v", e, err)
 		}
-		switch chain.Type {
-		case fat0.Type:
-			transaction := fat0.NewTransaction(e)
-			if err := transaction.Valid(chain.Identity.ID1); err != nil {
-				log.Debugf("Invalid Transaction Entry: %v, %v", e.Hash, err)
-				continue
-			}
-			if err := chain.applyFAT0(transaction); err != nil {
-				return err
-			}
-		case fat1.Type:
-			transaction := fat1.NewTransaction(e)
-			if err := transaction.Valid(chain.Identity.ID1); err != nil {
-				log.Debugf("Invalid Transaction Entry: %v, %v", e.Hash, err)
-				continue
-			}
-			if err := chain.applyFAT1(transaction); err != nil {
-				return err
+		err := chain.applyTransaction(e, eb, i)
+		if rej, ok := err.(rejection); ok {
+			log.Debugf("Invalid Transaction Entry: %v, %v", e.Hash, rej)
+			if err = chain.saveInvalidEntry(e, eb, i, rej); err == nil {
+				rejectedTransactionsTotal.Inc(
+					chain.ID.String(), string(rej.Code))
 			}
 		}
+		if err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
-func (chain *Chain) applyFAT0(transaction fat0.Transaction) (err error) {
+// applyTransaction validates and applies the transaction in e, which is the
+// entry at the given index in eb, and commits it. A rejection is returned if
+// the transaction is invalid.
+func (chain *Chain) applyTransaction(e factom.Entry,
+	eb *factom.EBlock, index int) (err error) {
 	db := chain.Begin()
 	defer chain.rollbackUnlessCommitted(*chain, &err)
 	chain.DB = db
 
-	entry, err := chain.createEntry(transaction.Entry.Entry)
+	evt, err := chain.apply(e, eb, index)
 	if err != nil {
 		return err
 	}
+	return chain.commitTransaction(evt)
+}
+
+// apply validates and applies the transaction in e, which is the entry at the
+// given index in eb, to chain.DB, which must be a database transaction. The
+// caller is responsible for committing or rolling back chain.DB.
+func (chain *Chain) apply(e factom.Entry,
+	eb *factom.EBlock, index int) (events.Transaction, error) {
+	switch chain.Type {
+	case fat0.Type:
+		transaction := fat0.NewTransaction(e)
+		if err := chain.validTransaction(&transaction); err != nil {
+			return events.Transaction{}, err
+		}
+		return chain.applyFAT0(transaction, eb, index)
+	case fat1.Type:
+		transaction := fat1.NewTransaction(e)
+		if err := chain.validTransaction(&transaction); err != nil {
+			return events.Transaction{}, err
+		}
+		return chain.applyFAT1(transaction, eb, index)
+	}
+	return events.Transaction{}, fmt.Errorf("invalid token type: %v",
+		chain.Type)
+}
+
+// validTransaction unmarshals and validates tx. If tx is invalid, a rejection
+// with the code that matches the reason is returned.
+func (chain Chain) validTransaction(tx transaction) error {
+	if err := tx.UnmarshalEntry(); err != nil {
+		return reject(RejectionInvalidData, "%v", err)
+	}
+	return chain.validSignatures(tx)
+}
+
+// validSignatures validates the RCD/signature pairs of tx, which must already
+// be unmarshaled.
+func (chain Chain) validSignatures(tx transaction) error {
+	if tx.IsCoinbase() && !chain.Identity.IsPopulated() {
+		return reject(RejectionNoID1Key, "issuer has no ID1Key")
+	}
+	// The data was already validated by UnmarshalEntry, so any remaining
+	// errors are from the RCD/signature pairs.
+	if err := tx.Valid(chain.ID1); err != nil {
+		return reject(RejectionInvalidSignature, "%v", err)
+	}
+	return nil
+}
+
+// transaction is implemented by *fat0.Transaction and *fat1.Transaction.
+type transaction interface {
+	UnmarshalEntry() error
+	IsCoinbase() bool
+	Valid(factom.IDKey) error
+}
+
+// TransactionHooks are called, in order, with each valid transaction after it
+// is committed. If a hook returns an error, processing stops and the chain is
+// faulted, so the transaction is rewound and the hooks are called with it
+// again when the chain recovers. The chain's height is only saved after the
+// hooks have been called for every transaction in the EBlock, so if fatd stops
+// in between, the EBlock is rewound when the chain is loaded and the hooks are
+// called again. Hooks must therefore be idempotent. Hooks may be called
+// concurrently for different chains.
+var TransactionHooks []func(events.Transaction) error
+
+// commitTransaction commits the database transaction for evt, and then runs
+// the TransactionHooks and publishes evt.
+func (chain *Chain) commitTransaction(evt events.Transaction) error {
+	if err := chain.Commit().Error; err != nil {
+		return err
+	}
+	for _, hook := range TransactionHooks {
+		if err := hook(evt); err != nil {
+			return err
+		}
+	}
+	transactionsTotal.Inc(chain.ID.String())
+	events.Publish(evt)
+	return nil
+}
+
+// newTransactionEvent returns the events.Transaction for the transaction tx in
+// e, which has the given input and output addresses and NFTokens.
+func (chain *Chain) newTransactionEvent(e factom.Entry, tx interface{},
+	adrs []factom.FAAddress, tkns fat1.NFTokens) events.Transaction {
+	return events.Transaction{
+		ChainID:   chain.ID,
+		Hash:      e.Hash,
+		Timestamp: e.Timestamp.Unix(),
+		Height:    e.Height,
+		Tx:        tx,
+		Addresses: adrs,
+		NFTokens:  tkns,
+	}
+}
+
+// applyFAT0 applies the valid transaction, which is the entry at the given
+// index in eb, to chain.DB, which must be a database transaction. A rejection
+// is returned if the transaction cannot be applied to the current state.
+func (chain *Chain) applyFAT0(transaction fat0.Transaction,
+	eb *factom.EBlock, index int) (events.Transaction, error) {
+	var evt events.Transaction
+	entry, err := chain.createEntry(transaction.Entry.Entry, eb, index)
+	if err != nil {
+		return evt, err
+	}
 	if entry == nil {
-		// replayed transaction
-		log.Debugf("Invalid Transaction Entry: %v, replayed transaction",
-			transaction.Hash)
-		return nil
+		return evt, reject(RejectionReplay, "replayed transaction")
 	}
 
 	for rcdHash, amount := range transaction.Inputs {
 		adr, err := chain.GetAddress(&rcdHash)
 		if err != nil {
-			return err
+			return evt, err
 		}
 		if err := chain.DB.Model(&adr).Association("From").
 			Append(entry).Error; err != nil {
-			return err
+			return evt, err
 		}
 		if transaction.IsCoinbase() {
 			if chain.Supply > 0 &&
 				uint64(chain.Supply)-chain.Issued < amount {
-				// insufficient coinbase supply
-				log.Debugf("Invalid Transaction Entry: %v, "+
-					"insufficient coinbase supply",
-					entry.Hash)
-				return nil
+				return evt, reject(RejectionInsufficientSupply,
+					"insufficient coinbase supply")
 			}
 			chain.Issued += amount
 			if err := chain.saveMetadata(); err != nil {
-				return err
+				return evt, err
 			}
 			break
 		}
 		if adr.Balance < amount {
-			// insufficient balance
-			log.Debugf("Invalid Transaction Entry: %v, "+
-				"insufficient balance: %v",
-				entry.Hash, adr.Address())
-			return nil
+			return evt, reject(RejectionInsufficientBalance,
+				"insufficient balance: %v", adr.Address())
 		}
 		adr.Balance -= amount
 		if err := chain.Save(&adr).Error; err != nil {
-			return err
+			return evt, err
+		}
+		if err := chain.saveBalanceChange(adr, entry,
+			-int64(amount)); err != nil {
+			return evt, err
 		}
 	}
 
 	for rcdHash, amount := range transaction.Outputs {
 		a, err := chain.GetAddress(&rcdHash)
 		if err != nil {
-			return err
+			return evt, err
 		}
 		a.Balance += amount
 		if err := chain.Save(&a).Error; err != nil {
-			return err
+			return evt, err
+		}
+		if err := chain.saveBalanceChange(a, entry,
+			int64(amount)); err != nil {
+			return evt, err
 		}
 		if err := chain.DB.Model(&a).Association("To").
 			Append(entry).Error; err != nil {
-			return err
+			return evt, err
 		}
 	}
 	log.Debugf("Valid Transaction Entry: %+v", transaction)
 
-	return chain.Commit().Error
+	adrs := make([]factom.FAAddress, 0,
+		len(transaction.Inputs)+len(transaction.Outputs))
+	for rcdHash := range transaction.Inputs {
+		adrs = append(adrs, rcdHash)
+	}
+	for rcdHash := range transaction.Outputs {
+		adrs = append(adrs, rcdHash)
+	}
+	return chain.newTransactionEvent(transaction.Entry.Entry, transaction,
+		adrs, nil), nil
 }
 
-func (chain *Chain) applyFAT1(transaction fat1.Transaction) (err error) {
-	db := chain.Begin()
-	defer chain.rollbackUnlessCommitted(*chain, &err)
-	chain.DB = db
-
-	entry, err := chain.createEntry(transaction.Entry.Entry)
+// applyFAT1 applies the valid transaction, which is the entry at the given
+// index in eb, to chain.DB, which must be a database transaction. A rejection
+// is returned if the transaction cannot be applied to the current state.
+func (chain *Chain) applyFAT1(transaction fat1.Transaction,
+	eb *factom.EBlock, index int) (events.Transaction, error) {
+	var evt events.Transaction
+	entry, err := chain.createEntry(transaction.Entry.Entry, eb, index)
 	if err != nil {
-		return err
+		return evt, err
 	}
 	if entry == nil {
-		// replayed transaction
-		log.Debugf("Invalid Transaction Entry: %v, replayed transaction",
-			transaction.Hash)
-		return nil
+		return evt, reject(RejectionReplay, "replayed transaction")
 	}
 
 	allTkns := make(map[fat1.NFTokenID]NFToken, transaction.Inputs.NumNFTokenIDs())
 	for rcdHash, tkns := range transaction.Inputs {
 		adr, err := chain.GetAddress(&rcdHash)
 		if err != nil {
-			return err
+			return evt, err
 		}
 		if err := chain.DB.Model(&adr).Association("From").
 			Append(entry).Error; err != nil {
-			return err
+			return evt, err
 		}
 		if transaction.IsCoinbase() {
 			if chain.Supply > 0 &&
 				uint64(chain.Supply)-chain.Issued < uint64(len(tkns)) {
-				// insufficient coinbase supply
-				log.Debugf("Invalid Transaction Entry: %v, "+
-					"insufficient coinbase supply",
-					entry.Hash)
-				return nil
+				return evt, reject(RejectionInsufficientSupply,
+					"insufficient coinbase supply")
 			}
 			chain.Issued += uint64(len(tkns))
 			if err := chain.saveMetadata(); err != nil {
-				return err
+				return evt, err
 			}
 			for tknID := range tkns {
 				tkn, err := chain.createNFToken(tknID,
 					transaction.TokenMetadata[tknID])
 				if err != nil {
-					return err
+					return evt, err
 				}
 				if tkn == nil {
-					log.Debugf("Invalid Transaction Entry: %v, "+
-						"NFTokenID(%v) already exists",
-						entry.Hash, tknID)
-					return nil
+					return evt, reject(RejectionNFTokenExists,
+						"NFTokenID(%v) already exists", tknID)
 				}
 				allTkns[tknID] = *tkn
 			}
 			break
 		}
 		if adr.Balance < uint64(len(tkns)) {
-			// insufficient balance
-			log.Debugf("Invalid Transaction Entry: %v, "+
-				"insufficient balance: %v",
-				entry.Hash, adr.Address())
-			return nil
+			return evt, reject(RejectionInsufficientBalance,
+				"insufficient balance: %v", adr.Address())
 		}
 		adr.Balance -= uint64(len(tkns))
 		if err := chain.Save(&adr).Error; err != nil {
-			return err
+			return evt, err
+		}
+		if err := chain.saveBalanceChange(adr, entry,
+			-int64(len(tkns))); err != nil {
+			return evt, err
 		}
 		for tknID := range tkns {
 			tkn := NFToken{NFTokenID: tknID, OwnerID: adr.ID}
 			err := chain.GetNFToken(&tkn)
 			if err == gorm.ErrRecordNotFound {
-				log.Debugf("Invalid Transaction Entry: %v, "+
+				return evt, reject(RejectionNFTokenNotOwned,
 					"NFTokenID(%v) is not owned by %v",
-					entry.Hash, tknID, rcdHash)
-				return nil
+					tknID, rcdHash)
 			}
 			if err != nil {
-				return err
+				return evt, err
 			}
 			if err := chain.DB.Model(&tkn).Association("PreviousOwners").
 				Append(&adr).Error; err != nil {
-				return err
+				return evt, err
 			}
 			allTkns[tknID] = tkn
 		}
@@ -338,30 +481,47 @@ func (chain *Chain) applyFAT1(transaction fat1.Transaction) (err error) {
 	for rcdHash, tkns := range transaction.Outputs {
 		a, err := chain.GetAddress(&rcdHash)
 		if err != nil {
-			return err
+			return evt, err
 		}
 		a.Balance += uint64(len(tkns))
 		if err := chain.Save(&a).Error; err != nil {
-			return err
+			return evt, err
+		}
+		if err := chain.saveBalanceChange(a, entry,
+			int64(len(tkns))); err != nil {
+			return evt, err
 		}
 		if err := chain.DB.Model(&a).Association("To").
 			Append(entry).Error; err != nil {
-			return err
+			return evt, err
 		}
 		for tknID := range tkns {
 			tkn := allTkns[tknID]
 			tkn.Owner = a
 			tkn.OwnerID = a.ID
 			if err := chain.Save(&tkn).Error; err != nil {
-				return err
+				return evt, err
 			}
 			if err := chain.DB.Model(&tkn).Association("Transactions").
 				Append(entry).Error; err != nil {
-				return err
+				return evt, err
+			}
+			if err := chain.saveNFTokenOwnerChange(tknID, a,
+				entry); err != nil {
+				return evt, err
 			}
 		}
 	}
 	log.Debugf("Valid Transaction Entry: %T%+v", transaction, transaction)
 
-	return chain.Commit().Error
+	adrs := make([]factom.FAAddress, 0,
+		len(transaction.Inputs)+len(transaction.Outputs))
+	for rcdHash := range transaction.Inputs {
+		adrs = append(adrs, rcdHash)
+	}
+	for rcdHash := range transaction.Outputs {
+		adrs = append(adrs, rcdHash)
+	}
+	return chain.newTransactionEvent(transaction.Entry.Entry, transaction,
+		adrs, transaction.Inputs.AllNFTokens()), nil
 }
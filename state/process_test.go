@@ -0,0 +1,172 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package state
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Factom-Asset-Tokens/fatd/events"
+	"github.com/Factom-Asset-Tokens/fatd/factom"
+	"github.com/Factom-Asset-Tokens/fatd/fat"
+	"github.com/Factom-Asset-Tokens/fatd/fat/fat0"
+)
+
+func TestProcessTransactionsRejections(t *testing.T) {
+	defer setupTestDBPath(t)()
+	require := require.New(t)
+	chain, adrs := newTestFAT0Chain(t)
+	defer chain.Close()
+
+	es, err := chain.GetEntries(nil, nil, nil, nil, nil, "", "", 0, 0)
+	require.NoError(err)
+	require.Len(es, 2)
+	replay := es[1].Entry
+
+	overspend := fat0.NewTransaction(factom.Entry{ChainID: chain.ID})
+	overspend.Inputs = fat0.AddressAmountMap{adrs[1].FAAddress(): 41}
+	overspend.Outputs = fat0.AddressAmountMap{adrs[0].FAAddress(): 41}
+	require.NoError(overspend.MarshalEntry())
+	overspend.Sign(adrs[1])
+
+	unsigned := fat0.NewTransaction(factom.Entry{ChainID: chain.ID})
+	unsigned.Inputs = fat0.AddressAmountMap{adrs[1].FAAddress(): 1}
+	unsigned.Outputs = fat0.AddressAmountMap{adrs[0].FAAddress(): 1}
+	require.NoError(unsigned.MarshalEntry())
+	unsigned.ExtIDs = []factom.Bytes{}
+
+	garbage := factom.Entry{ChainID: chain.ID,
+		ExtIDs: []factom.Bytes{}, Content: factom.Bytes("garbage")}
+
+	unbalanced := factom.Entry{ChainID: chain.ID, ExtIDs: []factom.Bytes{},
+		Content: factom.Bytes(fmt.Sprintf(
+			`{"inputs":{%q:1},"outputs":{%q:2}}`,
+			adrs[1].FAAddress(), adrs[0].FAAddress()))}
+
+	forger, err := factom.GenerateSK1Key()
+	require.NoError(err)
+	forged := fat0.NewTransaction(factom.Entry{ChainID: chain.ID})
+	forged.Inputs = fat0.AddressAmountMap{fat.Coinbase(): 1}
+	forged.Outputs = fat0.AddressAmountMap{adrs[0].FAAddress(): 1}
+	require.NoError(forged.MarshalEntry())
+	forged.Sign(forger)
+
+	eb := factom.EBlock{KeyMR: factom.NewBytes32(make([]byte, 32)),
+		Height: 30, Sequence: 2,
+		Entries: []factom.Entry{replay, overspend.Entry.Entry,
+			unsigned.Entry.Entry, garbage, unbalanced,
+			forged.Entry.Entry}}
+	for i := range eb.Entries {
+		e := &eb.Entries[i]
+		hash, err := e.ComputeHash()
+		require.NoError(err)
+		e.Hash = &hash
+		e.Height = eb.Height
+	}
+	// Pending transactions are rejected for the same reasons.
+	rejs, err := chain.applyPending(eb.Entries)
+	require.NoError(err)
+
+	require.NoError(chain.processTransactions(&eb, 0))
+
+	for i, code := range []RejectionCode{
+		RejectionReplay,
+		RejectionInsufficientBalance,
+		RejectionInvalidSignature,
+		RejectionInvalidData,
+		RejectionInvalidData,
+		RejectionInvalidSignature,
+	} {
+		invalid, err := chain.GetInvalidEntries(eb.Entries[i].Hash)
+		require.NoError(err)
+		if i == 0 {
+			// The original transaction is still valid.
+			_, err := chain.GetEntry(replay.Hash)
+			assert.NoError(t, err)
+		}
+		require.Len(invalid, 1, code)
+		assert.Equal(t, code, invalid[0].Code)
+		require.NotNil(rejs[i], code)
+		assert.Equal(t, code, rejs[i].Code)
+		assert.NotEmpty(t, invalid[0].Reason)
+		assert.Equal(t, uint32(30), invalid[0].Height)
+		assert.Equal(t, uint32(i), invalid[0].EntryIndex)
+	}
+
+	// The rejected overspend must not have changed any balances.
+	rcdHash := adrs[1].FAAddress()
+	adr, err := chain.GetAddress(&rcdHash)
+	require.NoError(err)
+	assert.Equal(t, uint64(40), adr.Balance)
+}
+
+func TestTransactionHooks(t *testing.T) {
+	defer setupTestDBPath(t)()
+	require := require.New(t)
+	chain, adrs := newTestFAT0Chain(t)
+	defer chain.Close()
+
+	saved := *chain
+	var hashes []factom.Bytes32
+	TransactionHooks = []func(events.Transaction) error{
+		func(evt events.Transaction) error {
+			// The transaction is committed before the hooks run.
+			ok, err := saved.isSaved(evt.Hash)
+			require.NoError(err)
+			require.True(ok)
+			hashes = append(hashes, *evt.Hash)
+			return nil
+		}}
+	defer func() { TransactionHooks = nil }()
+
+	overspend := fat0.NewTransaction(factom.Entry{ChainID: chain.ID})
+	overspend.Inputs = fat0.AddressAmountMap{adrs[1].FAAddress(): 41}
+	overspend.Outputs = fat0.AddressAmountMap{adrs[0].FAAddress(): 41}
+	require.NoError(overspend.MarshalEntry())
+	overspend.Sign(adrs[1])
+
+	spend := fat0.NewTransaction(factom.Entry{ChainID: chain.ID})
+	spend.Inputs = fat0.AddressAmountMap{adrs[1].FAAddress(): 40}
+	spend.Outputs = fat0.AddressAmountMap{adrs[0].FAAddress(): 40}
+	require.NoError(spend.MarshalEntry())
+	spend.Sign(adrs[1])
+
+	eb := factom.EBlock{KeyMR: factom.NewBytes32(make([]byte, 32)),
+		Height: 30, Sequence: 2,
+		Entries: []factom.Entry{overspend.Entry.Entry,
+			spend.Entry.Entry}}
+	for i := range eb.Entries {
+		e := &eb.Entries[i]
+		hash, err := e.ComputeHash()
+		require.NoError(err)
+		e.Hash = &hash
+		e.Height = eb.Height
+	}
+	require.NoError(chain.processTransactions(&eb, 0))
+
+	// Only the valid transaction is passed to the hooks.
+	assert.Equal(t, []factom.Bytes32{*eb.Entries[1].Hash}, hashes)
+}
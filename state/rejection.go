@@ -0,0 +1,78 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package state
+
+import "fmt"
+
+// RejectionCode is a machine readable reason for why an entry on a token chain
+// was rejected as a transaction.
+type RejectionCode string
+
+const (
+	// RejectionInvalidData is used when the entry content is not a valid
+	// transaction.
+	RejectionInvalidData RejectionCode = "invalid-data"
+	// RejectionInvalidSignature is used when the RCD/signature pairs in
+	// the ExtIDs are malformed, expired, or do not match the inputs.
+	RejectionInvalidSignature RejectionCode = "invalid-signature"
+	// RejectionReplay is used when the entry has the same hash as a
+	// previously applied transaction.
+	RejectionReplay RejectionCode = "replay"
+	// RejectionInsufficientBalance is used when an input address does not
+	// have a sufficient balance.
+	RejectionInsufficientBalance RejectionCode = "insufficient-balance"
+	// RejectionInsufficientSupply is used when a coinbase transaction
+	// exceeds the remaining supply.
+	RejectionInsufficientSupply RejectionCode = "insufficient-supply"
+	// RejectionNFTokenExists is used when a coinbase transaction issues an
+	// NFTokenID that already exists.
+	RejectionNFTokenExists RejectionCode = "nf-token-exists"
+	// RejectionNFTokenNotOwned is used when an input address does not own
+	// an input NFTokenID.
+	RejectionNFTokenNotOwned RejectionCode = "nf-token-not-owned"
+	// RejectionNoID1Key is used when a coinbase transaction cannot be
+	// validated because the issuer's ID1Key is not known.
+	RejectionNoID1Key RejectionCode = "no-id1-key"
+)
+
+// rejection is returned by applyFAT0 and applyFAT1 for invalid transactions so
+// that the database transaction is rolled back before the rejection is saved.
+type rejection struct {
+	Code   RejectionCode
+	Reason string
+}
+
+func reject(code RejectionCode, format string, a ...interface{}) rejection {
+	return rejection{Code: code, Reason: fmt.Sprintf(format, a...)}
+}
+
+func (r rejection) Error() string {
+	return fmt.Sprintf("%v: %v", r.Code, r.Reason)
+}
+
+// RejectionReason returns the reason that a transaction was rejected if err was
+// returned for a rejected transaction. Otherwise, ok is false.
+func RejectionReason(err error) (reason string, ok bool) {
+	rej, ok := err.(rejection)
+	return rej.Reason, ok
+}
@@ -0,0 +1,160 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package state
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Factom-Asset-Tokens/fatd/factom"
+	"github.com/Factom-Asset-Tokens/fatd/flag"
+)
+
+// Rewind removes everything saved for the tracked chain with the given id
+// after height, so that it can be resynced from height using Backfill. A
+// faulted chain is first reopened from its database, as when it is retried,
+// and is no longer faulted.
+//
+// A chain can only be rewound exactly if it was issued at or before height and
+// its full history is known. Otherwise its database is deleted and the chain is
+// no longer tracked, so that Backfill resyncs it from its first EBlock. Rewind
+// must not be called concurrently with Process.
+func Rewind(id factom.Bytes32, height uint32) error {
+	chain := Chains.Get(&id)
+	if chain.IsFaulted() {
+		var err error
+		if chain, err = reopenFaulted(id); err != nil {
+			return err
+		}
+		if !chain.IsTracked() {
+			// Backfill syncs the chain from its first EBlock.
+			return nil
+		}
+	}
+	if !chain.IsTracked() {
+		return fmt.Errorf("ChainID(%v): not tracked", id)
+	}
+	if height >= chain.Metadata.Height {
+		return nil
+	}
+	if !chain.IsIssued() ||
+		height < chain.Issuance.Height ||
+		chain.HistoryHeight > 0 {
+		return chain.remove()
+	}
+	if err := chain.rewind(height); err != nil {
+		return err
+	}
+	Chains.set(chain.ID, &chain)
+	return nil
+}
+
+// remove closes and deletes the database of the chain and forgets the chain.
+func (chain *Chain) remove() error {
+	if err := chain.Close(); err != nil {
+		return err
+	}
+	Chains.Lock()
+	delete(Chains.m, *chain.ID)
+	for i := range Chains.ids {
+		if Chains.ids[i] == *chain.ID {
+			Chains.ids = append(Chains.ids[:i:i], Chains.ids[i+1:]...)
+			break
+		}
+	}
+	Chains.Unlock()
+	fname := fmt.Sprintf("%v/%v%v", flag.DBPath, chain.ID, dbFileExtension)
+	if err := os.Remove(fname); err != nil {
+		return err
+	}
+	log.Infof("ChainID(%v): database removed", chain.ID)
+	return nil
+}
+
+// rewind deletes all entries, invalid entries and history after height, and
+// then replays the remaining transactions to restore the balances, NF token
+// owners and issued supply as of height.
+func (chain *Chain) rewind(height uint32) error {
+	if err := chain.deleteAfter(height); err != nil {
+		return err
+	}
+	// Any balances, NF token owners and the issued supply that do not
+	// match the remaining transactions are repaired.
+	replay, mismatches, err := chain.validateState()
+	if err != nil {
+		return err
+	}
+	if len(mismatches) > 0 {
+		if err := chain.repairState(replay); err != nil {
+			return err
+		}
+	}
+	log.Infof("ChainID(%v): rewound to height %v", chain.ID, height)
+	return nil
+}
+
+// deleteAfter deletes all entries, invalid entries and history after height in
+// a single database transaction, and saves height as the chain height.
+func (chain *Chain) deleteAfter(height uint32) (err error) {
+	db := chain.Begin()
+	defer chain.rollbackUnlessCommitted(*chain, &err)
+	chain.DB = db
+
+	const after = "SELECT id FROM entries WHERE height > ?"
+	for _, qry := range []string{
+		"DELETE FROM address_transactions_to WHERE entry_id IN (" + after + ");",
+		"DELETE FROM address_transactions_from WHERE entry_id IN (" + after + ");",
+		"DELETE FROM nf_token_transactions WHERE entry_id IN (" + after + ");",
+		"DELETE FROM balance_changes WHERE height > ?;",
+		"DELETE FROM nf_token_owner_changes WHERE height > ?;",
+		"DELETE FROM invalid_entries WHERE height > ?;",
+		"DELETE FROM entries WHERE height > ?;",
+	} {
+		if err := chain.Exec(qry, height).Error; err != nil {
+			return fmt.Errorf("%#v: %v", qry, err)
+		}
+	}
+
+	// Every owner of an NF token that was replaced by a later owner is a
+	// previous owner.
+	for _, qry := range []string{
+		"DELETE FROM nf_token_previousowners;",
+		`INSERT OR IGNORE INTO nf_token_previousowners (nf_token_id, address_id)
+			SELECT nf_tokens.id, changes.owner_id
+			FROM nf_token_owner_changes AS changes
+			JOIN nf_tokens ON nf_tokens.nf_token_id = changes.nf_token_id
+			WHERE EXISTS (SELECT 1 FROM nf_token_owner_changes AS later
+				WHERE later.nf_token_id = changes.nf_token_id
+				AND later.id > changes.id);`,
+	} {
+		if err := chain.Exec(qry).Error; err != nil {
+			return fmt.Errorf("%#v: %v", qry, err)
+		}
+	}
+
+	chain.Metadata.Height = height
+	if err := chain.saveMetadata(); err != nil {
+		return err
+	}
+	return chain.Commit().Error
+}
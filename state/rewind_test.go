@@ -0,0 +1,88 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Factom-Asset-Tokens/fatd/flag"
+)
+
+func TestRewind(t *testing.T) {
+	defer setupTestDBPath(t)()
+	assert := assert.New(t)
+	require := require.New(t)
+	chain, adrs := newTestFAT0Chain(t)
+	require.NoError(chain.saveHeight(25))
+	Chains.set(chain.ID, chain)
+	defer func() {
+		Chains.Lock()
+		delete(Chains.m, *chain.ID)
+		Chains.ids = nil
+		Chains.Unlock()
+		chain.Close()
+	}()
+
+	// Rewinding to the current height or later does nothing.
+	require.NoError(Rewind(*chain.ID, 25))
+	assert.Equal(uint32(25), Chains.Get(chain.ID).Metadata.Height)
+
+	// Rewinding to before the transaction at height 20 removes it.
+	require.NoError(Rewind(*chain.ID, 15))
+	rewound := Chains.Get(chain.ID)
+	assert.Equal(uint32(15), rewound.Metadata.Height)
+	assert.Equal(uint64(100), rewound.Issued)
+	for i, balance := range []uint64{100, 0} {
+		rcdHash := adrs[i].FAAddress()
+		adr, err := rewound.GetAddress(&rcdHash)
+		require.NoError(err)
+		assert.Equal(balance, adr.Balance)
+	}
+	var count int
+	require.NoError(rewound.DB.Model(&entry{}).Count(&count).Error)
+	assert.Equal(2, count)
+	require.NoError(rewound.DB.Model(&BalanceChange{}).
+		Where("height > 15").Count(&count).Error)
+	assert.Equal(0, count)
+	mismatches, err := rewound.Validate(false)
+	require.NoError(err)
+	assert.Empty(mismatches)
+
+	// Rewinding to before the issuance removes the database.
+	fname := filepath.Join(flag.DBPath, chain.ID.String()+dbFileExtension)
+	_, err = os.Stat(fname)
+	require.NoError(err)
+	require.NoError(Rewind(*chain.ID, 5))
+	assert.True(Chains.Get(chain.ID).IsUnknown())
+	assert.NotContains(Chains.GetIssued(), *chain.ID)
+	_, err = os.Stat(fname)
+	assert.True(os.IsNotExist(err))
+
+	assert.EqualError(Rewind(*chain.ID, 5),
+		"ChainID("+chain.ID.String()+"): not tracked")
+}
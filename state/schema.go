@@ -39,6 +39,16 @@ type Metadata struct {
 	Issuer *factom.Bytes32
 
 	Issued uint64
+
+	// HistoryHeight and HistoryTimestamp are the earliest DBlock height
+	// and time for which historical balances are available. Both are zero
+	// if the full history of the chain is available.
+	HistoryHeight    uint32
+	HistoryTimestamp time.Time
+
+	// NetworkID is the Factom network that the chain was synced from. It
+	// is zero for databases created before it was recorded.
+	NetworkID factom.NetworkID
 }
 
 type entry struct {
@@ -46,14 +56,27 @@ type entry struct {
 	Hash      *factom.Bytes32 `gorm:"type:VARCHAR(32); UNIQUE_INDEX; NOT NULL;"`
 	Timestamp time.Time       `gorm:"NOT NULL;"`
 	Data      factom.Bytes    `gorm:"NOT NULL;"`
+	Height    uint32          `gorm:"INDEX; NOT NULL; DEFAULT:0;"`
+
+	// The KeyMR and Sequence of the EBlock that confirmed the entry, and
+	// the index of the entry within that EBlock.
+	EBlockKeyMR    *factom.Bytes32 `gorm:"type:VARCHAR(32);"`
+	EBlockSequence uint32          `gorm:"NOT NULL; DEFAULT:0;"`
+	EntryIndex     uint32          `gorm:"NOT NULL; DEFAULT:0;"`
 }
 
-func newEntry(e factom.Entry) entry {
+// newEntry returns the entry for e, which is the entry at the given index in
+// eb.
+func newEntry(e factom.Entry, eb *factom.EBlock, index int) entry {
 	b, _ := e.MarshalBinary()
 	return entry{
-		Hash:      e.Hash,
-		Timestamp: e.Timestamp,
-		Data:      b,
+		Hash:           e.Hash,
+		Timestamp:      e.Timestamp,
+		Data:           b,
+		Height:         e.Height,
+		EBlockKeyMR:    eb.KeyMR,
+		EBlockSequence: eb.Sequence,
+		EntryIndex:     uint32(index),
 	}
 }
 
@@ -62,11 +85,30 @@ func (e entry) IsValid() bool {
 }
 
 func (e entry) Entry() factom.Entry {
-	fe := factom.Entry{Hash: e.Hash, Timestamp: e.Timestamp}
+	fe := factom.Entry{Hash: e.Hash, Timestamp: e.Timestamp, Height: e.Height}
 	fe.UnmarshalBinary(e.Data)
 	return fe
 }
 
+// SavedEntry is a saved factom.Entry along with the location in the blockchain
+// where it was confirmed. EBlockKeyMR is nil if the entry was saved before
+// locations were recorded.
+type SavedEntry struct {
+	factom.Entry
+	EBlockKeyMR    *factom.Bytes32
+	EBlockSequence uint32
+	EntryIndex     uint32
+}
+
+func (e entry) SavedEntry() SavedEntry {
+	return SavedEntry{
+		Entry:          e.Entry(),
+		EBlockKeyMR:    e.EBlockKeyMR,
+		EBlockSequence: e.EBlockSequence,
+		EntryIndex:     e.EntryIndex,
+	}
+}
+
 type Address struct {
 	gorm.Model
 	RCDHash *factom.FAAddress `gorm:"type:varchar(32); UNIQUE_INDEX; NOT NULL;"`
@@ -95,3 +137,72 @@ type NFToken struct {
 	PreviousOwners []Address `gorm:"many2many:nf_token_previousowners;"`
 	Transactions   []entry   `gorm:"many2many:nf_token_transactions;"`
 }
+
+// BalanceChange records the change in the balance of an address due to a
+// single valid transaction entry. The balance of an address as of a given
+// height or time is the sum of all prior changes.
+type BalanceChange struct {
+	ID        uint64
+	AddressID uint      `gorm:"INDEX; NOT NULL;"`
+	EntryID   uint64    `gorm:"NOT NULL;"`
+	Height    uint32    `gorm:"INDEX; NOT NULL;"`
+	Timestamp time.Time `gorm:"NOT NULL;"`
+	Delta     int64     `gorm:"NOT NULL;"`
+}
+
+// NFTokenOwnerChange records the new owner of an NFToken due to a single valid
+// transaction entry.
+type NFTokenOwnerChange struct {
+	ID        uint64
+	NFTokenID fat1.NFTokenID `gorm:"INDEX; NOT NULL;"`
+	OwnerID   uint           `gorm:"INDEX; NOT NULL;"`
+	EntryID   uint64         `gorm:"NOT NULL;"`
+	Height    uint32         `gorm:"INDEX; NOT NULL;"`
+	Timestamp time.Time      `gorm:"NOT NULL;"`
+}
+
+// InvalidEntry is an entry on a token chain that was rejected as a
+// transaction. The same entry may be rejected more than once if it is
+// replayed.
+type InvalidEntry struct {
+	ID        uint64
+	Hash      *factom.Bytes32 `gorm:"type:VARCHAR(32); INDEX; NOT NULL;"`
+	Timestamp time.Time       `gorm:"NOT NULL;"`
+	Data      factom.Bytes    `gorm:"NOT NULL;"`
+	Height    uint32          `gorm:"NOT NULL;"`
+
+	EBlockKeyMR    *factom.Bytes32 `gorm:"type:VARCHAR(32);"`
+	EBlockSequence uint32          `gorm:"NOT NULL;"`
+	EntryIndex     uint32          `gorm:"NOT NULL;"`
+
+	Code   RejectionCode `gorm:"NOT NULL;"`
+	Reason string
+}
+
+func newInvalidEntry(e factom.Entry, eb *factom.EBlock, index int,
+	rej rejection) InvalidEntry {
+	valid := newEntry(e, eb, index)
+	return InvalidEntry{
+		Hash:           valid.Hash,
+		Timestamp:      valid.Timestamp,
+		Data:           valid.Data,
+		Height:         valid.Height,
+		EBlockKeyMR:    valid.EBlockKeyMR,
+		EBlockSequence: valid.EBlockSequence,
+		EntryIndex:     valid.EntryIndex,
+		Code:           rej.Code,
+		Reason:         rej.Reason,
+	}
+}
+
+func (e InvalidEntry) SavedEntry() SavedEntry {
+	return entry{
+		Hash:           e.Hash,
+		Timestamp:      e.Timestamp,
+		Data:           e.Data,
+		Height:         e.Height,
+		EBlockKeyMR:    e.EBlockKeyMR,
+		EBlockSequence: e.EBlockSequence,
+		EntryIndex:     e.EntryIndex,
+	}.SavedEntry()
+}
@@ -0,0 +1,41 @@
+-- A chain database created by fatd at schema version 3, prior to the
+-- introduction of invalid entries. It was migrated from version 1 at height
+-- 170000, so that is the earliest height with balance history.
+PRAGMA user_version = 3;
+CREATE TABLE "entries" ("id" integer primary key autoincrement,"hash" VARCHAR(32) NOT NULL,"timestamp" datetime NOT NULL,"data" blob NOT NULL,"height" integer NOT NULL  DEFAULT 0,"e_block_key_mr" VARCHAR(32),"e_block_sequence" integer NOT NULL  DEFAULT 0,"entry_index" integer NOT NULL  DEFAULT 0 );
+CREATE INDEX idx_entries_height ON "entries"("height") ;
+CREATE UNIQUE INDEX uix_entries_hash ON "entries"("hash") ;
+CREATE TABLE "address_transactions_to" ("address_id" integer,"entry_id" bigint, PRIMARY KEY ("address_id","entry_id"));
+CREATE TABLE "address_transactions_from" ("address_id" integer,"entry_id" bigint, PRIMARY KEY ("address_id","entry_id"));
+CREATE TABLE "addresses" ("id" integer primary key autoincrement,"created_at" datetime,"updated_at" datetime,"deleted_at" datetime,"rcd_hash" varchar(32) NOT NULL,"balance" bigint NOT NULL );
+CREATE INDEX idx_addresses_deleted_at ON "addresses"(deleted_at) ;
+CREATE UNIQUE INDEX uix_addresses_rcd_hash ON "addresses"(rcd_hash) ;
+CREATE TABLE "metadata" ("id" integer primary key autoincrement,"created_at" datetime,"updated_at" datetime,"deleted_at" datetime,"height" integer,"token" varchar(255),"issuer" blob,"issued" bigint,"history_height" integer,"history_timestamp" datetime );
+CREATE INDEX idx_metadata_deleted_at ON "metadata"(deleted_at) ;
+CREATE TABLE "nf_token_previousowners" ("nf_token_id" integer,"address_id" integer, PRIMARY KEY ("nf_token_id","address_id"));
+CREATE TABLE "nf_token_transactions" ("nf_token_id" integer,"entry_id" bigint, PRIMARY KEY ("nf_token_id","entry_id"));
+CREATE TABLE "nf_tokens" ("id" integer primary key autoincrement,"created_at" datetime,"updated_at" datetime,"deleted_at" datetime,"nf_token_id" bigint,"metadata" blob,"owner_id" integer );
+CREATE INDEX idx_nf_tokens_deleted_at ON "nf_tokens"(deleted_at) ;
+CREATE INDEX idx_nf_tokens_owner_id ON "nf_tokens"(owner_id) ;
+CREATE UNIQUE INDEX uix_nf_tokens_nf_token_id ON "nf_tokens"(nf_token_id) ;
+CREATE TABLE "balance_changes" ("id" integer primary key autoincrement,"address_id" integer NOT NULL,"entry_id" bigint NOT NULL,"height" integer NOT NULL,"timestamp" datetime NOT NULL,"delta" bigint NOT NULL );
+CREATE INDEX idx_balance_changes_address_id ON "balance_changes"(address_id) ;
+CREATE INDEX idx_balance_changes_height ON "balance_changes"("height") ;
+CREATE TABLE "nf_token_owner_changes" ("id" integer primary key autoincrement,"nf_token_id" bigint NOT NULL,"owner_id" integer NOT NULL,"entry_id" bigint NOT NULL,"height" integer NOT NULL,"timestamp" datetime NOT NULL );
+CREATE INDEX idx_nf_token_owner_changes_height ON "nf_token_owner_changes"("height") ;
+CREATE INDEX idx_nf_token_owner_changes_nf_token_id ON "nf_token_owner_changes"(nf_token_id) ;
+CREATE INDEX idx_nf_token_owner_changes_owner_id ON "nf_token_owner_changes"(owner_id) ;
+
+INSERT INTO "metadata" ("id","created_at","updated_at","height","token","issuer","issued","history_height","history_timestamp")
+	VALUES (1,'2019-04-01 00:00:00','2019-04-01 00:00:00',170000,'test',X'888888ababababababababababababababababababababababababababababab',100,170000,'2019-04-01 00:10:00+00:00');
+INSERT INTO "addresses" ("id","created_at","updated_at","rcd_hash","balance")
+	VALUES (1,'2019-04-01 00:00:00','2019-04-01 00:00:00',X'0000000000000000000000000000000000000000000000000000000000000001',0);
+INSERT INTO "addresses" ("id","created_at","updated_at","rcd_hash","balance")
+	VALUES (2,'2019-04-01 00:00:00','2019-04-01 00:00:00',X'1212121212121212121212121212121212121212121212121212121212121212',100);
+INSERT INTO "entries" ("id","hash","timestamp","data")
+	VALUES (1,X'cdcdcdcdcdcdcdcdcdcdcdcdcdcdcdcdcdcdcdcdcdcdcdcdcdcdcdcdcdcdcdcd','2019-04-01 00:00:00',X'00');
+INSERT INTO "entries" ("id","hash","timestamp","data","height","e_block_key_mr","e_block_sequence","entry_index")
+	VALUES (2,X'efefefefefefefefefefefefefefefefefefefefefefefefefefefefefefefef','2019-04-01 00:10:00',X'00',170000,X'abababababababababababababababababababababababababababababababab',5,3);
+INSERT INTO "address_transactions_to" ("address_id","entry_id") VALUES (2,2);
+INSERT INTO "balance_changes" ("id","address_id","entry_id","height","timestamp","delta")
+	VALUES (1,2,0,170000,'2019-04-01 00:10:00+00:00',100);
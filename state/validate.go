@@ -0,0 +1,576 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package state
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Factom-Asset-Tokens/fatd/factom"
+	"github.com/Factom-Asset-Tokens/fatd/fat/fat1"
+	"github.com/Factom-Asset-Tokens/fatd/flag"
+)
+
+// Validate performs a full integrity check of the chain's database. Every
+// stored entry is re-hashed, all stored transactions are replayed in order
+// into a scratch database using applyFAT0 and applyFAT1, and the resulting
+// address balances, NF token owners and issued supply are compared against
+// what is saved.
+//
+// A description of every mismatch is returned. Nothing is changed unless
+// repair is true, in which case the mismatches are logged, the database file
+// is backed up, and then corrupted entries are re-downloaded from factomd,
+// entries that are not valid transactions are removed and all saved state and
+// history is overwritten with the replayed state.
+func (chain *Chain) Validate(repair bool) ([]string, error) {
+	var backedUp bool
+	mismatches, err := chain.checkEntries(repair, &backedUp)
+	if err != nil {
+		return nil, err
+	}
+	if !chain.IsIssued() {
+		return mismatches, nil
+	}
+	stateMismatches, err := chain.checkState(repair, &backedUp)
+	if err != nil {
+		return nil, err
+	}
+	return append(mismatches, stateMismatches...), nil
+}
+
+// ValidateChain runs Validate on the tracked chain with the given id and
+// updates Chains with any repairs. A faulted chain is first reopened from its
+// database, as when it is retried, and is no longer faulted, so it must then be
+// synced using Backfill. ValidateChain must not be called concurrently with
+// Process.
+func ValidateChain(id factom.Bytes32, repair bool) ([]string, error) {
+	chain := Chains.Get(&id)
+	if chain.IsFaulted() {
+		var err error
+		if chain, err = reopenFaulted(id); err != nil {
+			return nil, err
+		}
+		if !chain.IsTracked() {
+			// The chain had not been issued, so there is
+			// nothing to validate.
+			return nil, nil
+		}
+	}
+	if !chain.IsTracked() {
+		return nil, fmt.Errorf("ChainID(%v): not tracked", id)
+	}
+	mismatches, err := chain.Validate(repair)
+	if err != nil {
+		return nil, err
+	}
+	if repair {
+		Chains.set(chain.ID, &chain)
+	}
+	return mismatches, nil
+}
+
+// checkEntries re-hashes the data of every stored entry. If repair is true,
+// any corrupted entries are reported and then re-downloaded from factomd,
+// after backing up the database unless backedUp is already true.
+func (chain *Chain) checkEntries(repair bool, backedUp *bool) ([]string, error) {
+	corrupted, err := chain.corruptedEntries()
+	if err != nil {
+		return nil, err
+	}
+	mismatches := make([]string, len(corrupted))
+	for i, e := range corrupted {
+		mismatches[i] = fmt.Sprintf("entry %v: data does not match hash",
+			e.Hash)
+	}
+	if !repair || len(corrupted) == 0 {
+		return mismatches, nil
+	}
+	if err := chain.prepareRepair(mismatches, backedUp); err != nil {
+		return nil, err
+	}
+	for _, e := range corrupted {
+		fe := factom.Entry{Hash: e.Hash, ChainID: chain.ID}
+		if err := fe.Get(c); err != nil {
+			return nil, fmt.Errorf("Entry%+v.Get(c): %v", fe, err)
+		}
+		data, err := fe.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		if err := chain.DB.Model(&e).Update("data", data).Error; err != nil {
+			return nil, err
+		}
+		log.Infof("Repaired entry %v", e.Hash)
+	}
+	return mismatches, nil
+}
+
+// corruptedEntries returns all stored entries whose data does not match their
+// hash.
+func (chain *Chain) corruptedEntries() ([]entry, error) {
+	var corrupted []entry
+	rows, err := chain.DB.Model(&entry{}).Order("id").Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var e entry
+		if err := chain.ScanRows(rows, &e); err != nil {
+			return nil, err
+		}
+		if !e.IsValid() {
+			corrupted = append(corrupted, e)
+		}
+	}
+	return corrupted, rows.Err()
+}
+
+// checkState replays all stored transactions and compares the result with the
+// saved state. If repair is true, any mismatches are reported and then the
+// saved state is overwritten, after backing up the database unless backedUp is
+// already true.
+func (chain *Chain) checkState(repair bool, backedUp *bool) ([]string, error) {
+	replay, mismatches, err := chain.validateState()
+	if err != nil {
+		return nil, err
+	}
+	if !repair || len(mismatches) == 0 {
+		return mismatches, nil
+	}
+	if err := chain.prepareRepair(mismatches, backedUp); err != nil {
+		return nil, err
+	}
+	if err := chain.repairState(replay); err != nil {
+		return nil, err
+	}
+	return mismatches, nil
+}
+
+// prepareRepair logs the mismatches that are about to be repaired and backs up
+// the database, unless backedUp is already true.
+func (chain *Chain) prepareRepair(mismatches []string, backedUp *bool) error {
+	for _, mismatch := range mismatches {
+		log.Warnf("ChainID(%v): %v", chain.ID, mismatch)
+	}
+	if *backedUp {
+		return nil
+	}
+	fpath, err := chain.backup()
+	if err != nil {
+		return fmt.Errorf("backup: %v", err)
+	}
+	*backedUp = true
+	log.Infof("ChainID(%v): backed up database to %v before repairing",
+		chain.ID, fpath)
+	return nil
+}
+
+// backup copies the chain's database file into the same directory, and
+// returns the path of the copy. The name of the copy ends in ".bak" so that it
+// is never loaded as a chain database.
+func (chain *Chain) backup() (_ string, err error) {
+	src := fmt.Sprintf("%v/%v%v", flag.DBPath, chain.ID, dbFileExtension)
+	dst := fmt.Sprintf("%v.%v.bak", src, time.Now().Unix())
+	in, err := os.Open(src)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+	if _, err := io.Copy(out, in); err != nil {
+		return "", err
+	}
+	return dst, out.Sync()
+}
+
+// replayState is the state of a chain computed solely from replaying its
+// stored transactions.
+type replayState struct {
+	Balances map[factom.FAAddress]uint64
+	Owners   map[fat1.NFTokenID]factom.FAAddress
+	Metadata map[fat1.NFTokenID][]byte
+	Issued   uint64
+
+	// Invalid holds the IDs of all stored entries that are not valid
+	// transactions.
+	Invalid []uint64
+
+	// BalanceChanges and OwnerChanges are the full history of the
+	// replayed transactions, in order.
+	BalanceChanges []replayBalanceChange
+	OwnerChanges   []replayOwnerChange
+}
+
+// replayBalanceChange is a BalanceChange from the scratch database, identified
+// by address and entry hash, since the IDs differ from the chain's database.
+type replayBalanceChange struct {
+	RCDHash   factom.FAAddress
+	EntryHash factom.Bytes32
+	Height    uint32
+	Timestamp time.Time
+	Delta     int64
+}
+
+// replayOwnerChange is an NFTokenOwnerChange from the scratch database,
+// identified by owner and entry hash.
+type replayOwnerChange struct {
+	NFTokenID fat1.NFTokenID
+	Owner     factom.FAAddress
+	EntryHash factom.Bytes32
+	Height    uint32
+	Timestamp time.Time
+}
+
+// validateState replays all stored transactions and compares the result with
+// the saved state.
+func (chain *Chain) validateState() (replayState, []string, error) {
+	replay, mismatches, err := chain.replay()
+	if err != nil {
+		return replayState{}, nil, err
+	}
+
+	var adrs []Address
+	if err := chain.Find(&adrs).Error; err != nil {
+		return replayState{}, nil, err
+	}
+	adrIDs := make(map[factom.FAAddress]uint, len(adrs))
+	for _, adr := range adrs {
+		adrIDs[*adr.RCDHash] = adr.ID
+		if balance := replay.Balances[*adr.RCDHash]; adr.Balance != balance {
+			mismatches = append(mismatches, fmt.Sprintf(
+				"address %v: balance %v, expected %v",
+				adr.RCDHash, adr.Balance, balance))
+		}
+	}
+	for rcdHash, balance := range replay.Balances {
+		if _, ok := adrIDs[rcdHash]; !ok {
+			mismatches = append(mismatches, fmt.Sprintf(
+				"address %v: missing, expected balance %v",
+				rcdHash, balance))
+		}
+	}
+
+	var tkns []NFToken
+	if err := chain.Preload("Owner").Find(&tkns).Error; err != nil {
+		return replayState{}, nil, err
+	}
+	tknIDs := make(map[fat1.NFTokenID]struct{}, len(tkns))
+	for _, tkn := range tkns {
+		tknIDs[tkn.NFTokenID] = struct{}{}
+		owner, ok := replay.Owners[tkn.NFTokenID]
+		if !ok {
+			mismatches = append(mismatches, fmt.Sprintf(
+				"NFTokenID(%v): was never issued", tkn.NFTokenID))
+			continue
+		}
+		if tkn.Owner.RCDHash == nil || *tkn.Owner.RCDHash != owner {
+			mismatches = append(mismatches, fmt.Sprintf(
+				"NFTokenID(%v): owner %v, expected %v",
+				tkn.NFTokenID, tkn.Owner.RCDHash, owner))
+		}
+	}
+	for tknID, owner := range replay.Owners {
+		if _, ok := tknIDs[tknID]; !ok {
+			mismatches = append(mismatches, fmt.Sprintf(
+				"NFTokenID(%v): missing, expected owner %v",
+				tknID, owner))
+		}
+	}
+
+	if chain.Issued != replay.Issued {
+		mismatches = append(mismatches, fmt.Sprintf(
+			"metadata: issued %v, expected %v",
+			chain.Issued, replay.Issued))
+	}
+	return replay, mismatches, nil
+}
+
+// replay applies the issuance and all stored transactions, in order, to a new
+// scratch database with the same rules as processTransactions, and returns the
+// resulting state along with a mismatch for each stored transaction that is
+// rejected.
+func (chain *Chain) replay() (replay replayState, mismatches []string,
+	err error) {
+	dir, err := ioutil.TempDir("", "fatd-replay")
+	if err != nil {
+		return replay, nil, err
+	}
+	defer os.RemoveAll(dir)
+	scratch := Chain{ID: chain.ID, ChainStatus: ChainStatusTracked,
+		Identity: chain.Identity}
+	scratch.Metadata.Token = chain.Token
+	scratch.Metadata.Issuer = chain.Issuer
+	scratch.Metadata.NetworkID = chain.Metadata.NetworkID
+	if err := scratch.setupDBPath(filepath.Join(dir,
+		fmt.Sprintf("%v%v", chain.ID, dbFileExtension))); err != nil {
+		return replay, nil, err
+	}
+	defer scratch.Close()
+
+	rows, err := chain.DB.Model(&entry{}).Order("id").Rows()
+	if err != nil {
+		return replay, nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var e entry
+		if err := chain.ScanRows(rows, &e); err != nil {
+			return replay, nil, err
+		}
+		eb := factom.EBlock{ChainID: chain.ID, KeyMR: e.EBlockKeyMR,
+			Sequence: e.EBlockSequence, Height: e.Height}
+		if e.ID == 1 {
+			if err := scratch.issue(chain.Issuance, &eb,
+				int(e.EntryIndex)); err != nil {
+				return replay, nil, err
+			}
+			continue
+		}
+		err := scratch.replayTransaction(e.Entry(), &eb, int(e.EntryIndex))
+		if rej, ok := err.(rejection); ok {
+			mismatches = append(mismatches, fmt.Sprintf(
+				"entry %v: invalid transaction: %v", e.Hash, rej))
+			replay.Invalid = append(replay.Invalid, e.ID)
+			continue
+		}
+		if err != nil {
+			return replay, nil, err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return replay, nil, err
+	}
+
+	replay.Balances = make(map[factom.FAAddress]uint64)
+	var adrs []Address
+	if err := scratch.Find(&adrs).Error; err != nil {
+		return replay, nil, err
+	}
+	rcdHashes := make(map[uint]factom.FAAddress, len(adrs))
+	for _, adr := range adrs {
+		replay.Balances[*adr.RCDHash] = adr.Balance
+		rcdHashes[adr.ID] = *adr.RCDHash
+	}
+	replay.Owners = make(map[fat1.NFTokenID]factom.FAAddress)
+	replay.Metadata = make(map[fat1.NFTokenID][]byte)
+	var tkns []NFToken
+	if err := scratch.Preload("Owner").Find(&tkns).Error; err != nil {
+		return replay, nil, err
+	}
+	for _, tkn := range tkns {
+		replay.Owners[tkn.NFTokenID] = *tkn.Owner.RCDHash
+		replay.Metadata[tkn.NFTokenID] = tkn.Metadata
+	}
+	replay.Issued = scratch.Issued
+
+	var es []entry
+	if err := scratch.Select("id, hash").Find(&es).Error; err != nil {
+		return replay, nil, err
+	}
+	hashes := make(map[uint64]factom.Bytes32, len(es))
+	for _, e := range es {
+		hashes[e.ID] = *e.Hash
+	}
+	var balanceChanges []BalanceChange
+	if err := scratch.Order("id").Find(&balanceChanges).Error; err != nil {
+		return replay, nil, err
+	}
+	replay.BalanceChanges = make([]replayBalanceChange, len(balanceChanges))
+	for i, c := range balanceChanges {
+		replay.BalanceChanges[i] = replayBalanceChange{
+			RCDHash:   rcdHashes[c.AddressID],
+			EntryHash: hashes[c.EntryID],
+			Height:    c.Height,
+			Timestamp: c.Timestamp,
+			Delta:     c.Delta,
+		}
+	}
+	var ownerChanges []NFTokenOwnerChange
+	if err := scratch.Order("id").Find(&ownerChanges).Error; err != nil {
+		return replay, nil, err
+	}
+	replay.OwnerChanges = make([]replayOwnerChange, len(ownerChanges))
+	for i, c := range ownerChanges {
+		replay.OwnerChanges[i] = replayOwnerChange{
+			NFTokenID: c.NFTokenID,
+			Owner:     rcdHashes[c.OwnerID],
+			EntryHash: hashes[c.EntryID],
+			Height:    c.Height,
+			Timestamp: c.Timestamp,
+		}
+	}
+	return replay, mismatches, nil
+}
+
+// replayTransaction applies the transaction in e, which is the entry at the
+// given index in eb, like applyTransaction, but without calling the
+// TransactionHooks or publishing any events.
+func (chain *Chain) replayTransaction(e factom.Entry,
+	eb *factom.EBlock, index int) (err error) {
+	db := chain.Begin()
+	defer chain.rollbackUnlessCommitted(*chain, &err)
+	chain.DB = db
+	if _, err := chain.apply(e, eb, index); err != nil {
+		return err
+	}
+	return chain.Commit().Error
+}
+
+// repairState overwrites the saved state and history of the chain with the
+// replay state in a single database transaction. Since the history is rebuilt
+// from every stored transaction, the full history is then available.
+func (chain *Chain) repairState(replay replayState) (err error) {
+	db := chain.Begin()
+	defer chain.rollbackUnlessCommitted(*chain, &err)
+	chain.DB = db
+
+	for _, id := range replay.Invalid {
+		for _, qry := range []string{
+			"DELETE FROM address_transactions_to WHERE entry_id = ?;",
+			"DELETE FROM address_transactions_from WHERE entry_id = ?;",
+			"DELETE FROM nf_token_transactions WHERE entry_id = ?;",
+			"DELETE FROM entries WHERE id = ?;",
+		} {
+			if err := chain.Exec(qry, id).Error; err != nil {
+				return fmt.Errorf("%#v: %v", qry, err)
+			}
+		}
+	}
+
+	adrIDs := make(map[factom.FAAddress]uint, len(replay.Balances))
+	if err := chain.DB.Model(&Address{}).
+		Update("balance", 0).Error; err != nil {
+		return err
+	}
+	for rcdHash, balance := range replay.Balances {
+		adr, err := chain.GetAddress(&rcdHash)
+		if err != nil {
+			return err
+		}
+		adr.Balance = balance
+		if err := chain.Save(&adr).Error; err != nil {
+			return err
+		}
+		adrIDs[rcdHash] = adr.ID
+	}
+
+	// Remove all NF tokens that were never issued.
+	qry := chain.Unscoped()
+	if len(replay.Owners) > 0 {
+		tknIDs := make([]fat1.NFTokenID, 0, len(replay.Owners))
+		for tknID := range replay.Owners {
+			tknIDs = append(tknIDs, tknID)
+		}
+		qry = qry.Where("nf_token_id NOT IN (?)", tknIDs)
+	}
+	if err := qry.Delete(&NFToken{}).Error; err != nil {
+		return err
+	}
+	for tknID, owner := range replay.Owners {
+		tkn := NFToken{NFTokenID: tknID}
+		if err := chain.Where("nf_token_id = ?", tknID).
+			FirstOrInit(&tkn).Error; err != nil {
+			return err
+		}
+		if tkn.ID == 0 {
+			tkn.Metadata = replay.Metadata[tknID]
+		}
+		tkn.OwnerID = adrIDs[owner]
+		if err := chain.Save(&tkn).Error; err != nil {
+			return err
+		}
+	}
+
+	if err := chain.repairHistory(replay, adrIDs); err != nil {
+		return err
+	}
+
+	chain.Issued = replay.Issued
+	chain.HistoryHeight = 0
+	chain.HistoryTimestamp = time.Time{}
+	if err := chain.saveMetadata(); err != nil {
+		return err
+	}
+	log.Infof("Repaired state of chain %v", chain.ID)
+	return chain.Commit().Error
+}
+
+// repairHistory replaces all BalanceChanges and NFTokenOwnerChanges with those
+// of the replay. The adrIDs must hold the ID of every replayed address.
+func (chain *Chain) repairHistory(replay replayState,
+	adrIDs map[factom.FAAddress]uint) error {
+	for _, qry := range []string{
+		"DELETE FROM balance_changes;",
+		"DELETE FROM nf_token_owner_changes;",
+	} {
+		if err := chain.Exec(qry).Error; err != nil {
+			return fmt.Errorf("%#v: %v", qry, err)
+		}
+	}
+	var es []entry
+	if err := chain.Select("id, hash").Find(&es).Error; err != nil {
+		return err
+	}
+	entryIDs := make(map[factom.Bytes32]uint64, len(es))
+	for _, e := range es {
+		entryIDs[*e.Hash] = e.ID
+	}
+	for _, c := range replay.BalanceChanges {
+		change := BalanceChange{
+			AddressID: adrIDs[c.RCDHash],
+			EntryID:   entryIDs[c.EntryHash],
+			Height:    c.Height,
+			Timestamp: c.Timestamp.UTC(),
+			Delta:     c.Delta,
+		}
+		if err := chain.Create(&change).Error; err != nil {
+			return err
+		}
+	}
+	for _, c := range replay.OwnerChanges {
+		change := NFTokenOwnerChange{
+			NFTokenID: c.NFTokenID,
+			OwnerID:   adrIDs[c.Owner],
+			EntryID:   entryIDs[c.EntryHash],
+			Height:    c.Height,
+			Timestamp: c.Timestamp.UTC(),
+		}
+		if err := chain.Create(&change).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package state
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Factom-Asset-Tokens/fatd/factom"
+	"github.com/Factom-Asset-Tokens/fatd/fat"
+	"github.com/Factom-Asset-Tokens/fatd/fat/fat0"
+	"github.com/Factom-Asset-Tokens/fatd/flag"
+)
+
+// newTestFAT0Chain returns a FAT-0 chain issued in an EBlock at height 10 with
+// a saved coinbase transaction of 100 to adrs[0] in the same EBlock, and a
+// transaction of 40 from adrs[0] to adrs[1] in an EBlock at height 20, ten
+// minutes later.
+func newTestFAT0Chain(t *testing.T) (*Chain, []factom.FsAddress) {
+	require := require.New(t)
+	issuerSecret, err := factom.GenerateSK1Key()
+	require.NoError(err)
+	adrs := make([]factom.FsAddress, 2)
+	for i := range adrs {
+		adrs[i], err = factom.GenerateFsAddress()
+		require.NoError(err)
+	}
+
+	issuer := factom.NewBytes32(make([]byte, 32))
+	chainID := fat.ChainID("test", *issuer)
+	chain := &Chain{ID: &chainID}
+	chain.Metadata.Token = "test"
+	chain.Metadata.Issuer = issuer
+	chain.Identity.ChainID = issuer
+	chain.ID1 = issuerSecret.ID1Key()
+	require.NoError(chain.setupDB())
+
+	ebs := []*factom.EBlock{
+		{KeyMR: factom.NewBytes32(make([]byte, 32)), Sequence: 0, Height: 10},
+		{KeyMR: factom.NewBytes32(make([]byte, 32)), Sequence: 1, Height: 20},
+	}
+	ebs[0].KeyMR[0], ebs[1].KeyMR[0] = 1, 2
+
+	issuance := fat.NewIssuance(factom.Entry{ChainID: chain.ID})
+	issuance.Type = fat0.Type
+	issuance.Supply = 1000
+	require.NoError(issuance.MarshalEntry())
+	issuance.Sign(issuerSecret)
+	issuance.Height = 10
+	require.NoError(chain.issue(issuance, ebs[0], 0))
+
+	coinbase := fat0.NewTransaction(factom.Entry{ChainID: chain.ID})
+	coinbase.Inputs = fat0.AddressAmountMap{fat.Coinbase(): 100}
+	coinbase.Outputs = fat0.AddressAmountMap{adrs[0].FAAddress(): 100}
+	require.NoError(coinbase.MarshalEntry())
+	coinbase.Sign(issuerSecret)
+	coinbase.Height = 10
+	coinbase.Timestamp = coinbase.Timestamp.Add(-10 * time.Minute)
+	require.NoError(chain.applyTransaction(coinbase.Entry.Entry, ebs[0], 1))
+
+	tx := fat0.NewTransaction(factom.Entry{ChainID: chain.ID})
+	tx.Inputs = fat0.AddressAmountMap{adrs[0].FAAddress(): 40}
+	tx.Outputs = fat0.AddressAmountMap{adrs[1].FAAddress(): 40}
+	require.NoError(tx.MarshalEntry())
+	tx.Sign(adrs[0])
+	tx.Height = 20
+	require.NoError(chain.applyTransaction(tx.Entry.Entry, ebs[1], 0))
+
+	return chain, adrs
+}
+
+func TestValidate(t *testing.T) {
+	defer setupTestDBPath(t)()
+	assert := assert.New(t)
+	require := require.New(t)
+	chain, adrs := newTestFAT0Chain(t)
+	defer chain.Close()
+
+	mismatches, err := chain.Validate(false)
+	require.NoError(err)
+	assert.Empty(mismatches)
+
+	// Corrupt a balance, the issued supply and the history.
+	rcdHash := adrs[1].FAAddress()
+	adr, err := chain.GetAddress(&rcdHash)
+	require.NoError(err)
+	adr.Balance = 50
+	require.NoError(chain.Save(&adr).Error)
+	chain.Issued = 90
+	chain.HistoryHeight = 15
+	require.NoError(chain.saveMetadata())
+	require.NoError(chain.Exec(
+		"DELETE FROM balance_changes WHERE height = 10;").Error)
+
+	// Without repair nothing is changed or backed up.
+	mismatches, err = chain.Validate(false)
+	require.NoError(err)
+	assert.Len(mismatches, 2)
+	adr, err = chain.GetAddress(&rcdHash)
+	require.NoError(err)
+	assert.Equal(uint64(50), adr.Balance)
+	backups, err := filepath.Glob(filepath.Join(flag.DBPath, "*.bak"))
+	require.NoError(err)
+	assert.Empty(backups)
+
+	// The database is backed up once before it is repaired.
+	mismatches, err = chain.Validate(true)
+	require.NoError(err)
+	assert.Len(mismatches, 2)
+	backups, err = filepath.Glob(filepath.Join(flag.DBPath, "*.bak"))
+	require.NoError(err)
+	assert.Len(backups, 1)
+
+	mismatches, err = chain.Validate(false)
+	require.NoError(err)
+	assert.Empty(mismatches)
+	adr, err = chain.GetAddress(&rcdHash)
+	require.NoError(err)
+	assert.Equal(uint64(40), adr.Balance)
+	rcdHash = adrs[0].FAAddress()
+	adr, err = chain.GetAddress(&rcdHash)
+	require.NoError(err)
+	assert.Equal(uint64(60), adr.Balance)
+	assert.Equal(uint64(100), chain.Issued)
+
+	// The history is rebuilt from the replay, so it is fully available
+	// and consistent with the repaired balances.
+	assert.Equal(uint32(0), chain.HistoryHeight)
+	for i, expected := range [][]uint64{{100, 0}, {60, 40}} {
+		asOf := AsOf{Height: uint32(10 * (i + 1))}
+		for j, balance := range expected {
+			rcdHash := adrs[j].FAAddress()
+			actual, err := chain.GetBalanceAsOf(&rcdHash, asOf)
+			require.NoError(err)
+			assert.Equal(balance, actual, "height %v", asOf.Height)
+		}
+	}
+}
+
+func TestValidateCorruptedEntry(t *testing.T) {
+	defer setupTestDBPath(t)()
+	require := require.New(t)
+	chain, _ := newTestFAT0Chain(t)
+	defer chain.Close()
+
+	require.NoError(chain.Exec(
+		"UPDATE entries SET data = ? WHERE id = 3;", []byte{0}).Error)
+	mismatches, err := chain.Validate(false)
+	require.NoError(err)
+	// The corrupted entry is reported as corrupted and its transaction is
+	// no longer valid, so the balances no longer match either.
+	require.NotEmpty(mismatches)
+	assert.Contains(t, mismatches[0], "data does not match hash")
+}
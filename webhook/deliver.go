@@ -0,0 +1,265 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Factom-Asset-Tokens/fatd/events"
+	"github.com/Factom-Asset-Tokens/fatd/flag"
+	_log "github.com/Factom-Asset-Tokens/fatd/log"
+	"github.com/Factom-Asset-Tokens/fatd/state"
+)
+
+// Headers sent with each delivery.
+var (
+	SignatureHeaderKey = http.CanonicalHeaderKey("Fatd-Signature")
+	DeliveryHeaderKey  = http.CanonicalHeaderKey("Fatd-Delivery")
+)
+
+const (
+	pollInterval   = 1 * time.Second
+	requestTimeout = 10 * time.Second
+	batchSize      = 100
+
+	// The delay before each retry of a delivery, and before a hook is
+	// attempted again after a failure, doubles from minRetryDelay up to
+	// maxRetryDelay. A delivery is dropped after maxAttempts.
+	minRetryDelay = 10 * time.Second
+	maxRetryDelay = 1 * time.Hour
+	maxAttempts   = 30
+)
+
+var (
+	log    _log.Log
+	client = http.Client{Timeout: requestTimeout}
+)
+
+// Start loads the hooks from flag.Webhooks, if set, and registers a
+// state.TransactionHook to queue matching transactions. It must be called
+// before the engine is started. Queued deliveries are attempted in a goroutine
+// until stop is closed. The returned done channel is closed when the
+// goroutine exits. If the done channel is closed before the stop channel is
+// closed, an error occurred. Start returns nil if it fails to start.
+func Start(stop <-chan struct{}) (done <-chan struct{}) {
+	log = _log.New("webhook")
+	_done := make(chan struct{})
+	if len(flag.Webhooks) == 0 {
+		go func() {
+			<-stop
+			close(_done)
+		}()
+		return _done
+	}
+
+	hooks, err := LoadHooks(flag.Webhooks)
+	if err != nil {
+		log.Errorf("LoadHooks(): %v", err)
+		return nil
+	}
+	q, err := openQueue(flag.DBPath)
+	if err != nil {
+		log.Errorf("openQueue(): %v", err)
+		return nil
+	}
+	state.TransactionHooks = append(state.TransactionHooks,
+		func(evt events.Transaction) error {
+			return q.enqueue(hooks, evt)
+		})
+	log.Infof("Loaded %v webhooks.", len(hooks))
+
+	go func() {
+		defer close(_done)
+		defer q.Close()
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+		for {
+			if err := q.deliverDue(hooks, time.Now()); err != nil {
+				log.Error(err)
+				return
+			}
+			select {
+			case <-ticker.C:
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return _done
+}
+
+// deliverDue attempts the deliveries that are due at now. Each hook is
+// delivered to concurrently, so that a slow or unreachable endpoint does not
+// delay the others, and a hook whose endpoint fails is backed off. Failed
+// deliveries are rescheduled or dropped. An error is only returned if the
+// queue could not be updated.
+func (q *queue) deliverDue(hooks []Hook, now time.Time) error {
+	urls := make([]string, len(hooks))
+	for i, hook := range hooks {
+		urls[i] = hook.URL
+		if q.backoff[hook.URL] == nil {
+			q.backoff[hook.URL] = &backoff{}
+		}
+	}
+	if err := q.dropUnconfigured(urls, now); err != nil {
+		return err
+	}
+
+	errs := make(chan error, len(hooks))
+	var wg sync.WaitGroup
+	wg.Add(len(hooks))
+	for _, hook := range hooks {
+		hook := hook
+		go func() {
+			defer wg.Done()
+			if err := q.deliverHook(hook, q.backoff[hook.URL],
+				now); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	return <-errs
+}
+
+// backoff delays all deliveries to a hook after a delivery to it fails.
+type backoff struct {
+	failures uint
+	until    time.Time
+}
+
+// deliverHook attempts the deliveries to hook that are due at now, in the
+// order they were queued, until one fails, unless hook is backed off.
+func (q *queue) deliverHook(hook Hook, b *backoff, now time.Time) error {
+	if now.Before(b.until) {
+		return nil
+	}
+	for {
+		ds, err := q.due(hook.URL, now, batchSize)
+		if err != nil {
+			return fmt.Errorf("queue.due(): %v", err)
+		}
+		for _, d := range ds {
+			if err := post(hook, d); err != nil {
+				b.until = now.Add(retryDelay(b.failures))
+				b.failures++
+				return q.failed(d, now, err)
+			}
+			b.failures = 0
+			if err := q.done(d); err != nil {
+				return fmt.Errorf("queue.done(): %v", err)
+			}
+		}
+		if len(ds) < batchSize {
+			return nil
+		}
+	}
+}
+
+// dropUnconfigured drops the deliveries that are due at now to any URL other
+// than urls.
+func (q *queue) dropUnconfigured(urls []string, now time.Time) error {
+	for {
+		ds, err := q.dueExcept(urls, now, batchSize)
+		if err != nil {
+			return fmt.Errorf("queue.dueExcept(): %v", err)
+		}
+		for _, d := range ds {
+			log.Warnf("Dropping delivery of %v to %v: "+
+				"webhook no longer configured", d.Hash, d.URL)
+			if err := q.done(d); err != nil {
+				return fmt.Errorf("queue.done(): %v", err)
+			}
+		}
+		if len(ds) < batchSize {
+			return nil
+		}
+	}
+}
+
+func (q *queue) failed(d delivery, now time.Time, err error) error {
+	if d.Attempts+1 >= maxAttempts {
+		log.Errorf("Dropping delivery of %v to %v after %v attempts: %v",
+			d.Hash, d.URL, d.Attempts+1, err)
+		if err := q.done(d); err != nil {
+			return fmt.Errorf("queue.done(): %v", err)
+		}
+		return nil
+	}
+	delay := retryDelay(d.Attempts)
+	log.Debugf("Delivery of %v to %v failed, retrying in %v: %v",
+		d.Hash, d.URL, delay, err)
+	if err := q.retry(d, now.Add(delay)); err != nil {
+		return fmt.Errorf("queue.retry(): %v", err)
+	}
+	return nil
+}
+
+// retryDelay returns the delay before the next attempt after the given number
+// of consecutive failures.
+func retryDelay(failures uint) time.Duration {
+	if failures < 32 && minRetryDelay<<failures < maxRetryDelay {
+		return minRetryDelay << failures
+	}
+	return maxRetryDelay
+}
+
+// post sends the payload of d to hook. Any response other than 2xx is an
+// error.
+func post(hook Hook, d delivery) error {
+	req, err := http.NewRequest(http.MethodPost, hook.URL,
+		bytes.NewReader(d.Payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeaderKey, "sha256="+Sign(hook.Secret, d.Payload))
+	req.Header.Set(DeliveryHeaderKey, fmt.Sprint(d.ID))
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	io.Copy(ioutil.Discard, res.Body)
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("HTTP status %v", res.Status)
+	}
+	return nil
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of payload using secret.
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,167 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+
+	"github.com/Factom-Asset-Tokens/fatd/events"
+	"github.com/Factom-Asset-Tokens/fatd/factom"
+)
+
+const (
+	dbDriver   = "sqlite3"
+	dbFileName = "webhooks.sqlite3"
+)
+
+// delivery is a queued POST of Payload to URL. NextAttempt is a Unix
+// timestamp. Once a delivery succeeds or is dropped it is marked Done and its
+// Payload is cleared, but it is kept so that the transaction is never queued
+// for the same URL again, for example after a chain is rewound and resynced.
+type delivery struct {
+	ID          uint64
+	URL         string          `gorm:"NOT NULL; UNIQUE_INDEX:idx_url_hash;"`
+	Hash        *factom.Bytes32 `gorm:"type:VARCHAR(32); NOT NULL; UNIQUE_INDEX:idx_url_hash;"`
+	Payload     []byte          `gorm:"NOT NULL;"`
+	Attempts    uint            `gorm:"NOT NULL; DEFAULT:0;"`
+	NextAttempt int64           `gorm:"INDEX; NOT NULL;"`
+	Done        bool            `gorm:"INDEX; NOT NULL; DEFAULT:false;"`
+}
+
+// queue is the database of deliveries. The mutex serializes writes, since
+// transactions on different chains are processed concurrently, and hooks are
+// delivered to concurrently.
+//
+// backoff holds the backoff of each hook by URL. It is only used by
+// deliverDue.
+type queue struct {
+	*gorm.DB
+	sync.Mutex
+
+	backoff map[string]*backoff
+}
+
+func openQueue(dbPath string) (*queue, error) {
+	if err := os.Mkdir(dbPath, 0755); err != nil && !os.IsExist(err) {
+		return nil, fmt.Errorf("os.Mkdir(%#v)", dbPath)
+	}
+	fpath := filepath.Join(dbPath, dbFileName)
+	db, err := gorm.Open(dbDriver, fpath)
+	if err != nil {
+		return nil, err
+	}
+	db.LogMode(false)
+	if err := db.AutoMigrate(&delivery{}).Error; err != nil {
+		db.Close()
+		return nil, fmt.Errorf("db.AutoMigrate(&delivery{}): %v", err)
+	}
+	return &queue{DB: db, backoff: make(map[string]*backoff)}, nil
+}
+
+// enqueue adds a delivery of evt to each hook that it matches. A transaction
+// that has already been queued for a hook is not queued again, even if it was
+// delivered, so reprocessing a transaction after a failure or a resync does not
+// duplicate its deliveries.
+func (q *queue) enqueue(hooks []Hook, evt events.Transaction) error {
+	var payload []byte
+	q.Lock()
+	defer q.Unlock()
+	for _, hook := range hooks {
+		if !hook.Matches(evt) {
+			continue
+		}
+		if payload == nil {
+			var err error
+			if payload, err = json.Marshal(evt); err != nil {
+				return err
+			}
+		}
+		var count int
+		if err := q.Model(&delivery{}).
+			Where("url = ? AND hash = ?", hook.URL, evt.Hash).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+		if err := q.Create(&delivery{
+			URL:         hook.URL,
+			Hash:        evt.Hash,
+			Payload:     payload,
+			NextAttempt: time.Now().Unix(),
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// due returns up to limit deliveries to url whose next attempt is due at now.
+func (q *queue) due(url string, now time.Time, limit int) ([]delivery, error) {
+	var ds []delivery
+	err := q.Where("url = ? AND done = ? AND next_attempt <= ?",
+		url, false, now.Unix()).
+		Order("id").Limit(limit).Find(&ds).Error
+	return ds, err
+}
+
+// dueExcept returns up to limit deliveries to any URL other than urls whose
+// next attempt is due at now.
+func (q *queue) dueExcept(urls []string, now time.Time,
+	limit int) ([]delivery, error) {
+	qry := q.Where("done = ? AND next_attempt <= ?", false, now.Unix())
+	if len(urls) > 0 {
+		qry = qry.Where("url NOT IN (?)", urls)
+	}
+	var ds []delivery
+	err := qry.Order("id").Limit(limit).Find(&ds).Error
+	return ds, err
+}
+
+// done marks d as delivered or dropped.
+func (q *queue) done(d delivery) error {
+	q.Lock()
+	defer q.Unlock()
+	return q.Model(&d).Updates(map[string]interface{}{
+		"done":    true,
+		"payload": []byte{},
+	}).Error
+}
+
+func (q *queue) retry(d delivery, next time.Time) error {
+	q.Lock()
+	defer q.Unlock()
+	return q.Model(&d).Updates(map[string]interface{}{
+		"attempts":     d.Attempts + 1,
+		"next_attempt": next.Unix(),
+	}).Error
+}
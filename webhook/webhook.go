@@ -0,0 +1,169 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+// Package webhook delivers confirmed transactions on tracked token chains to
+// operator configured URLs. Each matching transaction is queued in a database
+// after the transaction is committed to the token chain's database, but before
+// the chain's height is saved, so a transaction that was committed but not
+// queued before fatd stopped is queued when its EBlock is processed again. Each
+// delivery is POSTed as a signed JSON payload until it is accepted or the
+// maximum number of attempts is reached. The queue survives restarts, so each
+// transaction is delivered at least once.
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+
+	"github.com/Factom-Asset-Tokens/fatd/events"
+	"github.com/Factom-Asset-Tokens/fatd/factom"
+	"github.com/Factom-Asset-Tokens/fatd/fat/fat0"
+	"github.com/Factom-Asset-Tokens/fatd/fat/fat1"
+)
+
+// Hook is a URL to POST matching transactions to. A transaction matches if it
+// satisfies all of the filters that are set.
+type Hook struct {
+	URL string `json:"url"`
+	// Secret is the key used to sign the payloads with HMAC-SHA256.
+	Secret string `json:"secret"`
+
+	// ChainID limits the transactions to a single token chain.
+	ChainID *factom.Bytes32 `json:"chainid,omitempty"`
+	// Addresses limits the transactions to those that include any of
+	// these addresses in their inputs or outputs.
+	Addresses []factom.FAAddress `json:"addresses,omitempty"`
+	// MinAmount limits the transactions to those whose outputs sum to at
+	// least this amount, or for FAT-1 this many NF tokens. If Addresses is
+	// set, only outputs to those addresses are counted.
+	MinAmount uint64 `json:"minamount,omitempty"`
+	// NFTokenIDs limits the transactions to those that transfer any of
+	// these NF tokens.
+	NFTokenIDs []fat1.NFTokenID `json:"nftokenids,omitempty"`
+
+	addresses map[factom.FAAddress]struct{}
+}
+
+// LoadHooks reads a JSON array of Hooks from the file fname.
+func LoadHooks(fname string) ([]Hook, error) {
+	data, err := ioutil.ReadFile(fname)
+	if err != nil {
+		return nil, err
+	}
+	var hooks []Hook
+	if err := json.Unmarshal(data, &hooks); err != nil {
+		return nil, fmt.Errorf("%v: %v", fname, err)
+	}
+	urls := make(map[string]struct{}, len(hooks))
+	for i := range hooks {
+		hook := &hooks[i]
+		u, err := url.Parse(hook.URL)
+		if err != nil {
+			return nil, fmt.Errorf("%v: hook %v: %v", fname, i, err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return nil, fmt.Errorf("%v: hook %v: %q is not an HTTP URL",
+				fname, i, hook.URL)
+		}
+		if _, ok := urls[hook.URL]; ok {
+			return nil, fmt.Errorf("%v: hook %v: duplicate URL %q",
+				fname, i, hook.URL)
+		}
+		urls[hook.URL] = struct{}{}
+		if len(hook.Secret) == 0 {
+			return nil, fmt.Errorf(`%v: hook %v: "secret" is required`,
+				fname, i)
+		}
+		hook.index()
+	}
+	return hooks, nil
+}
+
+func (hook *Hook) index() {
+	if len(hook.Addresses) == 0 {
+		return
+	}
+	hook.addresses = make(map[factom.FAAddress]struct{}, len(hook.Addresses))
+	for _, adr := range hook.Addresses {
+		hook.addresses[adr] = struct{}{}
+	}
+}
+
+// Matches returns true if evt satisfies all of the filters of hook.
+func (hook Hook) Matches(evt events.Transaction) bool {
+	if hook.ChainID != nil && *hook.ChainID != *evt.ChainID {
+		return false
+	}
+	if len(hook.addresses) > 0 {
+		var found bool
+		for _, adr := range evt.Addresses {
+			if _, found = hook.addresses[adr]; found {
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(hook.NFTokenIDs) > 0 {
+		var found bool
+		for _, tknID := range hook.NFTokenIDs {
+			if _, found = evt.NFTokens[tknID]; found {
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return hook.MinAmount == 0 || hook.amount(evt.Tx) >= hook.MinAmount
+}
+
+// amount returns the sum of the outputs of tx that count towards MinAmount.
+func (hook Hook) amount(tx interface{}) uint64 {
+	var amount uint64
+	switch tx := tx.(type) {
+	case fat0.Transaction:
+		for rcdHash, a := range tx.Outputs {
+			if hook.counts(rcdHash) {
+				amount += a
+			}
+		}
+	case fat1.Transaction:
+		for rcdHash, tkns := range tx.Outputs {
+			if hook.counts(rcdHash) {
+				amount += uint64(len(tkns))
+			}
+		}
+	}
+	return amount
+}
+
+func (hook Hook) counts(rcdHash factom.FAAddress) bool {
+	if len(hook.addresses) == 0 {
+		return true
+	}
+	_, ok := hook.addresses[rcdHash]
+	return ok
+}
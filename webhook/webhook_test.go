@@ -0,0 +1,235 @@
+// MIT License
+//
+// Copyright 2018 Canonical Ledgers, LLC
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to
+// deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or
+// sell copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING
+// FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS
+// IN THE SOFTWARE.
+
+package webhook
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Factom-Asset-Tokens/fatd/events"
+	"github.com/Factom-Asset-Tokens/fatd/factom"
+	"github.com/Factom-Asset-Tokens/fatd/fat"
+	"github.com/Factom-Asset-Tokens/fatd/fat/fat0"
+	"github.com/Factom-Asset-Tokens/fatd/fat/fat1"
+	_log "github.com/Factom-Asset-Tokens/fatd/log"
+)
+
+func newTestTransaction(t *testing.T, chainID *factom.Bytes32,
+	outputs fat0.AddressAmountMap) events.Transaction {
+	tx := fat0.NewTransaction(factom.Entry{ChainID: chainID})
+	tx.Inputs = fat0.AddressAmountMap{fat.Coinbase(): outputs.Sum()}
+	tx.Outputs = outputs
+	require.NoError(t, tx.MarshalEntry())
+	hash, err := tx.ComputeHash()
+	require.NoError(t, err)
+	evt := events.Transaction{ChainID: chainID, Hash: &hash, Tx: tx,
+		Addresses: []factom.FAAddress{fat.Coinbase()}}
+	for rcdHash := range outputs {
+		evt.Addresses = append(evt.Addresses, rcdHash)
+	}
+	return evt
+}
+
+func TestMatches(t *testing.T) {
+	var chainID, otherChainID factom.Bytes32
+	otherChainID[0] = 1
+	adrs := make([]factom.FAAddress, 2)
+	adrs[0][0], adrs[1][0] = 1, 2
+	evt := newTestTransaction(t, &chainID, fat0.AddressAmountMap{
+		adrs[0]: 10, adrs[1]: 5})
+
+	for _, test := range []struct {
+		Name  string
+		Hook  Hook
+		Match bool
+	}{{
+		Name:  "no filters",
+		Match: true,
+	}, {
+		Name:  "chain",
+		Hook:  Hook{ChainID: &chainID},
+		Match: true,
+	}, {
+		Name: "other chain",
+		Hook: Hook{ChainID: &otherChainID},
+	}, {
+		Name:  "address",
+		Hook:  Hook{Addresses: adrs[1:]},
+		Match: true,
+	}, {
+		Name: "other address",
+		Hook: Hook{Addresses: []factom.FAAddress{{3}}},
+	}, {
+		Name:  "min amount",
+		Hook:  Hook{MinAmount: 15},
+		Match: true,
+	}, {
+		Name: "min amount to address",
+		Hook: Hook{Addresses: adrs[1:], MinAmount: 6},
+	}, {
+		Name: "NF token",
+		Hook: Hook{NFTokenIDs: []fat1.NFTokenID{1}},
+	}} {
+		t.Run(test.Name, func(t *testing.T) {
+			hook := test.Hook
+			hook.index()
+			assert.Equal(t, test.Match, hook.Matches(evt))
+		})
+	}
+}
+
+func TestDeliver(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	log = _log.New("webhook")
+	dir, err := ioutil.TempDir("", "fatd-webhook-test")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	var received [][]byte
+	var fail bool
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			body, err := ioutil.ReadAll(r.Body)
+			require.NoError(err)
+			assert.Equal("sha256="+Sign("secret", body),
+				r.Header.Get(SignatureHeaderKey))
+			if fail {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			received = append(received, body)
+		}))
+	defer ts.Close()
+
+	fname := filepath.Join(dir, "webhooks.json")
+	require.NoError(ioutil.WriteFile(fname, []byte(
+		`[{"url":"`+ts.URL+`","secret":"secret","minamount":10}]`), 0644))
+	hooks, err := LoadHooks(fname)
+	require.NoError(err)
+
+	q, err := openQueue(dir)
+	require.NoError(err)
+	defer q.Close()
+
+	var chainID factom.Bytes32
+	small := newTestTransaction(t, &chainID, fat0.AddressAmountMap{{1}: 5})
+	large := newTestTransaction(t, &chainID, fat0.AddressAmountMap{{1}: 10})
+	require.NoError(q.enqueue(hooks, small))
+	require.NoError(q.enqueue(hooks, large))
+	// Reprocessing does not queue a duplicate delivery.
+	require.NoError(q.enqueue(hooks, large))
+
+	now := time.Now()
+	fail = true
+	require.NoError(q.deliverDue(hooks, now))
+	assert.Empty(received)
+	ds, err := q.due(ts.URL, now.Add(minRetryDelay), batchSize)
+	require.NoError(err)
+	require.Len(ds, 1)
+	assert.Equal(uint(1), ds[0].Attempts)
+
+	fail = false
+	require.NoError(q.deliverDue(hooks, now))
+	assert.Empty(received, "retry is not yet due")
+	require.NoError(q.deliverDue(hooks, now.Add(minRetryDelay)))
+	require.Len(received, 1)
+	assert.Contains(string(received[0]), large.Hash.String())
+	ds, err = q.due(ts.URL, now.Add(maxRetryDelay), batchSize)
+	require.NoError(err)
+	assert.Empty(ds)
+
+	// Reprocessing after a resync does not deliver it again.
+	require.NoError(q.enqueue(hooks, large))
+	ds, err = q.due(ts.URL, now.Add(maxRetryDelay), batchSize)
+	require.NoError(err)
+	assert.Empty(ds)
+}
+
+func TestDeliverConcurrently(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	log = _log.New("webhook")
+	dir, err := ioutil.TempDir("", "fatd-webhook-test")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	// The failing endpoint only responds once the other endpoint has
+	// received its delivery, which is queued later.
+	received := make(chan struct{})
+	var failures int32
+	failing := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&failures, 1)
+			select {
+			case <-received:
+			case <-time.After(5 * time.Second):
+				t.Error("deliveries to other hooks were blocked")
+			}
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+	defer failing.Close()
+	working := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			close(received)
+		}))
+	defer working.Close()
+
+	hooks := []Hook{{URL: failing.URL, Secret: "secret"},
+		{URL: working.URL, Secret: "secret",
+			ChainID: new(factom.Bytes32)}}
+	q, err := openQueue(dir)
+	require.NoError(err)
+	defer q.Close()
+
+	var chainID, otherChainID factom.Bytes32
+	otherChainID[0] = 1
+	require.NoError(q.enqueue(hooks, newTestTransaction(t,
+		&otherChainID, fat0.AddressAmountMap{{1}: 5})))
+	require.NoError(q.enqueue(hooks, newTestTransaction(t,
+		&chainID, fat0.AddressAmountMap{{1}: 5})))
+
+	now := time.Now()
+	require.NoError(q.deliverDue(hooks, now))
+	assert.Equal(int32(1), atomic.LoadInt32(&failures))
+
+	// The failing hook is backed off, so none of its deliveries are
+	// attempted until the backoff expires, even if they are due.
+	require.NoError(q.enqueue(hooks, newTestTransaction(t,
+		&otherChainID, fat0.AddressAmountMap{{1}: 6})))
+	require.NoError(q.deliverDue(hooks, now.Add(minRetryDelay/2)))
+	assert.Equal(int32(1), atomic.LoadInt32(&failures))
+	require.NoError(q.deliverDue(hooks, now.Add(minRetryDelay)))
+	assert.Equal(int32(2), atomic.LoadInt32(&failures))
+	assert.Equal(now.Add(3*minRetryDelay), q.backoff[failing.URL].until,
+		"the backoff doubles")
+}